@@ -0,0 +1,128 @@
+package repacker
+
+import (
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// importNames are the names the generated code refers to the packages it
+// imports by, by import path: their package names, or aliases where two
+// packages of the same name meet (e.g. dbmodels for db/models next to
+// api/models), or where one is named after the generated package or a
+// standard package the code calls.
+type importNames struct {
+	byPath map[string]string // names by import path
+	paths  map[string]string // import paths by name
+	names  map[string]string // package names of the aliased paths, by import path
+}
+
+// newImportNames returns the names of the imports of the code generated
+// into the package named pkgName, of import path pkgPath, which the
+// standard packages of stdImports keep the names of.
+func newImportNames(pkgPath, pkgName string) *importNames {
+	n := &importNames{byPath: map[string]string{}, paths: map[string]string{}, names: map[string]string{}}
+	n.paths[pkgName] = pkgPath
+	for name, importPath := range stdImports {
+		n.byPath[importPath], n.paths[name] = name, importPath
+	}
+	return n
+}
+
+// name returns the name the generated code refers to the package of the
+// import path and package name by, choosing an alias the first time if
+// another package already has the name.
+func (n *importNames) name(importPath, pkgName string) string {
+	if n == nil {
+		return pkgName
+	}
+	if name, ok := n.byPath[importPath]; ok {
+		return name
+	}
+	name := pkgName
+	if taken, ok := n.paths[name]; ok && taken != importPath {
+		name = n.alias(importPath, pkgName)
+		n.names[importPath] = pkgName
+	}
+	n.byPath[importPath], n.paths[name] = name, importPath
+	return name
+}
+
+// current returns the name the generated code refers to the package by so
+// far: its alias, if it was given one, or else its package name.
+func (n *importNames) current(importPath, pkgName string) string {
+	if n == nil {
+		return pkgName
+	}
+	if name, ok := n.byPath[importPath]; ok {
+		return name
+	}
+	return pkgName
+}
+
+// reserve keeps the name for an identifier of the generated code (e.g. a
+// param of -params), aliasing the packages of the name instead.
+func (n *importNames) reserve(name string) {
+	n.paths[name] = ""
+}
+
+// alias returns a free name for the package, prefixed by the parent
+// directory of its import path (e.g. dbmodels for db/models), or suffixed
+// by its last element if that is not its name (e.g. userv2 for user/v2),
+// or else numbered (e.g. models2).
+func (n *importNames) alias(importPath, pkgName string) string {
+	dir, last := path.Split(importPath)
+	candidate := identifier(path.Base(dir)) + pkgName
+	if last != pkgName {
+		candidate = pkgName + identifier(last)
+	}
+	if _, ok := n.paths[candidate]; !ok && candidate != pkgName && token.IsIdentifier(candidate) {
+		return candidate
+	}
+	for i := 2; ; i++ {
+		candidate = pkgName + strconv.Itoa(i)
+		if _, ok := n.paths[candidate]; !ok {
+			return candidate
+		}
+	}
+}
+
+// identifier returns the lower-case letters and digits of the path
+// element (e.g. apiv1 for api-v1).
+func identifier(element string) string {
+	var b strings.Builder
+	for _, r := range element {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// importName returns the alias of the import of the path, or "" if the
+// code refers to the package by its package name.
+func (n *importNames) importName(importPath string) string {
+	if n == nil {
+		return ""
+	}
+	if _, ok := n.names[importPath]; !ok {
+		return ""
+	}
+	return n.byPath[importPath]
+}
+
+// sortedPaths returns the import paths named so far, sorted.
+func (n *importNames) sortedPaths() []string {
+	if n == nil {
+		return nil
+	}
+	var paths []string
+	for importPath := range n.byPath {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,304 @@
+// Package analyzer reports the converters generated by repacker that have
+// drifted from their types: dst fields added since the generation, which
+// the converter neither sets nor notes as skipped or ignored, fields read
+// or set that no longer exist, and skipped dst fields that a src field of
+// their name would now map.
+//
+// It only reads the generated files, so it runs in go vet (see
+// cmd/repackervet) and any driver of golang.org/x/tools/go/analysis.
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer reports the drift of the converters generated by repacker.
+var Analyzer = &analysis.Analyzer{
+	Name: "repacker",
+	Doc:  "report converters generated by repacker that no longer cover the fields of their types; regenerate them",
+	Run:  run,
+	// The removed fields are type errors of the generated code.
+	RunDespiteErrors: true,
+}
+
+// generatedMark starts the comment of the files generated by repacker.
+const generatedMark = `// Code generated by "repacker`
+
+// noteRe matches the comments of the fields a converter leaves unset, e.g.
+// "TODO(repacker): field Note skipped: no src field".
+var noteRe = regexp.MustCompile(`^(?:TODO\(repacker\)|repacker): field (\w+) (skipped|ignored)(?:: (.*))?$`)
+
+// docRe matches the doc comments of the converters of structs, rather than
+// those of collections or the diff functions.
+var docRe = regexp.MustCompile(`^\w+ (creates \*?[\w.]+(\[.*\])? from |sets the fields of )`)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		if !isGenerated(f) {
+			continue
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil || fn.Doc == nil || !docRe.MatchString(fn.Doc.Text()) {
+				continue
+			}
+			checkConverter(pass, f, fn)
+		}
+	}
+	return nil, nil
+}
+
+// isGenerated reports whether repacker generated the file, marked so before
+// its package clause.
+func isGenerated(f *ast.File) bool {
+	for _, group := range f.Comments {
+		if group.Pos() > f.Package {
+			break
+		}
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, generatedMark) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkConverter reports the drift of the converter fn. Its dst is the
+// struct of its d receiver or parameter, or else of its result, and its
+// srcs the structs of its other parameters.
+func checkConverter(pass *analysis.Pass, f *ast.File, fn *ast.FuncDecl) {
+	obj, ok := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+	if !ok {
+		return
+	}
+	sig := obj.Type().(*types.Signature)
+	var dst *types.Named
+	var srcs []*types.Named
+	for _, v := range params(sig) {
+		named := structOf(v.Type())
+		switch {
+		case named == nil:
+		case v.Name() == "d":
+			dst = named
+		default:
+			srcs = append(srcs, named)
+		}
+	}
+	if dst == nil && sig.Results().Len() > 0 {
+		dst = structOf(sig.Results().At(0).Type())
+	}
+	if dst == nil || len(srcs) == 0 {
+		// Not a converter of structs, e.g. one of -anymap.
+		return
+	}
+	fields := dst.Underlying().(*types.Struct)
+	covered := map[string]bool{}
+	reported := map[string]bool{}
+	name := fn.Name.Name
+	if recv := sig.Recv(); recv != nil && structOf(recv.Type()) != nil {
+		name = structOf(recv.Type()).Obj().Name() + "." + name
+	}
+	report := func(node ast.Node, format string, args ...interface{}) {
+		msg := name + ": " + fmt.Sprintf(format, args...)
+		if !reported[msg] {
+			reported[msg] = true
+			pass.Reportf(node.Pos(), "%s", msg)
+		}
+	}
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CompositeLit:
+			if lit := structOf(pass.TypesInfo.TypeOf(n)); lit == nil || !types.Identical(lit, dst) {
+				return true
+			}
+			for _, elt := range n.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if key, ok := kv.Key.(*ast.Ident); ok {
+					covered[key.Name] = true
+					if !hasField(fields, key.Name) {
+						report(key, "sets %s.%s, which no longer exists; regenerate it", typeName(pass, dst), key.Name)
+					}
+				}
+			}
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if name := dstField(lhs); name != "" {
+					covered[name] = true
+				}
+			}
+		case *ast.CallExpr:
+			// The setters of the unexported fields of other packages.
+			if sel, ok := n.Fun.(*ast.SelectorExpr); ok && isIdent(sel.X, "d") && strings.HasPrefix(sel.Sel.Name, "Set") {
+				for i := 0; i < fields.NumFields(); i++ {
+					if strings.EqualFold("Set"+fields.Field(i).Name(), sel.Sel.Name) {
+						covered[fields.Field(i).Name()] = true
+					}
+				}
+			}
+		case *ast.SelectorExpr:
+			if _, ok := pass.TypesInfo.Selections[n]; ok {
+				return true
+			}
+			if t := pass.TypesInfo.TypeOf(n.X); t != nil && structOf(t) != nil {
+				if obj, _, _ := types.LookupFieldOrMethod(t, true, pass.Pkg, n.Sel.Name); obj == nil {
+					report(n.Sel, "reads %s.%s, which no longer exists; regenerate it", typeName(pass, structOf(t)), n.Sel.Name)
+				}
+			}
+		}
+		return true
+	})
+	for _, group := range f.Comments {
+		if group.Pos() < fn.Body.Lbrace || group.End() > fn.Body.Rbrace {
+			continue
+		}
+		for _, c := range group.List {
+			m := noteRe.FindStringSubmatch(strings.TrimSpace(strings.TrimPrefix(c.Text, "//")))
+			if m == nil {
+				continue
+			}
+			covered[m[1]] = true
+			if m[2] != "skipped" || m[3] != "no src field" {
+				continue
+			}
+			for _, src := range srcs {
+				if srcField(src, m[1], pass.Pkg) {
+					report(c, "%s.%s was skipped for want of a src field, but %s.%s now exists; regenerate it",
+						typeName(pass, dst), m[1], typeName(pass, src), m[1])
+					break
+				}
+			}
+		}
+	}
+	for i := 0; i < fields.NumFields(); i++ {
+		field := fields.Field(i)
+		if covered[field.Name()] || field.Name() == "_" {
+			continue
+		}
+		// Unexported fields of other packages cannot be set without setters.
+		if !field.Exported() && dst.Obj().Pkg() != pass.Pkg {
+			continue
+		}
+		report(fn.Name, "does not set %s.%s, added since it was generated; regenerate it", typeName(pass, dst), field.Name())
+	}
+}
+
+// params returns the receiver and the parameters of sig.
+func params(sig *types.Signature) []*types.Var {
+	var vars []*types.Var
+	if sig.Recv() != nil {
+		vars = append(vars, sig.Recv())
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		vars = append(vars, sig.Params().At(i))
+	}
+	return vars
+}
+
+// structOf returns the named struct of t or of the pointer t, if any.
+func structOf(t types.Type) *types.Named {
+	if t == nil {
+		return nil
+	}
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return nil
+	}
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		return nil
+	}
+	return named
+}
+
+// srcField reports whether the src struct has a field of the name that
+// repacker would read: exported, or of the package pkg, and not tagged "-".
+func srcField(src *types.Named, name string, pkg *types.Package) bool {
+	s := src.Underlying().(*types.Struct)
+	for i := 0; i < s.NumFields(); i++ {
+		if s.Field(i).Name() != name || (!s.Field(i).Exported() && src.Obj().Pkg() != pkg) {
+			continue
+		}
+		tag := reflect.StructTag(s.Tag(i))
+		for _, key := range tagKeys(s.Tag(i)) {
+			if value := tag.Get(key); value == "-" || strings.HasPrefix(value, "-,") || strings.HasPrefix(value, "-:") {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// tagKeys returns the keys of the struct tag (e.g. json and db).
+func tagKeys(tag string) []string {
+	var keys []string
+	for _, m := range tagKeyRe.FindAllStringSubmatch(tag, -1) {
+		keys = append(keys, m[1])
+	}
+	return keys
+}
+
+// tagKeyRe matches the keys of a struct tag.
+var tagKeyRe = regexp.MustCompile(`(\w+):"`)
+
+// dstField returns the field of d that the assignment to lhs sets, or "",
+// e.g. Base of d.Base.ID or Tags of d.Tags[k].
+func dstField(lhs ast.Expr) string {
+	for {
+		switch e := lhs.(type) {
+		case *ast.SelectorExpr:
+			if isIdent(e.X, "d") {
+				return e.Sel.Name
+			}
+			lhs = e.X
+		case *ast.IndexExpr:
+			lhs = e.X
+		case *ast.StarExpr:
+			lhs = e.X
+		case *ast.ParenExpr:
+			lhs = e.X
+		default:
+			return ""
+		}
+	}
+}
+
+// typeName returns the name of the type in the messages, qualified by
+// its package name outside the package of the pass (e.g. src.User).
+func typeName(pass *analysis.Pass, named *types.Named) string {
+	return types.TypeString(named, func(p *types.Package) string {
+		if p == pass.Pkg {
+			return ""
+		}
+		return p.Name()
+	})
+}
+
+// hasField reports whether the struct has a field of the name.
+func hasField(s *types.Struct, name string) bool {
+	for i := 0; i < s.NumFields(); i++ {
+		if s.Field(i).Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdent reports whether expr is the identifier of the name.
+func isIdent(expr ast.Expr, name string) bool {
+	id, ok := expr.(*ast.Ident)
+	return ok && id.Name == name
+}
@@ -0,0 +1,14 @@
+package analyzer
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+// TestAnalyzer checks the drift reported in the converters generated into
+// testdata/src/api, and that neither the converters covering their fields
+// nor those written by hand are reported.
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "api")
+}
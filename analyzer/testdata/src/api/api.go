@@ -0,0 +1,16 @@
+package api
+
+import "model"
+
+type User struct {
+	ID    int
+	Name  string
+	Note  string
+	Email string
+}
+
+// NewUserFromModelUserByHand creates *User from *model.User, written by
+// hand, so not checked.
+func NewUserFromModelUserByHand(s *model.User) *User {
+	return &User{ID: s.ID}
+}
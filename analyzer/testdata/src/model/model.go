@@ -0,0 +1,7 @@
+package model
+
+type User struct {
+	ID   int
+	Name string
+	Note string
+}
@@ -0,0 +1,216 @@
+package repacker
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// generateAnyMap generates, for AnyMap, the constructor of dst from a
+// map[string]interface{} of its fields by key, the tag or else the field
+// name, asserting the type of each value, and the reverse one flattening
+// dst into such a map. The fields of embedded structs are flattened, and
+// those of other struct types convert from and to nested maps.
+func (g *Generator) generateAnyMap(dstType Type) (fromMap, toMap string, err error) {
+	dstPkg, err := g.parsePackageDir(dstType.dir)
+	if err != nil {
+		return "", "", err
+	}
+	dstObj, err := g.lookup(dstPkg, dstType)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "Lookup: %s.%s", dstPkg.name, dstType.name)
+	}
+	if !isStruct(dstObj.Type()) {
+		return "", "", errors.Errorf("%s must be a struct", dstObj.Name())
+	}
+	dst := Object{
+		pkg:    dstPkg,
+		typ:    dstType,
+		object: dstObj,
+		local:  dstPkg.dir == g.dir,
+	}
+	if dst.typeParams() != "" {
+		return "", "", errors.Errorf("-anymap cannot convert generic type %s", dstObj.Name())
+	}
+	name := dst.funcName()
+	if !dst.local {
+		name = strings.Title(dst.pkgName()) + name
+	}
+	fromMap, toMap = fmt.Sprintf("New%sFromMap", name), "NewMapFrom"+name
+	if g.funcNames[fromMap] {
+		return fromMap, toMap, nil
+	}
+	g.funcNames[fromMap], g.funcNames[toMap] = true, true
+
+	var from, to bytes.Buffer
+	for _, f := range structFields(dstObj.Type().Underlying().(*types.Struct)) {
+		if _, ok := f.Type().Underlying().(*types.Struct); ok && f.Anonymous() {
+			// Flattened into its promoted fields.
+			continue
+		}
+		if !f.Exported() && !dst.local {
+			g.logger.printf(levelInfo, "%s: %s ignored: unexported field of another package", fromMap, f.path)
+			continue
+		}
+		key := f.Name()
+		if tag, ok := g.lookupTag(f.tag); tag == "-" {
+			g.logger.printf(levelInfo, "%s: %s ignored: tagged %s:\"-\"", fromMap, f.path, g.tagKey)
+			continue
+		} else if ok && tag != "" {
+			key = tag
+		}
+		value := "s." + f.path
+		if isNestedStruct(f.Type()) {
+			nestedType, err := g.parseType(f.Type(), dstPkg)
+			if err != nil {
+				return "", "", errors.Wrapf(err, "%s.%s", dstObj.Name(), f.path)
+			}
+			_, nestedTo, err := g.generateAnyMap(nestedType)
+			if err != nil {
+				return "", "", errors.Wrapf(err, "%s.%s", dstObj.Name(), f.path)
+			}
+			value = fmt.Sprintf("%s(&s.%s)", nestedTo, f.path)
+			if _, ok := f.Type().(*types.Pointer); ok {
+				value = fmt.Sprintf("%s(s.%s)", nestedTo, f.path)
+			}
+		}
+		fmt.Fprintf(&from, "	if v, ok := m[%q]; ok {\n", key)
+		set := func(expr string) string { return fmt.Sprintf("d.%s = %s\n", f.path, expr) }
+		if err := g.anyCode(&from, f.Type(), set, strings.ReplaceAll(key, "%", "%%"), nil, 1, dst); err != nil {
+			return "", "", errors.Wrapf(err, "%s.%s", dstObj.Name(), f.path)
+		}
+		fmt.Fprintf(&from, "	}\n")
+		fmt.Fprintf(&to, "		%q: %s,\n", key, value)
+		g.logger.printf(levelInfo, "%s: %s mapped from key %s", fromMap, f.path, key)
+	}
+
+	var code bytes.Buffer
+	fmt.Fprintf(&code, "\n// %s creates %s from the values of m by key\n", fromMap, dst.FullName())
+	fmt.Fprintf(&code, "func %s(m map[string]interface{}) (%s, error) {\n", fromMap, dst.FullName())
+	fmt.Fprintf(&code, "	if m == nil {\n		return nil, nil\n	}\n")
+	fmt.Fprintf(&code, "	d := %s{}\n", dst.PtrName())
+	code.Write(from.Bytes())
+	fmt.Fprintf(&code, "	return d, nil\n}\n")
+	fmt.Fprintf(&code, "\n// %s creates a map of the fields of %s by key\n", toMap, dst.FullName())
+	fmt.Fprintf(&code, "func %s(s %s) map[string]interface{} {\n", toMap, dst.FullName())
+	fmt.Fprintf(&code, "	if s == nil {\n		return nil\n	}\n")
+	fmt.Fprintf(&code, "	return map[string]interface{}{\n")
+	code.Write(to.Bytes())
+	fmt.Fprintf(&code, "	}\n}\n")
+	g.buf.Write(code.Bytes())
+	return fromMap, toMap, nil
+}
+
+// anyCode writes the type switch setting a value of t from the interface{}
+// value v, of t or of the types JSON decodes into: float64 for numbers,
+// []interface{} for slices and map[string]interface{} for maps and nested
+// structs, converted element by element. set returns the code setting the
+// value to an expression of t, and the errors name the value by path and
+// args.
+func (g *Generator) anyCode(code *bytes.Buffer, t types.Type, set func(string) string, path string, args []string, depth int, dst Object) error {
+	fmt.Fprintf(code, "	switch v := v.(type) {\n	case nil:\n")
+	if err := g.anyCases(code, t, set, path, args, depth, dst); err != nil {
+		return err
+	}
+	fmt.Fprintf(code, "	default:\n		return nil, fmt.Errorf(%s, %s)\n	}\n",
+		strconv.Quote(path+": %T is not "+types.TypeString(t, dst.qualifier)), strings.Join(append(args, "v"), ", "))
+	return nil
+}
+
+// anyCases writes the cases of the type switch of anyCode setting a value
+// of t.
+func (g *Generator) anyCases(code *bytes.Buffer, t types.Type, set func(string) string, path string, args []string, depth int, dst Object) error {
+	typeName := types.TypeString(t, dst.qualifier)
+	suffix := ""
+	if depth > 1 {
+		suffix = strconv.Itoa(depth)
+	}
+	fmt.Fprintf(code, "	case %s:\n%s", typeName, set("v"))
+	if isNestedStruct(t) {
+		nestedType, err := g.parseType(t, dst.pkg)
+		if err != nil {
+			return err
+		}
+		nestedFrom, _, err := g.generateAnyMap(nestedType)
+		if err != nil {
+			return err
+		}
+		if importPath := elemNamed(t).Obj().Pkg().Path(); importPath != dst.pkg.path {
+			g.imports = append(g.imports, importPath)
+		}
+		nested := "nested" + suffix
+		fmt.Fprintf(code, "	case map[string]interface{}:\n		%s, err := %s(v)\n", nested, nestedFrom)
+		fmt.Fprintf(code, "		if err != nil {\n			return nil, fmt.Errorf(%s, %s)\n		}\n",
+			strconv.Quote(path+": %w"), strings.Join(append(args, "err"), ", "))
+		if _, ok := t.(*types.Pointer); !ok {
+			nested = "*" + nested
+		}
+		code.WriteString(set(nested))
+		return nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsInteger != 0:
+			fmt.Fprintf(code, "	case float64:\n		if v != float64(%s(v)) {\n", typeName)
+			fmt.Fprintf(code, "			return nil, fmt.Errorf(%s, %s)\n		}\n",
+				strconv.Quote(path+": %v is not "+typeName), strings.Join(append(args, "v"), ", "))
+			code.WriteString(set(typeName + "(v)"))
+		case u.Info()&types.IsFloat != 0 && typeName != "float64":
+			fmt.Fprintf(code, "	case float64:\n%s", set(typeName+"(v)"))
+		}
+	case *types.Pointer:
+		// The other types point to a variable of their element.
+		ptr := "p" + suffix
+		return g.anyCases(code, u.Elem(), func(expr string) string {
+			return fmt.Sprintf("		%s := %s\n", ptr, expr) + set("&"+ptr)
+		}, path, args, depth+1, dst)
+	case *types.Slice:
+		if types.Identical(t, types.NewSlice(types.NewInterfaceType(nil, nil))) {
+			return nil
+		}
+		items, index := "items"+suffix, "i"+suffix
+		fmt.Fprintf(code, "	case []interface{}:\n		%s := make(%s, len(v))\n		for %s, v := range v {\n", items, typeName, index)
+		err := g.anyCode(code, u.Elem(), func(expr string) string {
+			return fmt.Sprintf("		%s[%s] = %s\n", items, index, expr)
+		}, path+"[%d]", append(args[:len(args):len(args)], index), depth+1, dst)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(code, "		}\n%s", set(items))
+	case *types.Map:
+		key, ok := u.Key().Underlying().(*types.Basic)
+		if !ok || key.Kind() != types.String || types.Identical(t, types.NewMap(types.Typ[types.String], types.NewInterfaceType(nil, nil))) {
+			return nil
+		}
+		entries, k := "entries"+suffix, "k"+suffix
+		keyCode := k
+		if keyName := types.TypeString(u.Key(), dst.qualifier); keyName != "string" {
+			keyCode = conversionCode(keyName, k)
+		}
+		fmt.Fprintf(code, "	case map[string]interface{}:\n		%s := make(%s, len(v))\n		for %s, v := range v {\n", entries, typeName, k)
+		err := g.anyCode(code, u.Elem(), func(expr string) string {
+			return fmt.Sprintf("		%s[%s] = %s\n", entries, keyCode, expr)
+		}, path+"[%q]", append(args[:len(args):len(args)], k), depth+1, dst)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(code, "		}\n%s", set(entries))
+	}
+	return nil
+}
+
+// isNestedStruct reports whether t is a named struct or a pointer to one,
+// other than time.Time, converted from and to a nested map by AnyMap, and
+// from and to JSON by unmarshalsJSON and marshalsJSON.
+func isNestedStruct(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && isStruct(named) && !isTime(named) && named.TypeArgs().Len() == 0 && named.Obj().Pkg() != nil
+}
@@ -0,0 +1,200 @@
+package repacker
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// decimalPath is the import path of the package of decimal.Decimal.
+const decimalPath = "github.com/shopspring/decimal"
+
+// bigNumber returns the name of the arbitrary-precision number type t is,
+// big.Int or big.Rat for pointers to them, or decimal.Decimal, or "" if t
+// is none of them.
+func bigNumber(t types.Type) string {
+	pointer := false
+	if p, ok := t.(*types.Pointer); ok {
+		t, pointer = p.Elem(), true
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return ""
+	}
+	switch path, name := named.Obj().Pkg().Path(), named.Obj().Name(); {
+	case path == "math/big" && pointer && (name == "Int" || name == "Rat"):
+		return "big." + name
+	case path == decimalPath && !pointer && name == "Decimal":
+		return "decimal.Decimal"
+	}
+	return ""
+}
+
+// isBigNumber reports whether t is *big.Int, *big.Rat or decimal.Decimal.
+func isBigNumber(t types.Type) bool {
+	return bigNumber(t) != ""
+}
+
+// isBasicKind reports whether the underlying type of t is the basic type
+// of the kind.
+func isBasicKind(t types.Type, kind types.BasicKind) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Kind() == kind
+}
+
+// parseScale parses the scale option of a field, the number of digits
+// after the decimal point, or returns -1 if there is none.
+func (g *Generator) parseScale(dstTag, srcTag string) (int, error) {
+	value, ok := g.fieldOption(dstTag, srcTag, "scale")
+	if !ok {
+		return -1, nil
+	}
+	scale, err := strconv.Atoi(value)
+	if err != nil || scale < 0 || scale > 18 {
+		return 0, errors.Errorf("scale option %s; use 0 to 18 digits", value)
+	}
+	return scale, nil
+}
+
+// bigNumberCode returns the code converting expr of an arbitrary-precision
+// number type into a string or an int64, or back, writing the variables it
+// needs with the name variable, and whether it parses a string, or "" if
+// neither type is one. The scale option of the tags formats a string with
+// as many digits after the point, and counts an int64 in units of as many
+// decimal places (e.g. cents for 2). A nil *big.Int or *big.Rat converts
+// into the zero value with deref, and the empty string into a nil one or
+// the zero decimal.Decimal. skipped is why the field is skipped instead,
+// if so.
+func (g *Generator) bigNumberCode(src, dst types.Type, dstTag, srcTag, expr, variable, errResult, fieldName string,
+	variables *bytes.Buffer, deref func(variable, typeName, pointer, expr string) string, qualifier types.Qualifier) (code string, parses bool, skipped string, err error) {
+	srcKind, dstKind := bigNumber(src), bigNumber(dst)
+	switch {
+	case srcKind != "" && dstKind == "" && (isBasicKind(dst, types.String) || isBasicKind(dst, types.Int64)):
+	case dstKind != "" && srcKind == "" && (isBasicKind(src, types.String) || isBasicKind(src, types.Int64)):
+	default:
+		return "", false, "", nil
+	}
+	scale, err := g.parseScale(dstTag, srcTag)
+	if err != nil {
+		return "", false, "", err
+	}
+	if (srcKind == "big.Int" || dstKind == "big.Int") && scale > 0 {
+		return "", false, "", errors.Errorf("scale option %d of an integer", scale)
+	}
+	// big and dec name the package of the number type in the code.
+	var big, dec string
+	if srcKind == "decimal.Decimal" || dstKind == "decimal.Decimal" {
+		dec = g.names.name(decimalPath, "decimal")
+		g.imports = append(g.imports, decimalPath)
+	} else {
+		big = g.names.name("math/big", "big")
+		g.imports = append(g.imports, "math/big")
+	}
+	// unit is 10 to the scale, the int64 of one.
+	unit := "1"
+	if scale > 0 {
+		unit += strings.Repeat("0", scale)
+	}
+	typeName := types.TypeString(dst, qualifier)
+	// Named types of the strings and int64s are converted.
+	named := !types.Identical(dst.Underlying(), dst)
+
+	if srcKind != "" && isBasicKind(dst, types.String) {
+		var text string
+		switch {
+		case srcKind == "big.Int":
+			text = expr + ".String()"
+		case srcKind == "big.Rat" && scale >= 0:
+			text = fmt.Sprintf("%s.FloatString(%d)", expr, scale)
+		case srcKind == "big.Rat":
+			text = expr + ".RatString()"
+		case scale >= 0:
+			text = fmt.Sprintf("%s.StringFixed(%d)", expr, scale)
+		default:
+			text = expr + ".String()"
+		}
+		if named {
+			text = conversionCode(typeName, text)
+		}
+		if srcKind == "decimal.Decimal" {
+			return text, false, "", nil
+		}
+		// A nil number leaves the empty string, rather than "<nil>".
+		return deref(variable, typeName, expr, text), false, "", nil
+	}
+
+	if srcKind != "" {
+		// An int64 truncates the fraction, and may overflow.
+		if g.strict {
+			return "", false, "due to narrowing conversion", nil
+		}
+		var number string
+		switch {
+		case srcKind == "big.Int":
+			number = expr + ".Int64()"
+		case srcKind == "big.Rat" && scale > 0:
+			number = fmt.Sprintf("new(%s.Int).Quo(new(%s.Int).Mul(%s.Num(), %s.NewInt(%s)), %s.Denom()).Int64()", big, big, expr, big, unit, expr)
+		case srcKind == "big.Rat":
+			number = fmt.Sprintf("new(%s.Int).Quo(%s.Num(), %s.Denom()).Int64()", big, expr, expr)
+		case scale > 0:
+			number = fmt.Sprintf("%s.Shift(%d).IntPart()", expr, scale)
+		default:
+			number = expr + ".IntPart()"
+		}
+		if named {
+			number = conversionCode(typeName, number)
+		}
+		if srcKind == "decimal.Decimal" {
+			return number, false, "", nil
+		}
+		return deref(variable, typeName, expr, number), false, "", nil
+	}
+
+	if isBasicKind(src, types.Int64) {
+		value := expr
+		if !types.Identical(src.Underlying(), src) {
+			value = fmt.Sprintf("int64(%s)", expr)
+		}
+		switch {
+		case dstKind == "big.Int":
+			return fmt.Sprintf("%s.NewInt(%s)", big, value), false, "", nil
+		case dstKind == "big.Rat" && scale > 0:
+			return fmt.Sprintf("%s.NewRat(%s, %s)", big, value, unit), false, "", nil
+		case dstKind == "big.Rat":
+			return fmt.Sprintf("new(%s.Rat).SetInt64(%s)", big, value), false, "", nil
+		case scale > 0:
+			return fmt.Sprintf("%s.New(%s, -%d)", dec, value, scale), false, "", nil
+		default:
+			return fmt.Sprintf("%s.NewFromInt(%s)", dec, value), false, "", nil
+		}
+	}
+
+	// Strings are parsed, failing on malformed numbers.
+	if !g.withError {
+		return "", false, "due to fallible conversion; use -witherror", nil
+	}
+	text := expr
+	if !types.Identical(src.Underlying(), src) {
+		text = fmt.Sprintf("string(%s)", expr)
+	}
+	fmt.Fprintf(variables, "	var %s %s\n", variable, typeName)
+	fmt.Fprintf(variables, "	if %s != \"\" {\n", expr)
+	switch dstKind {
+	case "big.Int":
+		fmt.Fprintf(variables, "		v, ok := new(%s.Int).SetString(%s, 10)\n", big, text)
+	case "big.Rat":
+		fmt.Fprintf(variables, "		v, ok := new(%s.Rat).SetString(%s)\n", big, text)
+	default:
+		fmt.Fprintf(variables, "		v, err := %s.NewFromString(%s)\n", dec, text)
+		fmt.Fprint(variables, errorCheck(errResult, fieldName))
+	}
+	if dstKind != "decimal.Decimal" {
+		fmt.Fprintf(variables, "		if !ok {\n			return %sfmt.Errorf(\"%s: invalid number %%q\", %s)\n		}\n", errResult, fieldName, expr)
+	}
+	fmt.Fprintf(variables, "		%s = v\n	}\n", variable)
+	return variable, true, "", nil
+}
@@ -0,0 +1,210 @@
+package repacker
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/gcexportdata"
+	"golang.org/x/tools/go/packages"
+)
+
+// cacheVersion is bumped whenever the format of the cached packages
+// changes, invalidating them.
+const cacheVersion = "2"
+
+var (
+	goEnvOnce sync.Once
+	goRoot    string
+	goVersion string
+	goEnvErr  error
+)
+
+// goEnv returns the GOROOT and GOVERSION of the go command, which load
+// the packages.
+func goEnv() (string, string, error) {
+	goEnvOnce.Do(func() {
+		out, err := exec.Command("go", "env", "GOROOT", "GOVERSION").Output()
+		if err != nil {
+			goEnvErr = errors.Wrapf(err, "go env: %s", err)
+			return
+		}
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if len(lines) != 2 {
+			goEnvErr = errors.Errorf("go env: unexpected output %q", out)
+			return
+		}
+		goRoot, goVersion = lines[0], lines[1]
+	})
+	return goRoot, goVersion, goEnvErr
+}
+
+// cacheKey returns the key of the package of cfg in the on-disk cache: a
+// hash of the files of the package and of its dependencies outside the
+// standard library, which the go version stands for. Listing them loads
+// no types, so it is far cheaper than type-checking the package.
+func cacheKey(cfg *packages.Config) (string, error) {
+	root, version, err := goEnv()
+	if err != nil {
+		return "", err
+	}
+	listCfg := *cfg
+	listCfg.Mode = packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps
+	loading.RLock()
+	pkgs, err := packages.Load(&listCfg, ".")
+	loading.RUnlock()
+	if err != nil {
+		return "", err
+	}
+	pkg := selectPackage(pkgs)
+	if pkg == nil {
+		return "", errors.New("no package")
+	}
+
+	deps := map[string]*packages.Package{}
+	var visit func(p *packages.Package)
+	visit = func(p *packages.Package) {
+		if deps[p.ID] != nil {
+			return
+		}
+		deps[p.ID] = p
+		for _, imp := range p.Imports {
+			visit(imp)
+		}
+	}
+	visit(pkg)
+	ids := make([]string, 0, len(deps))
+	for id := range deps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "repacker %s\n%s\n%v %s\n", cacheVersion, version, cfg.Tests, strings.Join(cfg.BuildFlags, " "))
+	for _, id := range ids {
+		fmt.Fprintf(h, "package %s\n", id)
+		for _, name := range deps[id].GoFiles {
+			if strings.HasPrefix(name, root+string(filepath.Separator)) {
+				// A standard package, which changes with the go version.
+				break
+			}
+			data, ok := cfg.Overlay[name]
+			if !ok {
+				if data, err = ioutil.ReadFile(name); err != nil {
+					return "", errors.WithStack(err)
+				}
+			}
+			fmt.Fprintf(h, "file %s %d\n", name, len(data))
+			h.Write(data)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readCachedPackage returns the package of key in the cache directory,
+// or nil if it is not there.
+func readCachedPackage(cacheDir, key, directory string) *Package {
+	f, err := os.Open(filepath.Join(cacheDir, key))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	path, err := r.ReadString('\n')
+	if err != nil {
+		return nil
+	}
+	path = strings.TrimSuffix(path, "\n")
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil
+	}
+	var docs map[string]string
+	if err := json.Unmarshal(line, &docs); err != nil {
+		return nil
+	}
+	pkgs, err := gcexportdata.ReadBundle(r, token.NewFileSet(), map[string]*types.Package{})
+	if err != nil || len(pkgs) == 0 || pkgs[0].Path() != path {
+		return nil
+	}
+	pkg := pkgs[0]
+	return &Package{
+		dir:   directory,
+		path:  pkg.Path(),
+		name:  pkg.Name(),
+		types: pkg,
+		docs:  docs,
+	}
+}
+
+// writeCachedPackage writes p to the cache directory as key, bundled with
+// its imports, whose parsers and methods are looked up in their scopes.
+// The export data only has the exported names of p, so a package
+// declaring others is not written, as they may be converters or the srcs
+// and dsts of -method. Nor is one declaring types in _test.go files,
+// whose files are not recorded.
+func writeCachedPackage(cacheDir, key string, p *Package, fset *token.FileSet) error {
+	if len(p.testTypes) > 0 {
+		return nil
+	}
+	for _, name := range p.types.Scope().Names() {
+		if !token.IsExported(name) {
+			return nil
+		}
+	}
+	bundle := []*types.Package{p.types}
+	seen := map[*types.Package]bool{p.types: true}
+	for i := 0; i < len(bundle); i++ {
+		for _, imp := range bundle[i].Imports() {
+			// unsafe is not exported, but known to every importer.
+			if !seen[imp] && imp != types.Unsafe {
+				seen[imp] = true
+				bundle = append(bundle, imp)
+			}
+		}
+	}
+	docs, err := json.Marshal(p.docs)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(p.path + "\n")
+	buf.Write(append(docs, '\n'))
+	if err := gcexportdata.WriteBundle(&buf, fset, bundle); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	// Other runs may read the file while it is written.
+	f, err := ioutil.TempFile(cacheDir, key+".*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = io.Copy(f, &buf)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(cacheDir, key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return errors.WithStack(err)
+	}
+	return nil
+}
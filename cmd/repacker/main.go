@@ -0,0 +1,624 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/knqyf263/repacker"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	src           = flag.String("src", "", "comma-separated list of type names, with +-joined types merged into one dst (e.g. pkg.User+pkg.Profile), or wildcards of exported structs (e.g. pkg.Model* or pkg.all); must be set")
+	dst           = flag.String("dst", "", "comma-separated list of type names, or formats of the dsts of wildcards (e.g. %sDTO); must be set, unless run by go generate above the dst type")
+	srcDir        = flag.String("srcdir", "", "directory of the src types named without an import path, relative to the module root unless ./ or ../; default the directory of the dst types")
+	withError     = flag.Bool("witherror", false, "generate constructors that also return an error for fallible conversions")
+	stdout        = flag.Bool("stdout", false, "write generated code to standard output instead of a file")
+	output        = flag.String("output", "", "output file name; default <directory or -outdir>/<first dst>_repack.go")
+	layout        = flag.String("layout", "dst", "output files: dst for one named after the first dst, package for one repack_gen.go, or pair for one per pair named after its dst")
+	filename      = flag.String("filename", "", "template of the output file names, of the lower-case {{.Dst}} (e.g. {{.Dst}}_conv.go); default {{.Dst}}_repack.go, or repack_gen.go with -layout package")
+	outDir        = flag.String("outdir", "", "directory of the package of the generated code, if other than the directory of the dst types, which are then qualified")
+	outPkg        = flag.String("outpkg", "", "package name of the generated code; default that of -outdir, or its base name if it has no Go files")
+	o             = flag.String("o", "", "output file name as -output, or - for standard output as -stdout")
+	tagKey        = flag.String("tag", "repack", "struct tag key used to match fields (e.g. json)")
+	matchTags     = flag.String("matchtags", "", "comma-separated list of other tag keys matching fields with the same tag (e.g. json,db), after -tag")
+	fuzzy         = flag.Bool("fuzzy", false, "match field names case-insensitively, ignoring underscores; same as -match=normalized")
+	match         = flag.String("match", "exact", "strategy matching field names without an exact match: exact, case-insensitive, or normalized ignoring case and underscores")
+	allowEmpty    = flag.Bool("allowempty", false, "only warn, instead of failing, when no dst field of a pair is mapped from its src, which usually means the wrong types")
+	strict        = flag.Bool("strict", false, "fail if a dst field is not mapped from any src field, refusing narrowing numeric conversions")
+	tags          = flag.String("tags", "", "comma-separated list of build tags to apply, in addition to those of -tags in GOFLAGS")
+	buildTag      = flag.String("buildtag", "", "build constraint of the generated files, written as a //go:build line (e.g. linux && amd64)")
+	header        = flag.String("header", "", "file of a header (e.g. a license) written at the top of the generated files, commenting out lines that are not comments")
+	includeTests  = flag.Bool("includetests", false, "also read types from _test.go files, generating the conversions of those into <dst>_repack_test.go")
+	getters       = flag.Bool("getters", false, "also read unexported src fields of other packages through Get-prefixed getters (e.g. s.GetID() for id), besides s.ID()")
+	rules         = flag.Bool("rules", false, "also note the rule that mapped each dst field in its comment (e.g. // from Name by tag)")
+	method        = flag.Bool("method", false, "generate methods on src (e.g. s.ToFoo()) instead of functions; src and dst must be in the same package")
+	mapping       = flag.String("mapping", "", "YAML (.yaml or .yml) or JSON file of explicit field mappings per src and dst pair")
+	genTest       = flag.Bool("gentest", false, "also generate a test that every mapped dst field is set, in <output>_test.go")
+	genFuzz       = flag.Bool("genfuzz", false, "with -gentest and -witherror, also write fuzz targets of the src strings parsed by each converter (e.g. FuzzNewFooFromBarBar)")
+	deepCopy      = flag.Bool("deepcopy", false, "deep-copy the pointers, slices and maps of the fields copied as they are, instead of sharing their memory with the src; the copy option of a tag (copy=deep or copy=shared) decides for its field")
+	funcOptions   = flag.Bool("options", false, "the constructors also take functional options (e.g. NewFooFromBarBar(s, opts ...FooOption)), a FooOption being a func(*Foo) applied after the fields are mapped")
+	genBench      = flag.Bool("genbench", false, "with -gentest, also write benchmarks of each converter reporting its allocations (e.g. BenchmarkNewFooFromBarBar)")
+	watch         = flag.Bool("watch", false, "after generating, regenerate whenever a Go file of the src and dst packages changes, until interrupted")
+	check         = flag.Bool("check", false, "do not write the output file; fail with a diff if it is missing or out of date")
+	force         = flag.Bool("force", false, "overwrite existing output files even if they were not generated by repacker")
+	byValue       = flag.Bool("byvalue", false, "generate the constructors of the pairs from and to values (e.g. func(s bar.Bar) Foo) instead of pointers, with no nil checks")
+	populate      = flag.Bool("populate", false, "generate methods that fill an existing dst (e.g. d.PopulateFrom(s)) instead of constructors")
+	inPlace       = flag.Bool("inplace", false, "also generate methods that fill an existing dst (e.g. d.PopulateFrom(s)) next to the constructors, as -populate")
+	merge         = flag.String("merge", "", "also generate functions copying only the set src fields onto a dst (e.g. MergeFoo(s, d)), for PATCH requests: zero skips zero values, nil only nil pointers, slices and maps")
+	diff          = flag.Bool("diff", false, "also generate functions listing the mapped fields of a dst that differ from those converted from the src (e.g. DiffFoo(s, d)), as FieldDiffs")
+	bidirectional = flag.Bool("bidirectional", false, "also generate the reverse constructors of each src from its dst")
+	collections   = flag.Bool("collections", false, "also generate the constructors of slices and maps of each dst (e.g. NewPtrFooSliceFromPtrBarBar)")
+	anyMap        = flag.Bool("anymap", false, "also generate the constructors of each dst from a map[string]interface{} of its fields by key, and back (e.g. NewFooFromMap and NewMapFromFoo); -src may then be unset")
+	style         = flag.String("style", "function", "style of the conversions: function, or method generating d.FromBar(s) instead of constructors, and d.ToBar() with -bidirectional")
+	null          = flag.String("null", "nil", "how invalid sql.Null* values map: nil pointers, or zero values and back (zero)")
+	optional      = flag.Bool("optional", false, "convert the zero values of plain src fields into nil pointer dst fields, as unset optional fields (e.g. of OpenAPI), instead of pointers to them")
+	profile       = flag.String("profile", "", "ORM of the entities (gorm, sqlx or ent), matching fields by column and leaving out associations and bookkeeping fields")
+	generics      = flag.Bool("generics", false, "convert slices and maps with generic helpers generated once per package (e.g. repackSlice) instead of a function per type")
+	arraySlice    = flag.Bool("arrayslice", false, "also convert arrays to slices and slices to arrays element by element, up to the length of the array")
+	config        = flag.String("config", "", "YAML (.yaml or .yml) or JSON file of jobs, each generating the code for one directory with its own flags")
+	cacheDir      = flag.String("cachedir", "", "directory of an on-disk cache of the type-checked packages, keyed by the hashes of their files, to skip type-checking the unchanged ones (e.g. in CI)")
+	parallel      = flag.Int("parallel", 0, "number of -config jobs generated at a time, loading each package once; default GOMAXPROCS")
+	skipNil       = flag.Bool("skipnil", false, "with -populate, -inplace or -style=method, leave dst fields untouched when their src pointers are nil instead of setting the zero values")
+	report        = flag.String("report", "", "also write a report of how each dst field was mapped to standard output, in the format (json)")
+	verbose       = flag.Bool("v", false, "also log the decision of each dst field: mapped, ignored or skipped, and why")
+	veryVerbose   = flag.Bool("vv", false, "as -v, also logging the lookups of the types and the src fields matching each dst field")
+	quiet         = flag.Bool("q", false, "log only the errors, not the warnings (e.g. of ambiguous fields)")
+	logFormat     = flag.String("logformat", "text", "format of the logged diagnostics: text, or json with one object of the level and msg per line")
+)
+
+// maps are the field mappings of -map, which may be repeated.
+var maps stringList
+
+// params are the extra parameters of the converters of -params, which may
+// be repeated.
+var params stringList
+
+func init() {
+	flag.StringVar(tagKey, "tagkey", "repack", "same as -tag")
+	flag.BoolVar(genTest, "gentests", false, "same as -gentest")
+	flag.BoolVar(includeTests, "include-tests", false, "same as -includetests")
+	flag.Var(&maps, "map", "field mapping Src.Field=Dst.Field taking precedence over names, tags and -mapping; may be repeated")
+	flag.Var(&params, "params", "extra parameter name and type leading the parameters of every generated converter (e.g. \"ctx context.Context\"), passed on to the nested converters and to the convert funcs taking one of its type; may be repeated")
+}
+
+// stringList is a flag.Value of a repeatable flag. Each value may also
+// list several comma-separated items.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// Usage is a replacement usage function for the flags package.
+func Usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\trepacker [generate] [flags] [directory]\n")
+	fmt.Fprintf(os.Stderr, "\trepacker check [flags] [directory]\n")
+	fmt.Fprintf(os.Stderr, "\trepacker list [flags] [directory]\n")
+	fmt.Fprintf(os.Stderr, "\trepacker init [-config file] [flags] [directory]\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n    \t%s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+// commands are the subcommands, each taking the flags of generate. The
+// flags without a command generate the code, as they always did.
+var commands = []struct {
+	name  string
+	usage string
+}{
+	{"generate", "generate the code for the directory, or the jobs of -config (default)"},
+	{"check", "fail with a diff if the generated code is missing or out of date, as -check"},
+	{"list", "print the functions that would be generated and how each dst field is mapped, writing nothing"},
+	{"init", "write a -config file (default repacker.yaml) with a job of the flags and directory"},
+}
+
+// listing is set by list, so that the runs list the code instead of
+// writing it.
+var listing bool
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("repacker: ")
+	flag.Usage = Usage
+	command, args := "generate", os.Args[1:]
+	if len(args) > 0 {
+		for _, c := range commands {
+			if args[0] == c.name {
+				command, args = c.name, args[1:]
+			}
+		}
+	}
+	flag.CommandLine.Parse(args)
+
+	var err error
+	switch command {
+	case "check":
+		flag.Set("check", "true")
+	case "list":
+		listing = true
+	}
+	switch {
+	case command == "init":
+		err = initConfig(flag.Args())
+	case *config != "":
+		err = repackConfig(*config)
+	default:
+		err = repack(flag.Args(), nil, nil)
+	}
+	if err == nil && *watch {
+		err = repacker.Watch(watched...)
+	}
+	switch {
+	case err == errUsage:
+		flag.Usage()
+		os.Exit(2)
+	case err != nil && *logFormat == "json":
+		line, _ := json.Marshal(map[string]string{"level": "error", "msg": err.Error()})
+		fmt.Fprintf(os.Stderr, "%s\n", line)
+		os.Exit(1)
+	case err != nil:
+		log.Fatalf("%+v", err)
+	}
+}
+
+// watched are the options of the runs of -watch, run by repacker.Watch
+// instead of repack.
+var watched []repacker.Options
+
+// jobs are the options of the -config jobs, run together by
+// repacker.RunAll instead of repack when collecting is set.
+var (
+	jobs       []repacker.Options
+	collecting bool
+)
+
+// errUsage reports invalid flags or arguments.
+var errUsage = errors.New("usage")
+
+// repack generates the code for the directory in args, with the mappings
+// in addition to those of the -mapping file and the converters of types.
+func repack(args []string, mappings []repacker.Mapping, converters []repacker.Converter) error {
+	if *dst == "" && *config == "" && (*src != "" || *anyMap) {
+		name, err := directiveType()
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			// Each src converts to the type.
+			names := make([]string, len(strings.Split(*src, ",")))
+			for i := range names {
+				names[i] = name
+			}
+			*dst = strings.Join(names, ",")
+		}
+	}
+	if len(*src) == 0 && !*anyMap || len(*dst) == 0 {
+		return errUsage
+	}
+
+	if len(args) == 0 {
+		// Default: process whole package in current directory.
+		args = []string{"."}
+	} else if len(args) > 1 {
+		return errUsage
+	}
+
+	opts := repacker.Options{
+		Dir:           args[0],
+		Src:           *src,
+		Dst:           *dst,
+		WithError:     *withError,
+		Output:        *output,
+		Layout:        *layout,
+		Filename:      *filename,
+		OutDir:        *outDir,
+		OutPkg:        *outPkg,
+		SrcDir:        *srcDir,
+		Stdout:        *stdout,
+		TagKey:        *tagKey,
+		Fuzzy:         *fuzzy,
+		Match:         *match,
+		Strict:        *strict,
+		AllowEmpty:    *allowEmpty,
+		IncludeTests:  *includeTests,
+		BuildTag:      *buildTag,
+		Header:        *header,
+		Method:        *method,
+		Getters:       *getters,
+		Rules:         *rules,
+		Mapping:       *mapping,
+		Mappings:      mappings,
+		Maps:          maps,
+		Params:        params,
+		Converters:    converters,
+		GenTest:       *genTest,
+		GenFuzz:       *genFuzz,
+		DeepCopy:      *deepCopy,
+		FuncOptions:   *funcOptions,
+		GenBench:      *genBench,
+		Check:         *check,
+		Force:         *force,
+		List:          listing,
+		Populate:      *populate,
+		ByValue:       *byValue,
+		InPlace:       *inPlace,
+		Merge:         *merge,
+		Diff:          *diff,
+		Bidirectional: *bidirectional,
+		Collections:   *collections,
+		AnyMap:        *anyMap,
+		Null:          *null,
+		Optional:      *optional,
+		Profile:       *profile,
+		Generics:      *generics,
+		ArraySlice:    *arraySlice,
+		Style:         *style,
+		SkipNil:       *skipNil,
+		Args:          headArgs(),
+		Report:        *report,
+		Summary:       os.Getenv("GOFILE") != "",
+		CacheDir:      *cacheDir,
+		LogFormat:     *logFormat,
+	}
+	switch {
+	case *quiet && (*verbose || *veryVerbose):
+		return errors.New("-q cannot be combined with -v or -vv")
+	case *quiet:
+		opts.Verbosity = -1
+	case *veryVerbose:
+		opts.Verbosity = 2
+	case *verbose:
+		opts.Verbosity = 1
+	}
+	if *tags != "" {
+		opts.Tags = strings.Split(*tags, ",")
+	}
+	if *matchTags != "" {
+		opts.MatchTags = strings.Split(*matchTags, ",")
+	}
+	switch {
+	case *o == "":
+	case *output != "" || *stdout:
+		return errors.New("-o cannot be combined with -output or -stdout")
+	case *o == "-":
+		opts.Stdout = true
+	default:
+		opts.Output = *o
+	}
+	if *watch {
+		watched = append(watched, opts)
+		return nil
+	}
+	if collecting {
+		jobs = append(jobs, opts)
+		return nil
+	}
+	return repacker.Run(opts)
+}
+
+// directiveType returns the type declared after the //go:generate directive
+// running repacker, from $GOFILE and $GOLINE, or "" when not run by go
+// generate.
+func directiveType() (string, error) {
+	fileName, line := os.Getenv("GOFILE"), os.Getenv("GOLINE")
+	if fileName == "" || line == "" {
+		return "", nil
+	}
+	n, err := strconv.Atoi(line)
+	if err != nil {
+		return "", errors.Errorf("GOLINE: %s", line)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fileName, nil, 0)
+	if err != nil {
+		return "", errors.Wrapf(err, "%s", fileName)
+	}
+	for _, decl := range f.Decls {
+		if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.TYPE && fset.Position(decl.Pos()).Line > n {
+			return decl.Specs[0].(*ast.TypeSpec).Name.Name, nil
+		}
+	}
+	return "", errors.Errorf("%s:%d: no type declared after the go:generate directive; set -dst", fileName, n)
+}
+
+// headArgs returns the arguments of the command recorded in the generated
+// code. -check only compares the output, so it is left out, as are the
+// commands, so that repacker generate records the same as repacker.
+func headArgs() []string {
+	var args []string
+	for i, arg := range os.Args[1:] {
+		if i == 0 && (arg == "generate" || arg == "check" || arg == "list") {
+			continue
+		}
+		if name := strings.TrimLeft(arg, "-"); name == "check" || strings.HasPrefix(name, "check=") {
+			continue
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// Job is a run of repacker listed in the -config file. Paths are relative
+// to the directory of the file.
+type Job struct {
+	Dir        string                 `json:"dir,omitempty"` // default "."
+	Src        string                 `json:"src"`
+	Dst        string                 `json:"dst"`
+	Output     string                 `json:"output,omitempty"`
+	Mapping    string                 `json:"mapping,omitempty"`
+	Mappings   []repacker.Mapping     `json:"mappings,omitempty"`   // in addition to those of Mapping
+	Converters []repacker.Converter   `json:"converters,omitempty"` // in addition to those of the config
+	Flags      map[string]interface{} `json:"flags,omitempty"`      // other flags by name (e.g. "witherror": true)
+}
+
+// Config is the -config file: the jobs, and the converters of types and
+// the header file shared by all of them. A file of only the list of jobs
+// is also accepted.
+type Config struct {
+	Converters []repacker.Converter `json:"converters,omitempty"`
+	Header     string               `json:"header,omitempty"` // unless a job sets its own in flags
+	Jobs       []Job                `json:"jobs"`
+}
+
+// readConfig reads the config from the YAML file, or from the JSON file
+// of another extension.
+func readConfig(fileName string) (*Config, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if isYAML(fileName) {
+		// The YAML is decoded by the json tags of the structs.
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, errors.Wrapf(err, "%s", fileName)
+		}
+	}
+	conf := &Config{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &conf.Jobs)
+	} else {
+		err = json.Unmarshal(data, conf)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s", fileName)
+	}
+	return conf, nil
+}
+
+// isYAML reports whether the file is YAML by its extension, .yaml or .yml.
+func isYAML(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// initConfig writes the -config file, repacker.yaml by default, with a
+// job of the directory in args and of the flags of the command line, or of
+// every struct of a src package to edit if -src is not set.
+func initConfig(args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	fileName := *config
+	if fileName == "" {
+		fileName = "repacker.yaml"
+	}
+	if _, err := os.Stat(fileName); err == nil {
+		return errors.Errorf("init: %s already exists", fileName)
+	}
+	// The paths of the config are relative to its directory.
+	base, err := filepath.Abs(filepath.Dir(fileName))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	relative := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return path
+		}
+		if rel, err := filepath.Rel(base, abs); err == nil {
+			return rel
+		}
+		return abs
+	}
+	job := Job{
+		Src:     *src,
+		Dst:     *dst,
+		Output:  relative(*output),
+		Mapping: relative(*mapping),
+		Flags:   map[string]interface{}{},
+	}
+	if job.Src == "" {
+		job.Src, job.Dst = "<import path of the src package>.all", "%s"
+	}
+	if len(args) > 0 {
+		job.Dir = relative(args[0])
+	}
+	if job.Dir == "." {
+		job.Dir = ""
+	}
+	flag.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch f.Name {
+		case "src", "dst", "output", "mapping", "config", "parallel":
+		case "o":
+			if value == "-" {
+				job.Flags["stdout"] = true
+			} else {
+				job.Output = relative(value)
+			}
+		case "header":
+			job.Flags[f.Name] = relative(value)
+		case "map":
+			job.Flags[f.Name] = []string(maps)
+		case "params":
+			job.Flags[f.Name] = []string(params)
+		default:
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+				job.Flags[f.Name] = value == "true"
+			} else {
+				job.Flags[f.Name] = value
+			}
+		}
+	})
+	var data bytes.Buffer
+	if isYAML(fileName) {
+		b, err := yaml.Marshal(Config{Jobs: []Job{job}})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		data.Write(b)
+	} else {
+		enc := json.NewEncoder(&data)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(Config{Jobs: []Job{job}}); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := ioutil.WriteFile(fileName, data.Bytes(), 0644); err != nil {
+		return errors.Wrapf(err, "init: %s", err)
+	}
+	log.Printf("wrote %s; generate with repacker -config %s", fileName, fileName)
+	return nil
+}
+
+// repackConfig runs the jobs of the config file, -parallel at a time. A
+// job starts from the flags of the command line, overridden by its own.
+// Every job runs, and the failures are reported together.
+func repackConfig(fileName string) error {
+	conf, err := readConfig(fileName)
+	if err != nil {
+		return errors.Wrapf(err, "config: %s", err)
+	}
+	cmdline := map[string]string{}
+	flag.Visit(func(f *flag.Flag) {
+		cmdline[f.Name] = f.Value.String()
+	})
+	base := filepath.Dir(fileName)
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(base, path)
+	}
+	checkAll := *check
+	collecting = true
+	defer func() { collecting = false }()
+	// indexes are the indexes in conf.Jobs of jobs.
+	var indexes []int
+	for i, job := range conf.Jobs {
+		flag.VisitAll(func(f *flag.Flag) {
+			f.Value.Set(f.DefValue)
+		})
+		maps, params = nil, nil
+		for _, name := range sortedNames(cmdline) {
+			flag.Set(name, cmdline[name])
+		}
+		values := map[string]string{
+			"src":     job.Src,
+			"dst":     job.Dst,
+			"output":  resolve(job.Output),
+			"mapping": resolve(job.Mapping),
+			"header":  resolve(conf.Header),
+		}
+		for name, value := range job.Flags {
+			if name == "header" {
+				values[name] = resolve(fmt.Sprint(value))
+				continue
+			}
+			// A list sets a repeatable flag (e.g. "map": ["Src.Foo=Dst.Bar"]).
+			if list, ok := value.([]interface{}); ok {
+				var items []string
+				for _, item := range list {
+					items = append(items, fmt.Sprint(item))
+				}
+				values[name] = strings.Join(items, ",")
+				continue
+			}
+			values[name] = fmt.Sprint(value)
+		}
+		// In the order of their names, so that aliases of a flag (e.g.
+		// tag and tagkey) always resolve the same way.
+		for _, name := range sortedNames(values) {
+			value := values[name]
+			if name == "config" {
+				return errors.Errorf("%s: job %d: -config cannot be nested", fileName, i)
+			}
+			if value == "" && job.Flags[name] == nil {
+				continue
+			}
+			if err := flag.Set(name, value); err != nil {
+				return errors.Wrapf(err, "%s: job %d: -%s", fileName, i, name)
+			}
+		}
+		dir := job.Dir
+		if dir == "" {
+			dir = "."
+		}
+		converters := append(append([]repacker.Converter(nil), conf.Converters...), job.Converters...)
+		err := repack([]string{resolve(dir)}, job.Mappings, converters)
+		if err == errUsage {
+			return errors.Errorf("%s: job %d: src and dst must be set", fileName, i)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "%s: job %d", fileName, i)
+		}
+		for len(indexes) < len(jobs) {
+			indexes = append(indexes, i)
+		}
+	}
+	var failed []string
+	for i, err := range repacker.RunAll(jobs, *parallel) {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: job %d: %s", fileName, indexes[i], err))
+		}
+	}
+	switch {
+	case len(failed) == 0:
+		return nil
+	case checkAll:
+		return errors.Errorf("check failed:\n\t%s", strings.Join(failed, "\n\t"))
+	default:
+		return errors.New(strings.Join(failed, "\n"))
+	}
+}
+
+// sortedNames returns the names of the flags in order.
+func sortedNames(values map[string]string) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
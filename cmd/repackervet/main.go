@@ -0,0 +1,14 @@
+// Command repackervet reports the converters generated by repacker that
+// have drifted from their types, as a vet tool:
+//
+//	go vet -vettool=$(which repackervet) ./...
+package main
+
+import (
+	"github.com/knqyf263/repacker/analyzer"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(analyzer.Analyzer)
+}
@@ -0,0 +1,887 @@
+package repacker
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// fieldConversion is the conversion of a src field into a dst field of
+// another type: the statements of its temps, written to variables, and the
+// code of the converted value, or else why the field is skipped.
+type fieldConversion struct {
+	src, dst         Object
+	srcField         *types.Var
+	dstField         *types.Var
+	srcTag, dstTag   string
+	access           string        // the src field read (e.g. s.Name, or s.Name() of a getter)
+	code             string        // the converted value, access at first
+	variables        *bytes.Buffer // the statements of the temps
+	errResult        string        // precedes the error in the early returns
+	locals           *localNames
+	temp             func() string // the temp of the field, named after the src field once
+	skipNil          bool
+	srcType, dstType Type       // the types of the fields, parsed by convert
+	srcElem, dstElem types.Type // the elements of the pointer types, or the types
+	layout           string     // of the times formatted and parsed
+
+	// guard is the src pointer the assignment of the dst field depends on,
+	// with -skipnil when populating.
+	guard string
+	// parses is whether the src string is parsed, failing on malformed
+	// values, for -genfuzz.
+	parses bool
+	// nested is whether the nested converters convert the struct, or the
+	// elements of the collections.
+	nested bool
+	// skipped is why the field is skipped, if so.
+	skipped string
+}
+
+// nilSafe returns the code of the variable set to expr, read through the
+// src pointer, or the zero value if the pointer is nil. With -skipnil
+// when populating, it returns expr and guards the assignment instead.
+func (c *fieldConversion) nilSafe(variable, typeName, pointer, expr string) string {
+	if c.skipNil {
+		c.guard = pointer
+		return expr
+	}
+	fmt.Fprint(c.variables, derefCode(variable, typeName, pointer, expr))
+	return variable
+}
+
+// skip notes why the field is skipped, which ends its conversion.
+func (c *fieldConversion) skip(format string, args ...interface{}) (bool, error) {
+	c.skipped = fmt.Sprintf(format, args...)
+	return true, nil
+}
+
+// mismatch skips the field for its type.
+func (c *fieldConversion) mismatch() (bool, error) {
+	return c.skip("skip field (%s): type mismatch %s vs %s", c.srcField.Name(),
+		types.TypeString(c.srcField.Type(), packageName), types.TypeString(c.dstField.Type(), packageName))
+}
+
+// fallible skips the field of a fallible conversion, without -witherror.
+func (c *fieldConversion) fallible() (bool, error) {
+	return c.skip("skip field (%s) due to fallible conversion; use -witherror", c.srcField.Name())
+}
+
+// narrowed skips the field of a narrowing conversion, with -strict.
+func (c *fieldConversion) narrowed() (bool, error) {
+	return c.skip("skip field (%s) due to narrowing conversion", c.srcField.Name())
+}
+
+// wrap adds the dst field to err.
+func (c *fieldConversion) wrap(err error) (bool, error) {
+	return true, errors.Wrapf(err, "%s.%s", c.dst.object.Name(), c.dstField.Name())
+}
+
+// convert converts the src field into the dst field of another type. An
+// anonymous struct is converted field by field. The other kinds are tried
+// in turn, the first converting the field winning: arrays, the values of
+// basic types, then the pointers to them, the elements of slices and of
+// maps, the texts of strings, and last structs.
+func (g *Generator) convert(c *fieldConversion) error {
+	if isAnonymous(c.srcField.Type()) || isAnonymous(c.dstField.Type()) {
+		g.anonymousConversion(c)
+		return nil
+	}
+	var err error
+	if c.srcType, err = g.parseType(c.srcField.Type(), c.src.pkg); err != nil {
+		return errors.Wrapf(err, "%s.%s", c.src.object.Name(), c.srcField.Name())
+	}
+	if c.dstType, err = g.parseType(c.dstField.Type(), c.dst.pkg); err != nil {
+		return errors.Wrapf(err, "%s.%s", c.dst.object.Name(), c.dstField.Name())
+	}
+	c.layout = "time.RFC3339"
+	if l, ok := g.fieldOption(c.dstTag, c.srcTag, "layout"); ok {
+		c.layout = strconv.Quote(l)
+	}
+	c.srcElem, c.dstElem = c.srcField.Type(), c.dstField.Type()
+	if p, ok := c.srcElem.(*types.Pointer); ok {
+		c.srcElem = p.Elem()
+	}
+	if p, ok := c.dstElem.(*types.Pointer); ok {
+		c.dstElem = p.Elem()
+	}
+	conversions := []func(*fieldConversion) (bool, error){
+		g.arrayConversion,
+		g.basicConversion,
+		g.pointerConversion,
+		g.sliceConversion,
+		g.mapConversion,
+		g.textConversion,
+		g.structConversion,
+	}
+	for _, conversion := range conversions {
+		if done, err := conversion(c); err != nil || done {
+			return err
+		}
+	}
+	return nil
+}
+
+// anonymousConversion converts the fields of anonymous structs, or of
+// pointers to them, into each other with a struct literal. A nil src
+// pointer leaves the zero value.
+func (g *Generator) anonymousConversion(c *fieldConversion) {
+	srcStruct, dstStruct := anonymousStruct(c.srcField.Type()), anonymousStruct(c.dstField.Type())
+	if srcStruct == nil || dstStruct == nil {
+		c.mismatch()
+		return
+	}
+	literal := g.anonymousCode(c.code, srcStruct, dstStruct, c.src.local, c.dst.qualifier)
+	if _, ok := c.dstField.Type().(*types.Pointer); ok {
+		literal = "&" + literal
+	}
+	c.code = literal
+	if _, ok := c.srcField.Type().(*types.Pointer); ok {
+		tmpSrcField := c.temp()
+		typeName := structCode(dstStruct, c.dst.qualifier)
+		if _, ok := c.dstField.Type().(*types.Pointer); ok {
+			typeName = "*" + typeName
+		}
+		fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, typeName)
+		fmt.Fprintf(c.variables, "	if %s != nil {\n		%s = %s\n	}\n", c.access, tmpSrcField, literal)
+		c.code = tmpSrcField
+	}
+}
+
+// arrayConversion converts arrays element by element, and with -arrayslice
+// arrays into slices and back: n elements are copied, and no more than
+// those of a src slice.
+func (g *Generator) arrayConversion(c *fieldConversion) (bool, error) {
+	srcArray, srcIsArray := c.srcField.Type().Underlying().(*types.Array)
+	dstArray, dstIsArray := c.dstField.Type().Underlying().(*types.Array)
+	srcSlice, srcIsSlice := c.srcField.Type().Underlying().(*types.Slice)
+	dstSlice, dstIsSlice := c.dstField.Type().Underlying().(*types.Slice)
+	if !(srcIsArray && dstIsArray || g.arraySlice && srcIsArray && dstIsSlice || g.arraySlice && srcIsSlice && dstIsArray) {
+		return false, nil
+	}
+	var srcElem, dstElem types.Type
+	var n int64
+	var bound string
+	index := c.locals.block("i")
+	switch {
+	case dstIsArray && srcIsArray:
+		if srcArray.Len() != dstArray.Len() && g.strict {
+			return c.skip("skip field (%s) due to different array lengths", c.srcField.Name())
+		}
+		srcElem, dstElem, n = srcArray.Elem(), dstArray.Elem(), srcArray.Len()
+		if dstArray.Len() < n {
+			n = dstArray.Len()
+		}
+	case srcIsArray:
+		srcElem, dstElem, n = srcArray.Elem(), dstSlice.Elem(), srcArray.Len()
+	default:
+		if g.strict {
+			return c.skip("skip field (%s) due to a slice of any length", c.srcField.Name())
+		}
+		srcElem, dstElem, n = srcSlice.Elem(), dstArray.Elem(), dstArray.Len()
+		bound = fmt.Sprintf(" && %s < len(%s)", index, c.code)
+	}
+	if g.strict && g.narrowing(srcElem, dstElem) {
+		return c.narrowed()
+	}
+	elemCode := c.code + "[" + index + "]"
+	tmpSrcField := c.temp()
+	var loop bytes.Buffer
+	switch {
+	case assignable(srcElem, dstElem):
+	case castable(srcElem, dstElem):
+		elemCode = conversionCode(types.TypeString(dstElem, c.dst.qualifier), elemCode)
+	case isCollection(srcElem) && isCollection(dstElem):
+		converted, skipped, err := g.elemConversion(&loop, elemCode, srcElem, dstElem, 1,
+			nesting{src: c.src, dst: c.dst, errResult: c.errResult, path: c.srcField.Name() + "[%d]", args: []string{index}})
+		if err != nil {
+			return c.wrap(err)
+		} else if skipped == typeMismatch {
+			return c.mismatch()
+		} else if skipped != "" {
+			return c.skip("skip field (%s) %s", c.srcField.Name(), skipped)
+		}
+		elemCode = converted
+	default:
+		nestedSrcElem, err := g.parseType(srcElem, c.src.pkg)
+		if err != nil {
+			return true, errors.Wrapf(err, "%s.%s", c.src.object.Name(), c.srcField.Name())
+		}
+		nestedDstElem, err := g.parseType(dstElem, c.dst.pkg)
+		if err != nil {
+			return c.wrap(err)
+		}
+		nestedFuncName, err := g.generate(nestedSrcElem, nestedDstElem)
+		if err != nil || nestedFuncName == "" || nestedSrcElem.isSlice || nestedSrcElem.isMap {
+			return c.mismatch()
+		}
+		nilCheck := elemCode
+		elemCode = g.callCode(nestedFuncName, elemCode, nestedSrcElem.isPointer)
+		if g.withError {
+			fmt.Fprintf(&loop, "		v, err := %s\n", elemCode)
+			fmt.Fprintf(&loop, "		if err != nil {\n			return %sfmt.Errorf(\"%s[%%d]: %%w\", %s, err)\n		}\n", c.errResult, c.srcField.Name(), index)
+			elemCode = "v"
+			nilCheck = "v"
+		}
+		if !nestedDstElem.isPointer {
+			elemCode = "*" + elemCode
+			if nestedSrcElem.isPointer {
+				// A nil src element leaves the zero value.
+				fmt.Fprintf(&loop, "		if %s == nil {\n			continue\n		}\n", nilCheck)
+			}
+		}
+	}
+	if dstIsSlice {
+		fmt.Fprintf(c.variables, "	%s := make(%s, %d)\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier), n)
+	} else {
+		fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier))
+	}
+	fmt.Fprintf(c.variables, "	for %s := 0; %s < %d%s; %s++ {\n", index, index, n, bound, index)
+	c.variables.Write(loop.Bytes())
+	fmt.Fprintf(c.variables, "		%s[%s] = %s\n", tmpSrcField, index, elemCode)
+	fmt.Fprintf(c.variables, "	}\n")
+	c.code = tmpSrcField
+	c.nested = nestedElems(c.srcField.Type(), c.dstField.Type())
+	return true, nil
+}
+
+// basicConversion converts the values of basic types, or of pointers to
+// them in the numbers of the unit option: durations and numbers, the
+// constants of enums by name, and the types converting into each other.
+func (g *Generator) basicConversion(c *fieldConversion) (bool, error) {
+	srcPtr, srcIsPtr := c.srcField.Type().(*types.Pointer)
+	dstPtr, dstIsPtr := c.dstField.Type().(*types.Pointer)
+	cases, unmatched := enumCases(c.srcField.Type(), c.dstField.Type(), c.src.qualifier, c.dst.qualifier)
+	// The unit option sets the unit of the numbers of time.Duration fields.
+	unit, hasUnit := g.fieldOption(c.dstTag, c.srcTag, "unit")
+	switch {
+	case hasUnit && (isDuration(c.srcElem) && isNumeric(c.dstElem) || isNumeric(c.srcElem) && isDuration(c.dstElem)):
+		dstElemName := types.TypeString(c.dstElem, c.dst.qualifier)
+		expr := c.code
+		if srcIsPtr {
+			expr = "*" + c.code
+		}
+		converted, err := durationCode(expr, c.srcElem, c.dstElem, unit, dstElemName)
+		if err != nil {
+			return c.wrap(err)
+		}
+		tmpSrcField := c.temp()
+		switch {
+		case srcIsPtr && dstIsPtr:
+			fmt.Fprint(c.variables, nilCheckedCode(tmpSrcField, dstElemName, c.code, converted))
+			c.code = tmpSrcField
+		case srcIsPtr:
+			c.code = c.nilSafe(tmpSrcField, dstElemName, c.code, converted)
+		case dstIsPtr:
+			fmt.Fprintf(c.variables, "	%s := %s\n", tmpSrcField, converted)
+			c.code = "&" + tmpSrcField
+		default:
+			c.code = converted
+		}
+	case len(cases) > 0:
+		// The constants are mapped by name, not by value.
+		var fallback *types.Const
+		if name, ok := g.fieldOption(c.dstTag, "", "fallback"); ok {
+			var err error
+			if fallback, err = lookupEnum(c.dstField.Type(), name); err != nil {
+				return c.wrap(err)
+			}
+		}
+		for _, constant := range unmatched {
+			if fallback != nil {
+				break
+			}
+			g.logger.printf(levelWarn, "constant (%s) of field (%s) has no match in %s; it maps to the zero value",
+				constant.Name(), c.srcField.Name(), types.TypeString(c.dstField.Type(), packageName))
+		}
+		tmpSrcField := c.temp()
+		fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier))
+		fmt.Fprintf(c.variables, "	switch %s {\n", c.code)
+		for _, constants := range cases {
+			fmt.Fprintf(c.variables, "	case %s:\n		%s = %s\n",
+				constCode(constants[0], c.src.qualifier), tmpSrcField, constCode(constants[1], c.dst.qualifier))
+		}
+		if fallback != nil {
+			fmt.Fprintf(c.variables, "	default:\n		%s = %s\n", tmpSrcField, constCode(fallback, c.dst.qualifier))
+		}
+		fmt.Fprintf(c.variables, "	}\n")
+		c.code = tmpSrcField
+	case sameUnderlying(c.srcField.Type(), c.dstField.Type()),
+		isBytes(c.srcField.Type()) && isString(c.dstField.Type()),
+		isString(c.srcField.Type()) && isBytes(c.dstField.Type()):
+		c.code = conversionCode(types.TypeString(c.dstField.Type(), c.dst.qualifier), c.code)
+	case isBytesOrString(c.srcField.Type(), c.dstField.Type()):
+		// A nil src pointer or slice leaves the zero value, or a nil dst pointer.
+		tmpSrcField := c.temp()
+		srcElem, dstElem, expr := c.srcField.Type(), c.dstField.Type(), c.code
+		if srcIsPtr {
+			srcElem, expr = srcPtr.Elem(), "*"+c.code
+		}
+		if dstIsPtr {
+			dstElem = dstPtr.Elem()
+		}
+		dstElemName := types.TypeString(dstElem, c.dst.qualifier)
+		switch {
+		case dstIsPtr && (srcIsPtr || isBytes(srcElem)):
+			fmt.Fprint(c.variables, nilCheckedCode(tmpSrcField, dstElemName, c.code, conversionCode(dstElemName, expr)))
+			c.code = tmpSrcField
+		case dstIsPtr:
+			fmt.Fprintf(c.variables, "	%s := %s\n", tmpSrcField, conversionCode(dstElemName, expr))
+			c.code = "&" + tmpSrcField
+		default:
+			c.code = c.nilSafe(tmpSrcField, dstElemName, c.code, conversionCode(dstElemName, expr))
+		}
+	case castable(c.srcField.Type(), c.dstField.Type()):
+		if g.strict && g.narrowing(c.srcField.Type(), c.dstField.Type()) {
+			return c.narrowed()
+		}
+		c.code = conversionCode(types.TypeString(c.dstField.Type(), c.dst.qualifier), c.code)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// pointerConversion converts pointers from and into the values they point
+// to, and into pointers to other types. A nil src pointer leaves the zero
+// value, or a nil dst pointer, and a dst pointer points to a copy.
+func (g *Generator) pointerConversion(c *fieldConversion) (bool, error) {
+	srcPtr, srcIsPtr := c.srcField.Type().(*types.Pointer)
+	dstPtr, dstIsPtr := c.dstField.Type().(*types.Pointer)
+	switch {
+	case srcIsPtr && !dstIsPtr && (assignable(srcPtr.Elem(), c.dstField.Type()) || castable(srcPtr.Elem(), c.dstField.Type())):
+		if g.strict && g.narrowing(srcPtr.Elem(), c.dstField.Type()) {
+			return c.narrowed()
+		}
+		dstTypeName := types.TypeString(c.dstField.Type(), c.dst.qualifier)
+		expr := "*" + c.code
+		if !identical(srcPtr.Elem(), c.dstField.Type()) {
+			expr = conversionCode(dstTypeName, expr)
+		}
+		c.code = c.nilSafe(c.temp(), dstTypeName, c.code, expr)
+	case !srcIsPtr && dstIsPtr && (assignable(c.srcField.Type(), dstPtr.Elem()) || castable(c.srcField.Type(), dstPtr.Elem())):
+		if g.strict && g.narrowing(c.srcField.Type(), dstPtr.Elem()) {
+			return c.narrowed()
+		}
+		// The copy keeps the dst from aliasing the src field.
+		tmpSrcField := c.temp()
+		expr := c.code
+		if !identical(c.srcField.Type(), dstPtr.Elem()) {
+			expr = conversionCode(types.TypeString(dstPtr.Elem(), c.dst.qualifier), expr)
+		}
+		fmt.Fprintf(c.variables, "	%s := %s\n", tmpSrcField, expr)
+		c.code = "&" + tmpSrcField
+	case srcIsPtr && dstIsPtr && castable(srcPtr.Elem(), dstPtr.Elem()):
+		if g.strict && g.narrowing(srcPtr.Elem(), dstPtr.Elem()) {
+			return c.narrowed()
+		}
+		dstElemName := types.TypeString(dstPtr.Elem(), c.dst.qualifier)
+		tmpSrcField := c.temp()
+		fmt.Fprint(c.variables, nilCheckedCode(tmpSrcField, dstElemName, c.code, conversionCode(dstElemName, "*"+c.code)))
+		c.code = tmpSrcField
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// nestedConversion converts the collections of collections level by level,
+// by the conversions of their elements.
+func (g *Generator) nestedConversion(c *fieldConversion) (bool, error) {
+	tmpSrcField := c.temp()
+	var loop bytes.Buffer
+	skipped, err := g.collectionCode(&loop, tmpSrcField, c.code, c.srcField.Type(), c.dstField.Type(), 1,
+		nesting{src: c.src, dst: c.dst, errResult: c.errResult, path: c.srcField.Name()})
+	if err != nil {
+		return c.wrap(err)
+	} else if skipped == typeMismatch {
+		return c.mismatch()
+	} else if skipped != "" {
+		return c.skip("skip field (%s) %s", c.srcField.Name(), skipped)
+	}
+	fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier))
+	c.variables.Write(loop.Bytes())
+	c.code = tmpSrcField
+	c.nested = nestedElems(c.srcField.Type(), c.dstField.Type())
+	return true, nil
+}
+
+// sliceConversion converts slices of collections level by level, and of
+// elements converting into each other one by one. A nil src slice leaves
+// the nil dst slice.
+func (g *Generator) sliceConversion(c *fieldConversion) (bool, error) {
+	srcSlice, srcIsSlice := c.srcField.Type().Underlying().(*types.Slice)
+	dstSlice, dstIsSlice := c.dstField.Type().Underlying().(*types.Slice)
+	switch {
+	case !srcIsSlice || !dstIsSlice:
+		return false, nil
+	case nestedCollections(c.srcField.Type(), c.dstField.Type()):
+		return g.nestedConversion(c)
+	case castable(srcSlice.Elem(), dstSlice.Elem()):
+		if g.strict && g.narrowing(srcSlice.Elem(), dstSlice.Elem()) {
+			return c.narrowed()
+		}
+		tmpSrcField := c.temp()
+		fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier))
+		fmt.Fprintf(c.variables, "	if %s != nil {\n", c.code)
+		fmt.Fprintf(c.variables, "		%s = make(%s, len(%s))\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier), c.code)
+		fmt.Fprintf(c.variables, "		for i, v := range %s {\n", c.code)
+		fmt.Fprintf(c.variables, "			%s[i] = %s\n", tmpSrcField, conversionCode(types.TypeString(dstSlice.Elem(), c.dst.qualifier), "v"))
+		fmt.Fprintf(c.variables, "		}\n	}\n")
+		c.code = tmpSrcField
+		return true, nil
+	}
+	return false, nil
+}
+
+// mapConversion converts maps of collections level by level, and of keys
+// and elements converting into each other one by one. A nil src map leaves
+// the nil dst map.
+func (g *Generator) mapConversion(c *fieldConversion) (bool, error) {
+	srcMap, srcIsMap := c.srcField.Type().Underlying().(*types.Map)
+	dstMap, dstIsMap := c.dstField.Type().Underlying().(*types.Map)
+	switch {
+	case !srcIsMap || !dstIsMap:
+		return false, nil
+	case nestedCollections(c.srcField.Type(), c.dstField.Type()):
+		return g.nestedConversion(c)
+	case (assignable(srcMap.Key(), dstMap.Key()) || castable(srcMap.Key(), dstMap.Key())) && castable(srcMap.Elem(), dstMap.Elem()):
+		if g.strict && (g.narrowing(srcMap.Key(), dstMap.Key()) || g.narrowing(srcMap.Elem(), dstMap.Elem())) {
+			return c.narrowed()
+		}
+		tmpSrcField := c.temp()
+		key := "k"
+		if !assignable(srcMap.Key(), dstMap.Key()) {
+			key = conversionCode(types.TypeString(dstMap.Key(), c.dst.qualifier), "k")
+		}
+		fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier))
+		fmt.Fprintf(c.variables, "	if %s != nil {\n", c.code)
+		fmt.Fprintf(c.variables, "		%s = make(%s, len(%s))\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier), c.code)
+		fmt.Fprintf(c.variables, "		for k, v := range %s {\n", c.code)
+		fmt.Fprintf(c.variables, "			%s[%s] = %s\n", tmpSrcField, key, conversionCode(types.TypeString(dstMap.Elem(), c.dst.qualifier), "v"))
+		fmt.Fprintf(c.variables, "		}\n	}\n")
+		c.code = tmpSrcField
+		return true, nil
+	}
+	return false, nil
+}
+
+// textConversion converts values into and from the texts of strings, or
+// of pointers to them: JSON, times in the layout option, stringers, text
+// marshalers and unmarshalers, parsers, the true and false options of
+// bools, formatted values and parsed numbers. The parsing ones need
+// -witherror.
+func (g *Generator) textConversion(c *fieldConversion) (bool, error) {
+	srcPtr, srcIsPtr := c.srcField.Type().(*types.Pointer)
+	dstPtr, dstIsPtr := c.dstField.Type().(*types.Pointer)
+	nestedSrcType, nestedDstType := c.srcType, c.dstType
+	textNamed, unmarshals := textUnmarshaler(c.dstField.Type())
+	switch {
+	case unmarshalsJSON(c.srcField.Type(), c.dstField.Type(), g.encodesJSON(c.dstTag, c.srcTag)):
+		if !g.withError {
+			return c.fallible()
+		}
+		// An empty src leaves the zero value or a nil pointer.
+		tmpSrcField := c.temp()
+		data, set := c.code, fmt.Sprintf("len(%s) > 0", c.code)
+		if isString(c.srcField.Type()) {
+			data, set = fmt.Sprintf("[]byte(%s)", c.code), c.code+` != ""`
+		}
+		g.imports = append(g.imports, "encoding/json")
+		fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier))
+		fmt.Fprintf(c.variables, "	if %s {\n", set)
+		target := "&" + tmpSrcField
+		if dstIsPtr {
+			fmt.Fprintf(c.variables, "		%s = new(%s)\n", tmpSrcField, types.TypeString(dstPtr.Elem(), c.dst.qualifier))
+			target = tmpSrcField
+		}
+		fmt.Fprintf(c.variables, "		if err := json.Unmarshal(%s, %s); err != nil {\n			return %sfmt.Errorf(\"%s: %%w\", err)\n		}\n	}\n",
+			data, target, c.errResult, c.srcField.Name())
+		c.code = tmpSrcField
+		c.parses = isString(c.srcField.Type())
+	case marshalsJSON(c.srcField.Type(), c.dstField.Type(), g.encodesJSON(c.dstTag, c.srcTag)):
+		if !g.withError {
+			return c.fallible()
+		}
+		tmpSrcField := c.temp()
+		dstTypeName := types.TypeString(c.dstField.Type(), c.dst.qualifier)
+		text := "v"
+		if isString(c.dstField.Type()) {
+			text = conversionCode(dstTypeName, "v")
+		}
+		g.imports = append(g.imports, "encoding/json")
+		if srcIsPtr {
+			// A nil src pointer leaves the empty value instead of null.
+			fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, dstTypeName)
+			fmt.Fprintf(c.variables, "	if %s != nil {\n		v, err := json.Marshal(%s)\n", c.code, c.code)
+			fmt.Fprint(c.variables, errorCheck(c.errResult, c.srcField.Name()))
+			fmt.Fprintf(c.variables, "		%s = %s\n	}\n", tmpSrcField, text)
+			c.code = tmpSrcField
+			break
+		}
+		fmt.Fprintf(c.variables, "	%sJSON, err := json.Marshal(%s)\n", tmpSrcField, c.code)
+		fmt.Fprint(c.variables, errorCheck(c.errResult, c.srcField.Name()))
+		c.code = tmpSrcField + "JSON"
+		if isString(c.dstField.Type()) {
+			c.code = conversionCode(dstTypeName, c.code)
+		}
+	case isTime(c.srcField.Type()) && nestedDstType.name == "string" && !nestedDstType.isSlice:
+		tmpSrcField := c.temp()
+		loc, setup, skipped, err := g.location(c.dstTag, c.srcTag, tmpSrcField, c.errResult, c.srcField.Name())
+		if err != nil {
+			return c.wrap(err)
+		} else if skipped != "" {
+			return c.skip("skip field (%s) %s", c.srcField.Name(), skipped)
+		}
+		c.variables.WriteString(setup)
+		formatted := fmt.Sprintf("%s.Format(%s)", c.code, c.layout)
+		if loc != "" {
+			// The time is formatted in the location of the tz option.
+			formatted = fmt.Sprintf("%s.In(%s).Format(%s)", c.code, loc, c.layout)
+		}
+		switch {
+		case nestedSrcType.isPointer && nestedDstType.isPointer:
+			fmt.Fprint(c.variables, nilCheckedCode(tmpSrcField, "string", c.code, formatted))
+			c.code = tmpSrcField
+		case nestedSrcType.isPointer:
+			c.code = c.nilSafe(tmpSrcField, "string", c.code, formatted)
+		case nestedDstType.isPointer:
+			fmt.Fprintf(c.variables, "	%s := %s\n", tmpSrcField, formatted)
+			c.code = "&" + tmpSrcField
+		default:
+			c.code = formatted
+		}
+	case isTime(c.dstField.Type()) && nestedSrcType.name == "string" && nestedSrcType.isPointer &&
+		!nestedSrcType.isSlice && !nestedSrcType.isMap:
+		if !g.withError {
+			return c.fallible()
+		}
+		// A nil src pointer leaves the zero value.
+		tmpSrcField := c.temp()
+		loc, setup, _, err := g.location(c.dstTag, c.srcTag, tmpSrcField, c.errResult, c.srcField.Name())
+		if err != nil {
+			return c.wrap(err)
+		}
+		c.variables.WriteString(setup)
+		parsed := "v"
+		if nestedDstType.isPointer {
+			parsed = "&v"
+		}
+		fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier))
+		fmt.Fprintf(c.variables, "	if %s != nil {\n", c.code)
+		fmt.Fprintf(c.variables, "		v, err := time.Parse(%s, *%s)\n", c.layout, c.code)
+		c.parses = true
+		fmt.Fprint(c.variables, errorCheck(c.errResult, c.srcField.Name()))
+		if loc != "" {
+			fmt.Fprintf(c.variables, "		v = v.In(%s)\n", loc)
+		}
+		fmt.Fprintf(c.variables, "		%s = %s\n	}\n", tmpSrcField, parsed)
+		if c.skipNil {
+			c.guard = c.code
+		}
+		c.code = tmpSrcField
+	case isTime(c.dstField.Type()) && nestedSrcType.name == "string" &&
+		!nestedSrcType.isPointer && !nestedSrcType.isSlice:
+		if !g.withError {
+			return c.fallible()
+		}
+		tmpSrcField := c.temp()
+		loc, setup, _, err := g.location(c.dstTag, c.srcTag, tmpSrcField, c.errResult, c.srcField.Name())
+		if err != nil {
+			return c.wrap(err)
+		}
+		c.variables.WriteString(setup)
+		fmt.Fprintf(c.variables, "	%s, err := time.Parse(%s, %s)\n", tmpSrcField, c.layout, c.code)
+		c.parses = true
+		fmt.Fprint(c.variables, errorCheck(c.errResult, c.srcField.Name()))
+		if loc != "" {
+			fmt.Fprintf(c.variables, "	%s = %s.In(%s)\n", tmpSrcField, tmpSrcField, loc)
+		}
+		c.code = tmpSrcField
+		if nestedDstType.isPointer {
+			c.code = "&" + tmpSrcField
+		}
+	case isStringer(c.srcField.Type()) && nestedDstType.name == "string" && !nestedDstType.isSlice:
+		c.code = fmt.Sprintf("%s.String()", c.code)
+		if nestedDstType.isPointer {
+			tmpSrcField := c.temp()
+			fmt.Fprintf(c.variables, "	%s := %s\n", tmpSrcField, c.code)
+			c.code = "&" + tmpSrcField
+		}
+	case isTextMarshaler(c.srcField.Type()) && !isStringer(c.srcElem) &&
+		nestedDstType.name == "string" && !nestedDstType.isSlice && !nestedDstType.isMap:
+		// Stringers are formatted as they print, infallibly.
+		if !g.withError {
+			return c.fallible()
+		}
+		tmpSrcField := c.temp()
+		text := tmpSrcField + "Text"
+		if srcIsPtr {
+			// A nil src pointer leaves the empty string or a nil pointer.
+			fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier))
+			fmt.Fprintf(c.variables, "	if %s != nil {\n", c.code)
+			fmt.Fprintf(c.variables, "		%s, err := %s.MarshalText()\n", text, c.code)
+			fmt.Fprint(c.variables, errorCheck(c.errResult, c.srcField.Name()))
+			if nestedDstType.isPointer {
+				fmt.Fprintf(c.variables, "		v := string(%s)\n		%s = &v\n	}\n", text, tmpSrcField)
+			} else {
+				fmt.Fprintf(c.variables, "		%s = string(%s)\n	}\n", tmpSrcField, text)
+			}
+			if c.skipNil {
+				c.guard = c.code
+			}
+			c.code = tmpSrcField
+			break
+		}
+		fmt.Fprintf(c.variables, "	%s, err := %s.MarshalText()\n", text, c.code)
+		fmt.Fprint(c.variables, errorCheck(c.errResult, c.srcField.Name()))
+		c.code = fmt.Sprintf("string(%s)", text)
+		if nestedDstType.isPointer {
+			fmt.Fprintf(c.variables, "	%s := %s\n", tmpSrcField, c.code)
+			c.code = "&" + tmpSrcField
+		}
+	case nestedSrcType.isBasic && nestedSrcType.name == "string" && !nestedSrcType.isSlice &&
+		unmarshals:
+		if !g.withError {
+			return c.fallible()
+		}
+		dstNamedName := types.TypeString(textNamed, c.dst.qualifier)
+		tmpSrcField := c.temp()
+		c.parses = true
+		if nestedSrcType.isPointer {
+			// A nil src pointer leaves the zero value or a nil pointer.
+			parsed := "v"
+			if nestedDstType.isPointer {
+				parsed = "&v"
+			}
+			fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier))
+			fmt.Fprintf(c.variables, "	if %s != nil {\n		var v %s\n", c.code, dstNamedName)
+			fmt.Fprintf(c.variables, "		if err := v.UnmarshalText([]byte(*%s)); err != nil {\n			return %sfmt.Errorf(\"%s: %%w\", err)\n		}\n",
+				c.code, c.errResult, c.srcField.Name())
+			fmt.Fprintf(c.variables, "		%s = %s\n	}\n", tmpSrcField, parsed)
+			if c.skipNil {
+				c.guard = c.code
+			}
+			c.code = tmpSrcField
+			break
+		}
+		fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, dstNamedName)
+		fmt.Fprintf(c.variables, "	if err := %s.UnmarshalText([]byte(%s)); err != nil {\n		return %sfmt.Errorf(\"%s: %%w\", err)\n	}\n",
+			tmpSrcField, c.code, c.errResult, c.srcField.Name())
+		c.code = tmpSrcField
+		if nestedDstType.isPointer {
+			c.code = "&" + tmpSrcField
+		}
+	case nestedSrcType.isBasic && nestedSrcType.name == "string" && !nestedSrcType.isSlice &&
+		isParsable(c.dstField.Type()):
+		dstNamed, _ := parsable(c.dstField.Type())
+		parser, err := lookupParser(dstNamed)
+		if err == nil && !parser.Exported() && c.dst.qualifier(parser.Pkg()) != "" {
+			err = fmt.Errorf("%s is unexported", parser.Name())
+		}
+		if err != nil {
+			return c.skip("skip field (%s): %s", c.srcField.Name(), err)
+		}
+		if !g.withError {
+			return c.fallible()
+		}
+		parserName := parser.Name()
+		if q := c.dst.qualifier(parser.Pkg()); q != "" {
+			parserName = q + "." + parserName
+			g.imports = append(g.imports, parser.Pkg().Path())
+		}
+		tmpSrcField := c.temp()
+		c.parses = true
+		if nestedSrcType.isPointer {
+			// A nil src pointer leaves the zero value or a nil pointer.
+			parsed := "v"
+			if nestedDstType.isPointer {
+				parsed = "&v"
+			}
+			fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier))
+			fmt.Fprintf(c.variables, "	if %s != nil {\n", c.code)
+			fmt.Fprintf(c.variables, "		v, err := %s(*%s)\n", parserName, c.code)
+			fmt.Fprint(c.variables, errorCheck(c.errResult, c.srcField.Name()))
+			fmt.Fprintf(c.variables, "		%s = %s\n	}\n", tmpSrcField, parsed)
+			if c.skipNil {
+				c.guard = c.code
+			}
+			c.code = tmpSrcField
+			break
+		}
+		fmt.Fprintf(c.variables, "	%s, err := %s(%s)\n", tmpSrcField, parserName, c.code)
+		fmt.Fprint(c.variables, errorCheck(c.errResult, c.srcField.Name()))
+		c.code = tmpSrcField
+		if nestedDstType.isPointer {
+			c.code = "&" + tmpSrcField
+		}
+	case nestedSrcType.isBasic && nestedSrcType.name == "bool" && nestedDstType.name == "string" &&
+		!nestedSrcType.isPointer && !nestedSrcType.isSlice && !nestedDstType.isSlice:
+		tmpSrcField := c.temp()
+		trueValue, hasTrue := g.fieldOption(c.dstTag, c.srcTag, "true")
+		falseValue, hasFalse := g.fieldOption(c.dstTag, c.srcTag, "false")
+		if hasTrue || hasFalse {
+			fmt.Fprintf(c.variables, "	%s := %s\n", tmpSrcField, strconv.Quote(falseValue))
+			fmt.Fprintf(c.variables, "	if %s {\n		%s = %s\n	}\n", c.code, tmpSrcField, strconv.Quote(trueValue))
+			c.code = tmpSrcField
+		} else {
+			c.code = fmt.Sprintf("strconv.FormatBool(%s)", c.code)
+		}
+		if nestedDstType.isPointer {
+			if c.code != tmpSrcField {
+				fmt.Fprintf(c.variables, "	%s := %s\n", tmpSrcField, c.code)
+			}
+			c.code = "&" + tmpSrcField
+		}
+	case nestedSrcType.isBasic && nestedSrcType.name == "string" && nestedDstType.isBasic && nestedDstType.name == "bool" &&
+		!nestedSrcType.isPointer && !nestedSrcType.isSlice && !nestedDstType.isSlice &&
+		g.hasFieldOption(c.dstTag, c.srcTag, "true", "false"):
+		// The other values fail rather than map to false.
+		if !g.withError {
+			return c.fallible()
+		}
+		tmpSrcField := c.temp()
+		trueValue, _ := g.fieldOption(c.dstTag, c.srcTag, "true")
+		falseValue, _ := g.fieldOption(c.dstTag, c.srcTag, "false")
+		fmt.Fprintf(c.variables, "	var %s bool\n", tmpSrcField)
+		fmt.Fprintf(c.variables, "	switch %s {\n", c.code)
+		fmt.Fprintf(c.variables, "	case %s:\n		%s = true\n", strconv.Quote(trueValue), tmpSrcField)
+		fmt.Fprintf(c.variables, "	case %s:\n		%s = false\n", strconv.Quote(falseValue), tmpSrcField)
+		fmt.Fprintf(c.variables, "	default:\n		return %sfmt.Errorf(\"%s: invalid value %%q\", %s)\n	}\n",
+			c.errResult, c.srcField.Name(), c.code)
+		c.code, c.parses = tmpSrcField, true
+		if nestedDstType.isPointer {
+			c.code = "&" + tmpSrcField
+		}
+	case nestedDstType.name == "string" && nestedDstType.isPointer && srcIsPtr &&
+		!nestedDstType.isSlice && !nestedDstType.isMap:
+		tmpSrcField := c.temp()
+		fmt.Fprint(c.variables, nilCheckedCode(tmpSrcField, "string", c.code,
+			formatCode("*"+c.code, srcPtr.Elem())))
+		c.code = tmpSrcField
+	case nestedDstType.name == "string" && srcIsPtr && !nestedDstType.isSlice && !nestedDstType.isMap:
+		c.code = c.nilSafe(c.temp(), "string", c.code,
+			formatCode("*"+c.code, srcPtr.Elem()))
+	case nestedDstType.name == "string" && !nestedDstType.isSlice && !nestedDstType.isMap:
+		c.code = formatCode(c.code, c.srcField.Type())
+		if nestedDstType.isPointer {
+			tmpSrcField := c.temp()
+			fmt.Fprintf(c.variables, "	%s := %s\n", tmpSrcField, c.code)
+			c.code = "&" + tmpSrcField
+		}
+	case nestedDstType.isBasic && nestedSrcType.isBasic && nestedSrcType.name == "string" &&
+		!nestedSrcType.isSlice && !nestedDstType.isSlice:
+		if !g.withError {
+			return c.fallible()
+		}
+		tmpSrcField := c.temp()
+		expr := c.code
+		if srcIsPtr {
+			expr = "*" + c.code
+		}
+		parsed, cast, err := parseCode(expr, nestedDstType.name)
+		if err != nil {
+			return c.mismatch()
+		}
+		c.parses = true
+		if srcIsPtr {
+			// A nil src pointer leaves the zero value, or a nil dst pointer.
+			value := "v"
+			if cast {
+				value = conversionCode(nestedDstType.name, "v")
+			}
+			if nestedDstType.isPointer {
+				fmt.Fprintf(c.variables, "	var %s *%s\n", tmpSrcField, nestedDstType.name)
+				value = "&" + value
+				if cast {
+					value = "&c"
+				}
+			} else {
+				fmt.Fprintf(c.variables, "	var %s %s\n", tmpSrcField, nestedDstType.name)
+			}
+			fmt.Fprintf(c.variables, "	if %s != nil {\n		v, err := %s\n", c.code, parsed)
+			fmt.Fprint(c.variables, errorCheck(c.errResult, c.srcField.Name()))
+			if cast && nestedDstType.isPointer {
+				fmt.Fprintf(c.variables, "		c := %s\n", conversionCode(nestedDstType.name, "v"))
+			}
+			fmt.Fprintf(c.variables, "		%s = %s\n	}\n", tmpSrcField, value)
+			c.code = tmpSrcField
+			break
+		}
+		if cast {
+			fmt.Fprintf(c.variables, "	%sValue, err := %s\n", tmpSrcField, parsed)
+			fmt.Fprint(c.variables, errorCheck(c.errResult, c.srcField.Name()))
+			fmt.Fprintf(c.variables, "	%s := %s(%sValue)\n", tmpSrcField, nestedDstType.name, tmpSrcField)
+		} else {
+			fmt.Fprintf(c.variables, "	%s, err := %s\n", tmpSrcField, parsed)
+			fmt.Fprint(c.variables, errorCheck(c.errResult, c.srcField.Name()))
+		}
+		c.code = tmpSrcField
+		if nestedDstType.isPointer {
+			c.code = "&" + tmpSrcField
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// structConversion converts the structs by their nested converters,
+// generated with the first one, and a struct into a basic type by its
+// field of the name of the type (e.g. Int64 for int64).
+func (g *Generator) structConversion(c *fieldConversion) (bool, error) {
+	nestedSrcType, nestedDstType := c.srcType, c.dstType
+	if nestedDstType.isBasic {
+		converter, err := g.generateConverteCode(nestedSrcType, nestedDstType.name)
+		if err != nil {
+			return c.mismatch()
+		}
+		c.code = fmt.Sprintf("%s.%s", c.access, converter)
+		if nestedDstType.isPointer && nestedSrcType.isPointer {
+			tmpSrcField := c.temp()
+			fmt.Fprint(c.variables, nilCheckedCode(tmpSrcField, nestedDstType.name,
+				c.access, c.code))
+			c.code = tmpSrcField
+		} else if nestedDstType.isPointer {
+			tmpSrcField := c.temp()
+			fmt.Fprintf(c.variables, "	%s := %s\n", tmpSrcField, c.code)
+			c.code = "&" + tmpSrcField
+		}
+		return true, nil
+	}
+	if !sameTypeParams(c.srcField.Type(), c.dstField.Type()) {
+		return c.skip("skip field (%s) due to different type parameters", c.srcField.Name())
+	}
+	nestedFuncName, err := g.generate(nestedSrcType, nestedDstType)
+	if err != nil {
+		return c.mismatch()
+	}
+	if nestedFuncName == "" {
+		return true, nil
+	}
+	c.code = g.callCode(nestedFuncName, c.code,
+		nestedSrcType.isSlice || nestedSrcType.isMap || nestedSrcType.isPointer)
+	c.nested = true
+	tmpSrcField := c.temp()
+	deref := !nestedDstType.isSlice && !nestedDstType.isMap && !nestedDstType.isPointer
+	nilCheck := c.access
+	if g.withError {
+		converted := tmpSrcField
+		if deref && nestedSrcType.isPointer {
+			converted += "Ptr"
+			nilCheck = converted
+		}
+		fmt.Fprintf(c.variables, "	%s, err := %s\n", converted, c.code)
+		fmt.Fprint(c.variables, errorCheck(c.errResult, c.srcField.Name()))
+		c.code = converted
+	}
+	if deref && nestedSrcType.isPointer {
+		c.code = c.nilSafe(tmpSrcField, types.TypeString(c.dstField.Type(), c.dst.qualifier), nilCheck, "*"+c.code)
+	} else if deref {
+		c.code = fmt.Sprintf("*%s", c.code)
+	}
+	return true, nil
+}
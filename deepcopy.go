@@ -0,0 +1,257 @@
+package repacker
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// deepCopies reports whether the dst field deep-copies the src field it
+// copies as is, by the copy option of their tags (deep or shared), or else
+// by -deepcopy.
+func (g *Generator) deepCopies(dstTag, srcTag string) (bool, error) {
+	value, ok := g.fieldOption(dstTag, srcTag, "copy")
+	switch {
+	case !ok:
+		return g.deepCopy, nil
+	case value == "deep":
+		return true, nil
+	case value == "shared":
+		return false, nil
+	}
+	return false, errors.Errorf("copy option %s; use deep or shared", value)
+}
+
+// sharesMemory reports whether a value of type t copied as is shares
+// memory with the original: whether it is or holds by value a pointer, a
+// slice, a map, an interface, a channel or a func, in the fields that the
+// generated code can set (which leaves out those of time.Time).
+func (g *Generator) sharesMemory(t types.Type) bool {
+	return g.references(t, map[types.Type]bool{})
+}
+
+// references is sharesMemory, not visiting the types seen again.
+func (g *Generator) references(t types.Type, seen map[types.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch u := t.Underlying().(type) {
+	case *types.Pointer, *types.Slice, *types.Map, *types.Interface, *types.Chan, *types.Signature:
+		return true
+	case *types.Basic:
+		return u.Kind() == types.UnsafePointer
+	case *types.Array:
+		return g.references(u.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if g.settable(u.Field(i)) && g.references(u.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// settable reports whether the generated code can set the struct field.
+func (g *Generator) settable(field *types.Var) bool {
+	return field.Name() != "_" && (field.Exported() || field.Pkg() != nil && field.Pkg().Path() == g.outPath)
+}
+
+// qualifier qualifies the types of the deep copies, declared in the
+// package of the generated code whichever package the types are of.
+func (g *Generator) qualifier(p *types.Package) string {
+	if p.Path() == g.outPath {
+		return ""
+	}
+	return g.names.name(p.Path(), p.Name())
+}
+
+// deepCopyCode returns the code of a deep copy of expr of type t: expr
+// itself if it shares no memory, or else the call of a generated function
+// copying the pointers, slices, maps and structs it holds, which leaves the
+// interfaces, channels and funcs shared, and the keys of maps, which are
+// compared by identity if pointers, as they are.
+func (g *Generator) deepCopyCode(expr string, t types.Type) string {
+	if !g.sharesMemory(t) || hasTypeParams(t) {
+		return expr
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		if lockPath(u.Elem(), g.qualifier, map[types.Type]bool{}) != "" {
+			// Locks are never copied.
+			return expr
+		}
+	case *types.Slice, *types.Map, *types.Array, *types.Struct:
+	default:
+		return expr
+	}
+	name := deepCopyName(t, g.qualifier)
+	if name == "" {
+		// Anonymous structs have no name to name the function after.
+		g.logger.printf(levelInfo, "%s: cannot deep-copy %s; shared", expr, types.TypeString(t, g.qualifier))
+		return expr
+	}
+	funcName := "deepCopy" + name
+	if _, ok := g.deepCopyTypes[funcName]; !ok {
+		// Generated with the helpers, and shared by the files of the
+		// package alike.
+		g.deepCopyTypes[funcName] = t
+		g.deepCopyNames = append(g.deepCopyNames, funcName)
+	}
+	return fmt.Sprintf("%s(%s)", funcName, expr)
+}
+
+// generateDeepCopy generates the function funcName returning a deep copy
+// of its argument of type t.
+func (g *Generator) generateDeepCopy(funcName string, t types.Type) {
+	typeName := types.TypeString(t, g.qualifier)
+	var code bytes.Buffer
+	fmt.Fprintf(&code, "\n// %s returns a deep copy of s\n", funcName)
+	fmt.Fprintf(&code, "func %s(s %s) %s {\n", funcName, typeName, typeName)
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		fmt.Fprintf(&code, "	if s == nil {\n		return nil\n	}\n")
+		fmt.Fprintf(&code, "	d := %s\n", g.deepCopyCode("*s", u.Elem()))
+		if !types.Identical(t, u) {
+			fmt.Fprintf(&code, "	return %s(&d)\n}\n", typeName)
+		} else {
+			fmt.Fprintf(&code, "	return &d\n}\n")
+		}
+	case *types.Slice:
+		fmt.Fprintf(&code, "	if s == nil {\n		return nil\n	}\n")
+		fmt.Fprintf(&code, "	d := make(%s, len(s))\n", typeName)
+		if elem := g.deepCopyCode("v", u.Elem()); elem != "v" {
+			fmt.Fprintf(&code, "	for i, v := range s {\n		d[i] = %s\n	}\n", elem)
+		} else {
+			fmt.Fprintf(&code, "	copy(d, s)\n")
+		}
+		fmt.Fprintf(&code, "	return d\n}\n")
+	case *types.Map:
+		fmt.Fprintf(&code, "	if s == nil {\n		return nil\n	}\n")
+		fmt.Fprintf(&code, "	d := make(%s, len(s))\n", typeName)
+		fmt.Fprintf(&code, "	for k, v := range s {\n		d[k] = %s\n	}\n", g.deepCopyCode("v", u.Elem()))
+		fmt.Fprintf(&code, "	return d\n}\n")
+	case *types.Array:
+		fmt.Fprintf(&code, "	d := s\n")
+		fmt.Fprintf(&code, "	for i, v := range s {\n		d[i] = %s\n	}\n", g.deepCopyCode("v", u.Elem()))
+		fmt.Fprintf(&code, "	return d\n}\n")
+	case *types.Struct:
+		fmt.Fprintf(&code, "	d := s\n")
+		for i := 0; i < u.NumFields(); i++ {
+			field := u.Field(i)
+			if !g.settable(field) {
+				continue
+			}
+			if copied := g.deepCopyCode("s."+field.Name(), field.Type()); copied != "s."+field.Name() {
+				fmt.Fprintf(&code, "	d.%s = %s\n", field.Name(), copied)
+			}
+		}
+		fmt.Fprintf(&code, "	return d\n}\n")
+	}
+	g.buf.Write(code.Bytes())
+}
+
+// deepCopyName returns the name of type t in the names of its deep copies
+// (e.g. StringSlice or PtrDbmodelsUser), or "" for anonymous structs.
+func deepCopyName(t types.Type, qualifier types.Qualifier) string {
+	switch u := types.Unalias(t).(type) {
+	case *types.Named:
+		name := strings.Title(u.Obj().Name())
+		if u.Obj().Pkg() != nil {
+			name = strings.Title(qualifier(u.Obj().Pkg())) + name
+		}
+		for i := 0; i < u.TypeArgs().Len(); i++ {
+			arg := deepCopyName(u.TypeArgs().At(i), qualifier)
+			if arg == "" {
+				return ""
+			}
+			name += arg
+		}
+		return name
+	case *types.Basic:
+		return strings.Title(u.Name())
+	case *types.Pointer:
+		if elem := deepCopyName(u.Elem(), qualifier); elem != "" {
+			return "Ptr" + elem
+		}
+	case *types.Slice:
+		if elem := deepCopyName(u.Elem(), qualifier); elem != "" {
+			return elem + "Slice"
+		}
+	case *types.Array:
+		if elem := deepCopyName(u.Elem(), qualifier); elem != "" {
+			return fmt.Sprintf("%sArray%d", elem, u.Len())
+		}
+	case *types.Map:
+		key, elem := deepCopyName(u.Key(), qualifier), deepCopyName(u.Elem(), qualifier)
+		if key != "" && elem != "" {
+			return key + elem + "Map"
+		}
+	case *types.Interface:
+		if u.Empty() {
+			return "Any"
+		}
+	}
+	return ""
+}
+
+// hasTypeParams reports whether t mentions a type parameter, which the
+// deep copies, not being generic, cannot copy.
+func hasTypeParams(t types.Type) bool {
+	switch u := types.Unalias(t).(type) {
+	case *types.TypeParam:
+		return true
+	case *types.Named:
+		for i := 0; i < u.TypeArgs().Len(); i++ {
+			if hasTypeParams(u.TypeArgs().At(i)) {
+				return true
+			}
+		}
+	case *types.Pointer:
+		return hasTypeParams(u.Elem())
+	case *types.Slice:
+		return hasTypeParams(u.Elem())
+	case *types.Array:
+		return hasTypeParams(u.Elem())
+	case *types.Map:
+		return hasTypeParams(u.Key()) || hasTypeParams(u.Elem())
+	}
+	return false
+}
+
+// copySemantics returns the paragraph of the doc comment of a converter
+// telling which of the dst fields copied as they are deep-copy their src
+// fields, and which share their memory, if any.
+func copySemantics(deep, shared []string) string {
+	var lines []string
+	switch len(deep) {
+	case 0:
+	case 1:
+		lines = append(lines, fmt.Sprintf("%s is a deep copy of its src field.", deep[0]))
+	default:
+		lines = append(lines, fmt.Sprintf("%s are deep copies of their src fields.", enumeration(deep)))
+	}
+	switch len(shared) {
+	case 0:
+	case 1:
+		lines = append(lines, fmt.Sprintf("%s shares memory with its src field.", shared[0]))
+	default:
+		lines = append(lines, fmt.Sprintf("%s share memory with their src fields.", enumeration(shared)))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "//\n" + commentLines("", strings.Join(lines, "\n"))
+}
+
+// enumeration joins the names as in a sentence (e.g. A, B and C).
+func enumeration(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
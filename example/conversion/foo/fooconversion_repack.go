@@ -3,17 +3,20 @@
 package foo
 
 import (
-	"fmt"
+	"time"
 
 	"github.com/knqyf263/repacker/example/conversion/bar"
 )
 
 // NewFooConversionFromBarBarConversion creates *FooConversion from *bar.BarConversion
 func NewFooConversionFromBarBarConversion(s *bar.BarConversion) *FooConversion {
-	createdAt := fmt.Sprint(s.CreatedAt)
+	if s == nil {
+		return nil
+	}
+	createdAt := s.CreatedAt.Format(time.RFC3339)
 	return &FooConversion{
-		ID:        s.ID,
-		Name:      s.Name,
-		CreatedAt: &createdAt,
+		ID:        s.ID,       // from ID
+		Name:      s.Name,     // from Name
+		CreatedAt: &createdAt, // from CreatedAt
 	}
 }
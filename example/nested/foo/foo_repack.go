@@ -6,16 +6,22 @@ import "github.com/knqyf263/repacker/example/nested/bar"
 
 // NewNestedFooFromBarNestedBar creates *NestedFoo from *bar.NestedBar
 func NewNestedFooFromBarNestedBar(s *bar.NestedBar) *NestedFoo {
+	if s == nil {
+		return nil
+	}
 	return &NestedFoo{
-		ID: s.ID,
+		ID: s.ID, // from ID
 	}
 }
 
 // NewFooFromBarBar creates *Foo from *bar.Bar
 func NewFooFromBarBar(s *bar.Bar) *Foo {
+	if s == nil {
+		return nil
+	}
 	return &Foo{
-		ID:   s.ID,
-		Name: s.Name,
-		Nest: *NewNestedFooFromBarNestedBar(&s.Nest),
+		ID:   s.ID,                                   // from ID
+		Name: s.Name,                                 // from Name
+		Nest: *NewNestedFooFromBarNestedBar(&s.Nest), // from Nest
 	}
 }
@@ -6,9 +6,12 @@ import "github.com/knqyf263/repacker/example/simple/bar"
 
 // NewFooSimpleFromBarBarSimple creates *FooSimple from *bar.BarSimple
 func NewFooSimpleFromBarBarSimple(s *bar.BarSimple) *FooSimple {
+	if s == nil {
+		return nil
+	}
 	return &FooSimple{
-		ID:     s.ID,
-		Name:   s.Name,
-		Detail: s.Detail,
+		ID:     s.ID,     // from ID
+		Name:   s.Name,   // from Name
+		Detail: s.Detail, // from Detail
 	}
 }
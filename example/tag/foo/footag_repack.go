@@ -6,9 +6,12 @@ import "github.com/knqyf263/repacker/example/tag/bar"
 
 // NewFooTagFromBarBarTag creates *FooTag from *bar.BarTag
 func NewFooTagFromBarBarTag(s *bar.BarTag) *FooTag {
+	if s == nil {
+		return nil
+	}
 	return &FooTag{
-		ID:   s.ID,
-		Name: s.Name,
-		Foo:  s.Bar,
+		ID:   s.ID,   // from ID
+		Name: s.Name, // from Name
+		Foo:  s.Bar,  // from Bar
 	}
 }
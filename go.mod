@@ -0,0 +1,17 @@
+module github.com/knqyf263/repacker
+
+go 1.26.0
+
+require (
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/pkg/errors v0.8.0
+	golang.org/x/tools v0.50.0
+	sigs.k8s.io/yaml v1.6.0
+)
+
+require (
+	go.yaml.in/yaml/v2 v2.4.2 // indirect
+	golang.org/x/mod v0.41.0 // indirect
+	golang.org/x/sync v0.23.0 // indirect
+	golang.org/x/sys v0.48.0 // indirect
+)
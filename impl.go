@@ -0,0 +1,158 @@
+package repacker
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// impls returns the concrete types of the interface field of the src or
+// dst, those of the impl option of the tags (e.g. impl=Card|*Bank) or else
+// of the impls of the mapping of the pair or of the reverse pair, since
+// they designate the same types either way.
+func (g *Generator) impls(src, dst Object, dstTag, srcTag, dstName, srcName string) ([]string, bool) {
+	if chain, ok := g.fieldOption(dstTag, srcTag, "impl"); ok {
+		return strings.Split(chain, "|"), true
+	}
+	for _, m := range []*Mapping{g.mapping(src, dst), g.mapping(dst, src)} {
+		if m == nil {
+			continue
+		}
+		for _, name := range []string{dstName, srcName} {
+			if impls := m.Impls[name]; len(impls) > 0 {
+				return impls, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// implType returns the concrete type of the name of an impl, a type of
+// pkg, the package of the struct of the interface field, or qualified by
+// its import path (e.g. github.com/foo/pay.Card), recording its import.
+func (g *Generator) implType(name string, pkg *types.Package) (types.Type, error) {
+	if strings.HasPrefix(name, "*") {
+		elem, err := g.implType(name[1:], pkg)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewPointer(elem), nil
+	}
+	if strings.Contains(name, ".") {
+		return g.paramType(name)
+	}
+	obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil, errors.Errorf("no type %s in %s", name, pkg.Path())
+	}
+	return obj.Type(), nil
+}
+
+// typeName returns the name of the named type t or of the type t points
+// to, or "" if neither is named.
+func typeName(t types.Type) string {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if named, ok := types.Unalias(t).(*types.Named); ok {
+		return named.Obj().Name()
+	}
+	return ""
+}
+
+// implCode returns the code converting expr of the type from into the
+// type to through the concrete types of impls, writing the variable it
+// sets to variables, or "" if both are interfaces: into an interface, the
+// constructor of the first impl from converts into, unless expr is nil,
+// and from one, a type switch over the impls converting into to. The impls
+// named as the concrete type, if any, are the only candidates.
+// skipped is why the field is skipped instead, if so.
+func (g *Generator) implCode(from, to types.Type, impls []string, expr, variable, errResult, fieldName string,
+	src, dst Object, variables *bytes.Buffer) (code, skipped string, err error) {
+	_, toIface := to.Underlying().(*types.Interface)
+	_, fromIface := from.Underlying().(*types.Interface)
+	switch {
+	case toIface && fromIface:
+		return "", "", nil
+	case !toIface && !fromIface:
+		return "", "", errors.New("impl option of a field of no interface")
+	}
+	iface, pkg := to, dst.object.Pkg()
+	if fromIface {
+		iface, pkg = from, src.object.Pkg()
+	}
+	var concrete []types.Type
+	for _, name := range impls {
+		t, err := g.implType(name, pkg)
+		if err != nil {
+			return "", "", errors.Wrap(err, "impl option")
+		}
+		if !types.AssignableTo(t, iface) {
+			return "", "", errors.Errorf("impl option: %s does not implement %s", types.TypeString(t, packageName), types.TypeString(iface, packageName))
+		}
+		concrete = append(concrete, t)
+	}
+	// The impls named as the concrete type of the other field (e.g. *Card
+	// for *bar.Card) are the only ones, if any.
+	other := from
+	if fromIface {
+		other = to
+	}
+	var named []types.Type
+	for _, t := range concrete {
+		if typeName(t) == typeName(other) {
+			named = append(named, t)
+		}
+	}
+	if len(named) > 0 {
+		concrete = named
+	}
+	toName := types.TypeString(to, g.qualifier)
+
+	if toIface {
+		for _, t := range concrete {
+			// The constructors of structs take and return pointers, which
+			// those of the impls of values dereference.
+			target := t
+			if _, ok := t.(*types.Pointer); !ok && isStructOrPtr(t) {
+				target = types.NewPointer(t)
+			}
+			pre, value, ok := g.oneofCode(expr, variable+"Value", from, target, src, dst, errResult, fieldName)
+			if !ok {
+				continue
+			}
+			if target != t {
+				value = "*" + value
+			}
+			if _, ok := from.(*types.Pointer); !ok {
+				variables.WriteString(pre)
+				return value, "", nil
+			}
+			// A nil src leaves a nil interface rather than one holding a nil
+			// pointer.
+			fmt.Fprintf(variables, "	var %s %s\n	if %s != nil {\n%s		%s = %s\n	}\n", variable, toName, expr, pre, variable, value)
+			return variable, "", nil
+		}
+		return "", fmt.Sprintf("due to no impl converting %s", types.TypeString(from, packageName)), nil
+	}
+
+	var cases bytes.Buffer
+	for _, t := range concrete {
+		pre, value, ok := g.oneofCode("v", variable+"Value", t, to, src, dst, errResult, fieldName)
+		if !ok {
+			g.logger.printf(levelInfo, "%s.%s: skip impl %s: cannot convert into %s", dst.object.Name(), fieldName,
+				types.TypeString(t, packageName), types.TypeString(to, packageName))
+			continue
+		}
+		fmt.Fprintf(&cases, "	case %s:\n%s		%s = %s\n", types.TypeString(t, g.qualifier), pre, variable, value)
+	}
+	if cases.Len() == 0 {
+		return "", fmt.Sprintf("due to no impl converting into %s", types.TypeString(to, packageName)), nil
+	}
+	// The other types leave the zero value.
+	fmt.Fprintf(variables, "	var %s %s\n	switch v := %s.(type) {\n%s	}\n", variable, toName, expr, cases.String())
+	return variable, "", nil
+}
@@ -0,0 +1,58 @@
+package repacker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+// writeList writes what the run of r would generate into its outputs for
+// List: the functions of each file, then the mapping table of each
+// converter, of the rule and the strategy of each mapped field or the
+// reason of the others, with the src fields no dst field is mapped from
+// last, e.g.
+//
+//	foo/foo_repack.go: NewFooFromBarBar
+//
+//	NewFooFromBarBar: github.com/foo/bar.Bar -> github.com/foo/foo.Foo
+//	  ID     mapped   ID     name          direct
+//	  Owner  mapped   Owner  name          nested constructor
+//	  Note   skipped         no src field
+//	         unused   Memo
+func writeList(w io.Writer, outputs []output, r *result) error {
+	wd, wdErr := filepath.Abs(".")
+	var buf bytes.Buffer
+	for _, o := range outputs {
+		outputName := o.name
+		if wdErr == nil {
+			if rel, err := filepath.Rel(wd, outputName); err == nil {
+				outputName = rel
+			}
+		}
+		fmt.Fprintf(&buf, "%s: %s\n", outputName, strings.Join(o.funcs, ", "))
+	}
+	for _, c := range r.report.Converters {
+		fmt.Fprintf(&buf, "\n%s: %s -> %s\n", c.Func, strings.Join(c.Srcs, " + "), c.Dst)
+		tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+		for _, f := range c.Fields {
+			if f.Status != "mapped" {
+				fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", f.Dst, f.Status, f.Src, f.Reason)
+				continue
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", f.Dst, f.Status, f.Src, f.Rule, f.Strategy)
+		}
+		for _, src := range c.Unused {
+			fmt.Fprintf(tw, "  \tunused\t%s\n", src)
+		}
+		tw.Flush()
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return errors.Wrapf(err, "Writing list: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package repacker
+
+import (
+	"go/token"
+	"go/types"
+	"regexp"
+	"strconv"
+)
+
+// localNames are the names of the variables of a generated converter,
+// chosen so that the temps named after the fields (e.g. age for Age) clash
+// neither with each other nor with the identifiers the converter refers to:
+// keywords, predeclared identifiers, packages and the declarations of the
+// generated package.
+type localNames struct {
+	taken map[string]bool
+	temps map[string]bool
+}
+
+// blockName matches the names of the variables the converters declare in
+// their blocks, which assign to the temps: the indexes, keys and values of
+// the loops over the elements (e.g. i, or i2 of the level below), the
+// elements converted (c and e, and d2 of the level below) and ok.
+var blockName = regexp.MustCompile(`^([cdeikv][0-9]*|ok)$`)
+
+// tempSuffixes are those of the names derived from a temp (e.g. ageValue
+// for the parsed age, or createdLocation for its location), which the temp
+// takes along.
+var tempSuffixes = []string{"", "Value", "JSON", "Text", "Ptr", "Location"}
+
+// localNames returns the names of a converter generated into the package
+// of the output, none of them taken yet.
+func (g *Generator) localNames() *localNames {
+	// err holds the errors of the fallible conversions of -witherror.
+	n := &localNames{taken: map[string]bool{"_": true, "err": true}, temps: map[string]bool{}}
+	n.reserve(types.Universe.Names()...)
+	for name := range stdImports {
+		n.reserve(name)
+	}
+	for _, name := range g.pkgNames {
+		n.reserve(name)
+	}
+	if g.names != nil {
+		for _, name := range g.names.byPath {
+			n.reserve(name)
+		}
+	}
+	for _, helper := range packageHelpers {
+		n.reserve(helper.name)
+	}
+	if p, ok := g.packages[g.dir]; ok {
+		n.reserve(p.types.Scope().Names()...)
+	}
+	return n
+}
+
+// reserve takes the names, which no temp may then have.
+func (n *localNames) reserve(names ...string) {
+	for _, name := range names {
+		n.taken[name] = true
+	}
+}
+
+// temp returns the name of a temp of base, or base numbered (e.g. age2)
+// if it or a name derived from it is taken, which it then takes. Bases that
+// are names of blocks, numbered or not, are followed by Field (e.g. iField
+// for I).
+func (n *localNames) temp(base string) string {
+	if blockName.MatchString(base) {
+		base += "Field"
+	}
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name += strconv.Itoa(i)
+		}
+		if n.free(name) {
+			for _, suffix := range tempSuffixes {
+				n.taken[name+suffix] = true
+			}
+			n.temps[name] = true
+			return name
+		}
+	}
+}
+
+// block returns the name of a variable of a block of base (e.g. i), or
+// base numbered if a temp has it.
+func (n *localNames) block(base string) string {
+	name := base
+	for i := 2; n.temps[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	return name
+}
+
+// free reports whether the name and those derived from it are not taken.
+func (n *localNames) free(name string) bool {
+	if !token.IsIdentifier(name) {
+		return false
+	}
+	for _, suffix := range tempSuffixes {
+		if n.taken[name+suffix] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package repacker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+)
+
+// level is the level of a diagnostic, shown when the Verbosity of the run
+// is at least its verbosity.
+type level struct {
+	name      string
+	verbosity int
+}
+
+var (
+	levelError  = level{"error", -1}
+	levelWarn   = level{"warn", 0}   // likely mistakes (e.g. ambiguous fields)
+	levelNotice = level{"notice", 0} // asked for by Summary or Watch
+	levelInfo   = level{"info", 1}   // the decision of each field
+	levelDebug  = level{"debug", 2}  // the lookups and the matching traces
+)
+
+// logger writes the diagnostics of a run to the standard logger, as text
+// or as one JSON object per line.
+type logger struct {
+	verbosity int
+	json      bool
+}
+
+func newLogger(opts Options) (*logger, error) {
+	l := &logger{verbosity: opts.Verbosity}
+	switch opts.LogFormat {
+	case "", "text":
+	case "json":
+		l.json = true
+	default:
+		return nil, errors.Errorf("-logformat: unknown format %s; use text or json", opts.LogFormat)
+	}
+	return l, nil
+}
+
+func (l *logger) printf(lv level, format string, args ...interface{}) {
+	if lv.verbosity > l.verbosity {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if !l.json {
+		log.Print(msg)
+		return
+	}
+	line, _ := json.Marshal(struct {
+		Level string `json:"level"`
+		Msg   string `json:"msg"`
+	}{lv.name, msg})
+	log.Writer().Write(append(line, '\n'))
+}
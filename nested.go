@@ -0,0 +1,201 @@
+package repacker
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// nesting is the field of a nested collection converted level by level:
+// its objects, the results of its converter on errors, and the path of
+// the element of the level, its format and the loop variables of the
+// levels above (e.g. Grid[%d][%d] of i and i2).
+type nesting struct {
+	src, dst  Object
+	errResult string
+	path      string
+	args      []string
+}
+
+// at returns the nesting of the element of the level with the loop
+// variable (e.g. i2 for Grid[%d][%d]), formatted by verb.
+func (n nesting) at(verb, variable string) nesting {
+	n.path += "[" + verb + "]"
+	n.args = append(n.args[:len(n.args):len(n.args)], variable)
+	return n
+}
+
+// typeMismatch is why the elements of a nested collection are skipped when
+// they cannot be converted.
+const typeMismatch = "type mismatch"
+
+// isCollection reports whether the underlying type of t is a slice, a map
+// or an array.
+func isCollection(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Slice, *types.Map, *types.Array:
+		return true
+	}
+	return false
+}
+
+// collectionElems returns the element types of src and dst if both are
+// slices, both maps of keys converting into each other, or both arrays of
+// the same length.
+func collectionElems(src, dst types.Type) (srcElem, dstElem types.Type, ok bool) {
+	switch s := src.Underlying().(type) {
+	case *types.Slice:
+		if d, ok := dst.Underlying().(*types.Slice); ok {
+			return s.Elem(), d.Elem(), true
+		}
+	case *types.Map:
+		if d, ok := dst.Underlying().(*types.Map); ok && (assignable(s.Key(), d.Key()) || castable(s.Key(), d.Key())) {
+			return s.Elem(), d.Elem(), true
+		}
+	case *types.Array:
+		if d, ok := dst.Underlying().(*types.Array); ok && s.Len() == d.Len() {
+			return s.Elem(), d.Elem(), true
+		}
+	}
+	return nil, nil, false
+}
+
+// nestedCollections reports whether src and dst are collections of the
+// same kind of elements that are collections too (e.g. [][]Item or
+// map[string][]Item), converted level by level.
+func nestedCollections(src, dst types.Type) bool {
+	srcElem, dstElem, ok := collectionElems(src, dst)
+	return ok && isCollection(srcElem) && isCollection(dstElem)
+}
+
+// nestedElems reports whether the innermost elements of the collections
+// src and dst, converted level by level, are structs converted by their
+// nested converters.
+func nestedElems(src, dst types.Type) bool {
+	for {
+		srcElem, dstElem, ok := collectionElems(src, dst)
+		if !ok {
+			return isStructOrPtr(src) && isStructOrPtr(dst)
+		}
+		if assignable(srcElem, dstElem) || castable(srcElem, dstElem) {
+			return false
+		}
+		src, dst = srcElem, dstElem
+	}
+}
+
+// collectionCode writes to code the loop setting variable, declared
+// before, to expr of the collection type src converted into dst element by
+// element, the loop of the depth (e.g. i2 and v2 for 2). A nil slice or map
+// leaves a nil one. skipped is why the elements cannot be converted, if so.
+func (g *Generator) collectionCode(code *bytes.Buffer, variable, expr string, src, dst types.Type, depth int, n nesting) (skipped string, err error) {
+	srcElem, dstElem, _ := collectionElems(src, dst)
+	suffix := ""
+	if depth > 1 {
+		suffix = strconv.Itoa(depth)
+	}
+	index, value := "i"+suffix, "v"+suffix
+	verb := "%d"
+	srcMap, isMap := src.Underlying().(*types.Map)
+	if isMap {
+		index, verb = "k"+suffix, "%v"
+	}
+	var body bytes.Buffer
+	elem, skipped, err := g.elemConversion(&body, value, srcElem, dstElem, depth, n.at(verb, index))
+	if err != nil || skipped != "" {
+		return skipped, err
+	}
+	key := index
+	if dstMap, ok := dst.Underlying().(*types.Map); ok && !assignable(srcMap.Key(), dstMap.Key()) {
+		if g.strict && g.narrowing(srcMap.Key(), dstMap.Key()) {
+			return "due to narrowing conversion", nil
+		}
+		key = conversionCode(types.TypeString(dstMap.Key(), n.dst.qualifier), index)
+	}
+	if _, ok := src.Underlying().(*types.Array); ok {
+		fmt.Fprintf(code, "	for %s, %s := range %s {\n", index, value, expr)
+	} else {
+		fmt.Fprintf(code, "	if %s != nil {\n", expr)
+		fmt.Fprintf(code, "		%s = make(%s, len(%s))\n", variable, types.TypeString(dst, n.dst.qualifier), expr)
+		fmt.Fprintf(code, "		for %s, %s := range %s {\n", index, value, expr)
+	}
+	code.Write(body.Bytes())
+	fmt.Fprintf(code, "	%s[%s] = %s\n", variable, key, elem)
+	if _, ok := src.Underlying().(*types.Array); ok {
+		fmt.Fprintf(code, "	}\n")
+	} else {
+		fmt.Fprintf(code, "		}\n	}\n")
+	}
+	return "", nil
+}
+
+// elemConversion returns the code converting value, an element of the
+// depth of type src, into dst, writing the statements it needs to code:
+// value itself or its conversion if it can be, the loop of the next depth
+// for a collection of collections or of numbers, the slice or map
+// converter (or generic helper) of a collection of structs, or else the
+// converter of the struct. A nil pointer leaves the zero value.
+func (g *Generator) elemConversion(code *bytes.Buffer, value string, src, dst types.Type, depth int, n nesting) (expr, skipped string, err error) {
+	switch {
+	case assignable(src, dst):
+		return value, "", nil
+	case castable(src, dst):
+		if g.strict && g.narrowing(src, dst) {
+			return "", "due to narrowing conversion", nil
+		}
+		return conversionCode(types.TypeString(dst, n.dst.qualifier), value), "", nil
+	}
+	inner := fmt.Sprintf("d%d", depth+1)
+	srcElem, dstElem, collection := collectionElems(src, dst)
+	_, isArray := src.Underlying().(*types.Array)
+	if collection && (isArray || !isStructOrPtr(srcElem) || !isStructOrPtr(dstElem)) {
+		var loop bytes.Buffer
+		if skipped, err := g.collectionCode(&loop, inner, value, src, dst, depth+1, n); err != nil || skipped != "" {
+			return "", skipped, err
+		}
+		fmt.Fprintf(code, "	var %s %s\n", inner, types.TypeString(dst, n.dst.qualifier))
+		code.Write(loop.Bytes())
+		return inner, "", nil
+	}
+	if !collection && (!isStructOrPtr(src) || !isStructOrPtr(dst)) {
+		return "", typeMismatch, nil
+	}
+	nestedSrc, err := g.parseType(src, n.src.pkg)
+	if err != nil {
+		return "", "", err
+	}
+	nestedDst, err := g.parseType(dst, n.dst.pkg)
+	if err != nil {
+		return "", "", err
+	}
+	funcName, err := g.generate(nestedSrc, nestedDst)
+	if err != nil || funcName == "" {
+		return "", typeMismatch, nil
+	}
+	suffix := ""
+	if depth > 1 {
+		suffix = strconv.Itoa(depth)
+	}
+	call := g.callCode(funcName, value, nestedSrc.isSlice || nestedSrc.isMap || nestedSrc.isPointer)
+	deref := !nestedDst.isSlice && !nestedDst.isMap && !nestedDst.isPointer
+	nilCheck := value
+	if g.withError {
+		converted := "c" + suffix
+		fmt.Fprintf(code, "	%s, err := %s\n", converted, call)
+		fmt.Fprintf(code, "	if err != nil {\n		return %sfmt.Errorf(%s, %s, err)\n	}\n",
+			n.errResult, strconv.Quote(n.path+": %w"), strings.Join(n.args, ", "))
+		call, nilCheck = converted, converted
+	}
+	switch {
+	case deref && nestedSrc.isPointer:
+		// The converter of a nil element returns nil.
+		converted := "e" + suffix
+		fmt.Fprint(code, derefCode(converted, types.TypeString(dst, n.dst.qualifier), nilCheck, "*"+call))
+		return converted, "", nil
+	case deref:
+		return "*" + call, "", nil
+	}
+	return call, "", nil
+}
@@ -0,0 +1,73 @@
+package repacker
+
+import (
+	"fmt"
+	"go/types"
+)
+
+// notCopyable returns why a value of type t must not be copied from a src
+// field, if it must not: it is or holds a lock by value, such as
+// sync.Mutex or a struct embedding one (e.g. "sync.Mutex is a lock"), or it
+// is a channel or a func, which would be shared rather than copied. Locks
+// are never copied; channels and funcs only when the mapping names their
+// src field.
+func notCopyable(t types.Type, qualifier types.Qualifier) (reason string, lock bool) {
+	if path := lockPath(t, qualifier, map[types.Type]bool{}); path != "" {
+		if path == types.TypeString(t, qualifier) {
+			return fmt.Sprintf("%s is a lock", path), true
+		}
+		return fmt.Sprintf("%s holds a lock (%s)", types.TypeString(t, qualifier), path), true
+	}
+	switch t.Underlying().(type) {
+	case *types.Chan:
+		return "channels are shared, not copied", false
+	case *types.Signature:
+		return "funcs are shared, not copied", false
+	}
+	return "", false
+}
+
+// lockPath returns the lock that a value of type t holds, as go vet's
+// copylocks finds them: t itself if its pointer declares Lock and Unlock
+// methods (e.g. sync.Mutex, and the noCopy of sync.WaitGroup and
+// atomic.Int64), or else the path of the struct field or array element
+// holding one by value (e.g. mu sync.Mutex).
+func lockPath(t types.Type, qualifier types.Qualifier, seen map[types.Type]bool) string {
+	if seen[t] {
+		return ""
+	}
+	seen[t] = true
+	if named, ok := types.Unalias(t).(*types.Named); ok && isLocker(named) {
+		return types.TypeString(t, qualifier)
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Array:
+		return lockPath(u.Elem(), qualifier, seen)
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if path := lockPath(u.Field(i).Type(), qualifier, seen); path != "" {
+				if u.Field(i).Anonymous() || u.Field(i).Name() == "_" {
+					return path
+				}
+				return u.Field(i).Name() + " " + path
+			}
+		}
+	}
+	return ""
+}
+
+// isLocker reports whether the named type declares Lock and Unlock
+// methods on its pointer, rather than having them promoted from a field.
+func isLocker(named *types.Named) bool {
+	if _, ok := named.Underlying().(*types.Interface); ok {
+		return false
+	}
+	methods := types.NewMethodSet(types.NewPointer(named))
+	for _, name := range []string{"Lock", "Unlock"} {
+		sel := methods.Lookup(nil, name)
+		if sel == nil || len(sel.Index()) != 1 {
+			return false
+		}
+	}
+	return types.NewMethodSet(named).Lookup(nil, "Lock") == nil
+}
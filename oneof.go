@@ -0,0 +1,112 @@
+package repacker
+
+import (
+	"fmt"
+	"go/types"
+)
+
+// oneofs returns the oneofs of the mapping of the src and dst pair, or
+// else of the reverse pair, since they designate the same fields either
+// way: by oneof field, the plain field of each of its cases.
+func (g *Generator) oneofs(src, dst Object) map[string]map[string]string {
+	if m := g.mapping(src, dst); m != nil && len(m.Oneofs) > 0 {
+		return m.Oneofs
+	}
+	if m := g.mapping(dst, src); m != nil {
+		return m.Oneofs
+	}
+	return nil
+}
+
+// oneofWrappers returns the wrappers of the cases of the oneof field of
+// type t, the interface of protoc (e.g. isEvent_Payload), by the name of
+// their only field (e.g. Text of Event_Text): the structs of its package
+// whose pointers implement it.
+func oneofWrappers(t types.Type) map[string]*types.Named {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return nil
+	}
+	iface, ok := named.Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+	wrappers := map[string]*types.Named{}
+	scope := named.Obj().Pkg().Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		wrapper, ok := obj.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		s, ok := wrapper.Underlying().(*types.Struct)
+		if !ok || s.NumFields() != 1 || !types.Implements(types.NewPointer(wrapper), iface) {
+			continue
+		}
+		wrappers[s.Field(0).Name()] = wrapper
+	}
+	return wrappers
+}
+
+// isOneof reports whether t is the interface of a oneof field.
+func isOneof(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Interface)
+	return ok && len(oneofWrappers(t)) > 0
+}
+
+// oneofCode returns the code converting expr of the type from, held by or
+// set in a case of a oneof, into the type to, and the statements it needs
+// first, indented within the case: expr as is if assignable, a conversion
+// of basic types, or a call of the constructor of a nested struct.
+func (g *Generator) oneofCode(expr, variable string, from, to types.Type, src, dst Object, errResult, name string) (pre, code string, ok bool) {
+	if assignable(from, to) {
+		return "", expr, true
+	}
+	fromBasic, isFromBasic := from.Underlying().(*types.Basic)
+	toBasic, isToBasic := to.Underlying().(*types.Basic)
+	if isFromBasic && isToBasic {
+		if fromBasic.Info()&types.IsString != toBasic.Info()&types.IsString || !types.ConvertibleTo(from, to) {
+			return "", "", false
+		}
+		return "", conversionCode(types.TypeString(to, dst.qualifier), expr), true
+	}
+	if !isStructOrPtr(from) || !isStructOrPtr(to) {
+		return "", "", false
+	}
+	fromType, err := g.parseType(from, src.pkg)
+	if err != nil {
+		return "", "", false
+	}
+	toType, err := g.parseType(to, dst.pkg)
+	if err != nil || (fromType.isPointer && !toType.isPointer) {
+		// A nil message would leave nothing to dereference.
+		return "", "", false
+	}
+	funcName, err := g.generate(fromType, toType)
+	if err != nil || funcName == "" {
+		return "", "", false
+	}
+	code = g.callCode(funcName, expr, fromType.isPointer)
+	if g.withError {
+		pre = fmt.Sprintf("		%s, err := %s\n		if err != nil {\n			return %sfmt.Errorf(\"%s: %%w\", err)\n		}\n",
+			variable, code, errResult, name)
+		code = variable
+	}
+	if !toType.isPointer {
+		code = "*" + code
+	}
+	return pre, code, true
+}
+
+// oneofCheck returns the condition that the src value expr of type t is
+// set, choosing the case of a oneof it converts into.
+func oneofCheck(expr string, t types.Type) (string, bool) {
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Slice, *types.Map, *types.Interface:
+		return expr + " != nil", true
+	}
+	return nonZeroCode(expr, t, false)
+}
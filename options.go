@@ -0,0 +1,120 @@
+package repacker
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// afterRepack is the name of the method of a dst that the constructors of
+// the dst call, if its package declares it, after mapping the fields (e.g.
+// func (d *Foo) afterRepack(s *bar.Bar)).
+const afterRepack = "afterRepack"
+
+// optionType returns the name of the type of the functional options of
+// the constructors of dst with -options (e.g. FooOption, or BarFooOption
+// for bar.Foo of another package, as the reverse of -bidirectional may
+// convert into a type of the same name), or "" if they take none. The
+// dsts of generic types take none.
+func (g *Generator) optionType(dst Object) string {
+	if !g.funcOptions || dst.typ.populate != "" {
+		return ""
+	}
+	if named, ok := dst.object.Type().(*types.Named); ok && (named.TypeParams().Len() > 0 || named.TypeArgs().Len() > 0) {
+		return ""
+	}
+	if !dst.local {
+		return strings.Title(dst.pkgName()) + dst.object.Name() + "Option"
+	}
+	return dst.object.Name() + "Option"
+}
+
+// declareOptionType writes the declaration of the option type of dst to
+// code the first time, unless the package or another generated file of it
+// declares it.
+func (g *Generator) declareOptionType(code *bytes.Buffer, dst Object) {
+	name := g.optionType(dst)
+	if name == "" || g.funcNames["type "+name] {
+		return
+	}
+	g.funcNames["type "+name] = true
+	if _, ok := g.shared[name]; ok {
+		return
+	}
+	if pkg, err := g.parsePackageDir(g.dir); err == nil && pkg.types.Scope().Lookup(name) != nil {
+		return
+	}
+	fmt.Fprintf(code, "// %s is an option of the constructors of %s, applied after the fields are mapped.\n", name, strings.TrimPrefix(dst.FullName(), "*"))
+	fmt.Fprintf(code, "type %s func(%s)\n\n", name, dst.FullName())
+}
+
+// hookCode returns the call of the afterRepack method of the local dst d
+// on the srcs, after the -params, or "" if the package declares none of
+// the parameters of the constructor. A hook returning an error fails the
+// constructor, under -witherror.
+func (g *Generator) hookCode(srcs []Object, dst Object, byValue bool, errResult string) (string, error) {
+	if !dst.local {
+		return "", nil
+	}
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(dst.object.Type()), true, dst.object.Pkg(), afterRepack)
+	method, ok := obj.(*types.Func)
+	if !ok {
+		return "", nil
+	}
+	sig := method.Type().(*types.Signature)
+	leading := sig.Params().Len() - len(srcs)
+	if leading < 0 {
+		g.logger.printf(levelInfo, "skip %s of %s: %s has fewer parameters than the srcs", afterRepack, dst.object.Name(), types.TypeString(sig, packageName))
+		return "", nil
+	}
+	var args []string
+	for i := 0; i < sig.Params().Len(); i++ {
+		t := sig.Params().At(i).Type()
+		if i < leading {
+			p := g.paramOf(t)
+			if p == nil {
+				g.logger.printf(levelInfo, "skip %s of %s: no -params of type %s", afterRepack, dst.object.Name(), types.TypeString(t, packageName))
+				return "", nil
+			}
+			args = append(args, p.name)
+			continue
+		}
+		src := srcs[i-leading]
+		switch {
+		case identical(t, types.NewPointer(src.object.Type())) && byValue:
+			args = append(args, "&"+src.param)
+		case identical(t, types.NewPointer(src.object.Type())):
+			args = append(args, src.param)
+		case identical(t, src.object.Type()) && byValue:
+			args = append(args, src.param)
+		case identical(t, src.object.Type()):
+			args = append(args, "*"+src.param)
+		default:
+			g.logger.printf(levelInfo, "skip %s of %s: %s is not of the parameters of %s", afterRepack, dst.object.Name(),
+				types.TypeString(sig, packageName), src.object.Name())
+			return "", nil
+		}
+	}
+	call := fmt.Sprintf("d.%s(%s)", afterRepack, strings.Join(args, ", "))
+	switch {
+	case sig.Results().Len() == 0:
+		return "	" + call + "\n", nil
+	case sig.Results().Len() > 1 || !types.Identical(sig.Results().At(0).Type(), types.Universe.Lookup("error").Type()):
+		return "", errors.Errorf("%s of %s returns %s; return nothing or an error", afterRepack, dst.object.Name(), types.TypeString(sig.Results(), packageName))
+	case !g.withError:
+		return "", errors.Errorf("%s of %s returns an error; use -witherror", afterRepack, dst.object.Name())
+	}
+	return fmt.Sprintf("	if err := %s; err != nil {\n		return %serr\n	}\n", call, errResult), nil
+}
+
+// optionsCode returns the code applying the options to d.
+func optionsCode(byValue bool) string {
+	d := "d"
+	if byValue {
+		d = "&d"
+	}
+	return fmt.Sprintf("	for _, opt := range opts {\n		opt(%s)\n	}\n", d)
+}
@@ -0,0 +1,112 @@
+package repacker
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// loading is held for reading while packages are loaded and for writing
+// while the outputs are written, so that a load never reads a partially
+// written file.
+var loading sync.RWMutex
+
+// packageCache shares the loaded packages between the runs of RunAll, so
+// that each package is type-checked once.
+type packageCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedPackage
+}
+
+type cachedPackage struct {
+	once sync.Once
+	pkg  *Package
+	err  error
+}
+
+// load returns the package of key, loading it once with f.
+func (c *packageCache) load(key string, f func() (*Package, error)) (*Package, error) {
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = map[string]*cachedPackage{}
+	}
+	e, ok := c.entries[key]
+	if !ok {
+		e = &cachedPackage{}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+	e.once.Do(func() {
+		e.pkg, e.err = f()
+	})
+	return e.pkg, e.err
+}
+
+// forget drops the packages of dir, whose files were written, so that the
+// next runs load them again.
+func (c *packageCache) forget(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, dir+" ") {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// RunAll runs each of opts as Run with up to workers runs at a time, or
+// GOMAXPROCS if workers is not positive, and returns the error of each,
+// nil if it succeeded. The packages are loaded once for all of opts. The
+// runs generating into the same directory run one after the other in the
+// order of opts, as each shares the helpers already declared there.
+func RunAll(opts []Options, workers int) []error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var groups [][]int
+	group := map[string]int{}
+	for i, o := range opts {
+		dir := o.Dir
+		if o.Output != "" {
+			dir = filepath.Dir(o.Output)
+		} else if o.OutDir != "" {
+			dir = o.OutDir
+		}
+		if dir == "" {
+			dir = "."
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		j, ok := group[dir]
+		if !ok {
+			j = len(groups)
+			group[dir] = j
+			groups = append(groups, nil)
+		}
+		groups[j] = append(groups[j], i)
+	}
+
+	cache := &packageCache{}
+	errs := make([]error, len(opts))
+	jobs := make(chan []int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(groups); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for indexes := range jobs {
+				for _, i := range indexes {
+					_, errs[i] = run(opts[i], cache)
+				}
+			}
+		}()
+	}
+	for _, indexes := range groups {
+		jobs <- indexes
+	}
+	close(jobs)
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,160 @@
+package repacker
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// param is an extra parameter of every generated converter, of -params
+// (e.g. ctx context.Context), which the converters pass on to those they
+// call and to the functions of the fields taking a parameter of its type.
+type param struct {
+	name string
+	typ  types.Type
+}
+
+// reservedParams are the names of the variables of the generated code
+// that a param would clash with, besides the single letters.
+var reservedParams = map[string]bool{"err": true, "tb": true, "got": true, "diffs": true}
+
+// parseParams parses the params of -params, each a name and a type,
+// predeclared or qualified by its import path, maybe a pointer (e.g.
+// "ctx context.Context" or "loc *github.com/foo/i18n.Localizer").
+func (g *Generator) parseParams(values []string) ([]param, error) {
+	var params []param
+	seen := map[string]bool{}
+	for _, value := range values {
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			return nil, errors.Errorf("-params %q: want a name and a type (e.g. ctx context.Context)", value)
+		}
+		name := fields[0]
+		if !token.IsIdentifier(name) || token.IsKeyword(name) || len(name) < 2 || reservedParams[name] {
+			return nil, errors.Errorf("-params %q: %s is not an identifier the generated code leaves free", value, name)
+		}
+		if _, ok := stdImports[name]; ok || seen[name] {
+			return nil, errors.Errorf("-params %q: %s is already taken", value, name)
+		}
+		seen[name] = true
+		typ, err := g.paramType(fields[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "-params %q", value)
+		}
+		// The packages of the name are aliased instead.
+		g.names.reserve(name)
+		params = append(params, param{name: name, typ: typ})
+	}
+	return params, nil
+}
+
+// paramType returns the type of a param, recording the import of its
+// package.
+func (g *Generator) paramType(s string) (types.Type, error) {
+	if strings.HasPrefix(s, "*") {
+		elem, err := g.paramType(s[1:])
+		if err != nil {
+			return nil, err
+		}
+		return types.NewPointer(elem), nil
+	}
+	i := strings.LastIndex(s, ".")
+	if i < 0 {
+		if obj, ok := types.Universe.Lookup(s).(*types.TypeName); ok {
+			return obj.Type(), nil
+		}
+		return nil, errors.Errorf("no type %s; qualify it by its import path", s)
+	}
+	importPath, typeName := s[:i], s[i+1:]
+	dir, err := g.importDir(importPath, g.dir)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := g.parsePackageDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := pkg.types.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil, errors.Errorf("no type %s in %s", typeName, importPath)
+	}
+	g.imports = append(g.imports, pkg.path)
+	return obj.Type(), nil
+}
+
+// paramDecls returns the declarations of the params, which lead the
+// parameters of the converters.
+func (g *Generator) paramDecls() []string {
+	var decls []string
+	for _, p := range g.params {
+		decls = append(decls, fmt.Sprintf("%s %s", p.name, types.TypeString(p.typ, g.qualifier)))
+	}
+	return decls
+}
+
+// paramsPrefix returns the declarations of the params before those of
+// the other parameters of a converter (e.g. "ctx context.Context, ").
+func (g *Generator) paramsPrefix() string {
+	if len(g.params) == 0 {
+		return ""
+	}
+	return strings.Join(g.paramDecls(), ", ") + ", "
+}
+
+// paramArgs returns the arguments of a call of a converter: the params
+// and then args.
+func (g *Generator) paramArgs(args ...string) string {
+	var all []string
+	for _, p := range g.params {
+		all = append(all, p.name)
+	}
+	return strings.Join(append(all, args...), ", ")
+}
+
+// passedParams returns the params passed to the function name of the
+// signature before the value it converts, those of the types of its
+// leading parameters (e.g. ctx of levelLabel(ctx context.Context, l
+// Level)). A function left to goimports, without a signature, gets none.
+func (g *Generator) passedParams(name string, sig *types.Signature) ([]string, error) {
+	if sig == nil {
+		return nil, nil
+	}
+	var names []string
+	for i := 0; i < sig.Params().Len()-1; i++ {
+		v := sig.Params().At(i)
+		p := g.paramOf(v.Type())
+		if p == nil {
+			return nil, errors.Errorf("%s: no -params of type %s for its parameter %s", name, types.TypeString(v.Type(), packageName), v.Name())
+		}
+		names = append(names, p.name)
+	}
+	return names, nil
+}
+
+// paramOf returns the first param of the type t, or nil if none.
+func (g *Generator) paramOf(t types.Type) *param {
+	for i, p := range g.params {
+		if identical(p.typ, t) {
+			return &g.params[i]
+		}
+	}
+	return nil
+}
+
+// paramsCode returns the declarations of the params in the tests, of
+// context.Background() for a context.Context and of the zero value of the
+// other types.
+func (g *Generator) paramsCode() string {
+	var b strings.Builder
+	for _, p := range g.params {
+		if types.TypeString(p.typ, nil) == "context.Context" {
+			fmt.Fprintf(&b, "	%s := context.Background()\n", p.name)
+			continue
+		}
+		fmt.Fprintf(&b, "	var %s %s\n", p.name, types.TypeString(p.typ, g.qualifier))
+	}
+	return b.String()
+}
@@ -0,0 +1,166 @@
+package repacker
+
+import (
+	"go/types"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// gormAssociations are the settings of gorm tags declaring associations.
+var gormAssociations = []string{"foreignkey", "references", "many2many", "polymorphic", "joinforeignkey", "joinreferences"}
+
+// gormBookkeeping are the settings of gorm tags of the fields gorm sets
+// itself.
+var gormBookkeeping = []string{"autocreatetime", "autoupdatetime", "->"}
+
+// gormSettings returns the settings of the gorm tag by lower-case key
+// (e.g. column for column:user_id), with the empty values of flags such as
+// primarykey.
+func gormSettings(tag string) map[string]string {
+	settings := map[string]string{}
+	value, ok := reflect.StructTag(tag).Lookup("gorm")
+	if !ok {
+		return settings
+	}
+	for _, setting := range strings.Split(value, ";") {
+		kv := strings.SplitN(setting, ":", 2)
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		if key == "" {
+			continue
+		}
+		if len(kv) == 2 {
+			settings[key] = strings.TrimSpace(kv[1])
+		} else {
+			settings[key] = ""
+		}
+	}
+	return settings
+}
+
+// column returns the column of the field of -profile, to match fields of
+// entities and DTOs by: that of its gorm column setting or db tag, or else
+// its json tag, or else the column the ORM names it after (e.g. user_id for
+// UserID, or userid with sqlx).
+func (g *Generator) column(name, tag string) string {
+	switch g.profile {
+	case "gorm":
+		if column := gormSettings(tag)["column"]; column != "" {
+			return column
+		}
+	case "sqlx":
+		if column, ok := lookupTagKey(tag, "db"); ok && column != "-" {
+			return column
+		}
+	}
+	if column, ok := lookupTagKey(tag, "json"); ok && column != "-" {
+		return column
+	}
+	if g.profile == "sqlx" {
+		return strings.ToLower(name)
+	}
+	return toDBName(name)
+}
+
+// toDBName returns the snake-case column of the field name, as gorm and ent
+// name them (e.g. user_id for UserID, and http_server for HTTPServer).
+func toDBName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// profileTagKeys are the tag keys of the ORMs of -profile, by profile.
+var profileTagKeys = map[string]string{"gorm": "gorm", "sqlx": "db"}
+
+// profileIgnored reports whether the field is left out by the ORM of
+// -profile, tagged gorm:"-" (or "-:all") or db:"-", and so is never mapped.
+func (g *Generator) profileIgnored(tag string) bool {
+	key, ok := profileTagKeys[g.profile]
+	if !ok {
+		return false
+	}
+	value, ok := reflect.StructTag(tag).Lookup(key)
+	return ok && (value == "-" || (key == "gorm" && value == "-:all"))
+}
+
+// isAssociation reports whether the field holds other entities of the ORM
+// of -profile rather than a column: a gorm field declaring an association
+// in its tag or of a struct type (or a pointer or slice of one) other than
+// time.Time and the types scanning columns, or the Edges of an ent entity.
+// Associations are matched by name only.
+func (g *Generator) isAssociation(f *types.Var, tag string) bool {
+	switch g.profile {
+	case "gorm":
+		settings := gormSettings(tag)
+		for _, key := range gormAssociations {
+			if _, ok := settings[key]; ok {
+				return true
+			}
+		}
+		if f.Anonymous() {
+			return false
+		}
+		t := f.Type()
+		if s, ok := t.Underlying().(*types.Slice); ok {
+			t = s.Elem()
+		}
+		if p, ok := t.Underlying().(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		named, ok := types.Unalias(t).(*types.Named)
+		if !ok || !isStruct(named) || isTime(named) {
+			return false
+		}
+		// sql.Scanner, as sql.NullString and gorm.DeletedAt.
+		scan, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), true, nil, "Scan")
+		_, isMethod := scan.(*types.Func)
+		return !isMethod
+	case "ent":
+		return f.Name() == "Edges"
+	}
+	return false
+}
+
+// profileReason returns why the dst field left without a src field is
+// ignored rather than skipped under -profile, if it is: an association of
+// the entity, or a field gorm sets itself, such as those of gorm.Model and
+// the autoCreateTime ones.
+func (g *Generator) profileReason(dst Object, f *types.Var, tag string) string {
+	if g.isAssociation(f, tag) {
+		return g.profile + " association"
+	}
+	if g.profile != "gorm" {
+		return ""
+	}
+	if pkg := dst.object.Pkg(); pkg != nil && pkg.Path() == "gorm.io/gorm" && dst.object.Name() == "Model" {
+		return "gorm bookkeeping"
+	}
+	if isGormDeletedAt(f.Type()) {
+		return "gorm bookkeeping"
+	}
+	settings := gormSettings(tag)
+	for _, key := range gormBookkeeping {
+		if _, ok := settings[key]; ok {
+			return "gorm bookkeeping"
+		}
+	}
+	return ""
+}
+
+// isGormDeletedAt reports whether t is gorm.DeletedAt, the sql.NullTime of
+// the soft deletes of gorm.
+func isGormDeletedAt(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "gorm.io/gorm" && named.Obj().Name() == "DeletedAt"
+}
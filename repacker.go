@@ -0,0 +1,6315 @@
+// Package repacker generates the code that copies src structs to dst
+// structs. The repacker command in cmd/repacker is a thin wrapper of Run.
+package repacker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/imports"
+	"sigs.k8s.io/yaml"
+)
+
+var tagRegex = regexp.MustCompile(`([0-9a-zA-Z,_=&\(\)\-]+)(:( )?"([0-9a-zA-Z,_=&\(\)\-]*)")?`)
+
+// Options are the options of a run of repacker, one field per flag of
+// the command.
+type Options struct {
+	Dir           string    // destination package directory; default "."
+	Src           string    // comma-separated list of type names, with +-joined types merged into one dst, or wildcards of exported structs (e.g. bar.Model* or bar.all); must be set
+	Dst           string    // comma-separated list of type names, formats of the dsts of wildcards (e.g. %sDTO); must be set
+	WithError     bool      // generate constructors that also return an error for fallible conversions
+	Output        string    // output file name for Run; default <OutDir or Dir>/<first dst>_repack.go
+	Layout        string    // output files of Run: dst (default) named after the first dst, package for one repack_gen.go, or pair for a file per pair named after its dst
+	Filename      string    // template of the output file names, of the lower-case .Dst (e.g. {{.Dst}}_conv.go); default {{.Dst}}_repack.go, or repack_gen.go with Layout package
+	OutDir        string    // directory of the package of the generated code, if other than Dir, qualifying the dst types
+	OutPkg        string    // package name of the generated code; default that of OutDir, or its base name if it has no Go files
+	SrcDir        string    // directory of the src types named without an import path, relative to the module root unless ./ or ../; default Dir
+	Stdout        bool      // Run writes the generated code to standard output instead of a file
+	TagKey        string    // struct tag key used to match fields; default "repack"
+	MatchTags     []string  // other tag keys matching fields with the same tag (e.g. json), after TagKey
+	Fuzzy         bool      // match field names case-insensitively, ignoring underscores, as Match normalized
+	Match         string    // field name matching: exact (default), case-insensitive or normalized
+	Profile       string    // ORM of the entities, gorm, sqlx or ent, matching fields by column and leaving out associations and bookkeeping fields
+	Strict        bool      // fail if a dst field is not mapped, refusing narrowing numeric conversions
+	AllowEmpty    bool      // only warn, instead of failing, when no dst field of a pair is mapped
+	Tags          []string  // build tags to apply, in addition to those of GOFLAGS
+	BuildTag      string    // build constraint of the generated files (e.g. linux && amd64), written as a //go:build line
+	Header        string    // file of a header (e.g. a license) written at the top of the generated files, as comments
+	IncludeTests  bool      // also read types from _test.go files, generating the code of those into a _test.go file
+	Method        bool      // generate methods on src instead of functions
+	Getters       bool      // also read unexported src fields through Get-prefixed getters (e.g. GetID() for id)
+	Rules         bool      // also note the rule that mapped each dst field in its comment (e.g. // from Name by tag)
+	Mapping       string    // JSON file of explicit field mappings
+	Mappings      []Mapping // in addition to those of Mapping
+	Maps          []string  // field mappings (e.g. Src.Foo=Dst.Bar), taking precedence over Mapping and Mappings
+	Params        []string  // extra parameters leading those of every converter, each a name and a type qualified by its import path (e.g. ctx context.Context), passed on to the functions of the fields taking one of its type
+	Converters    []Converter
+	GenTest       bool     // Run also writes a test that every mapped dst field is set
+	GenFuzz       bool     // also write fuzz targets of the src strings parsed with WithError to the test of GenTest
+	DeepCopy      bool     // deep-copy the pointers, slices and maps of the fields copied as they are instead of sharing them
+	FuncOptions   bool     // the constructors also take functional options applied after the mapping (e.g. opts ...FooOption)
+	GenBench      bool     // also write benchmarks of the converters, reporting their allocations, to the test of GenTest
+	Check         bool     // Run fails with a diff if the output is missing or out of date instead of writing it
+	Force         bool     // Run overwrites the existing files of its outputs even if they were not generated by repacker
+	List          bool     // Run writes the functions it would generate and the mapping table of each to standard output instead of the code
+	Populate      bool     // generate methods that fill an existing dst instead of constructors
+	ByValue       bool     // generate the constructors of the pairs from and to values (e.g. func(s bar.Bar) Foo) instead of pointers
+	InPlace       bool     // generate the methods of Populate next to the constructors
+	Merge         string   // also generate functions copying only the non-zero (zero) or non-nil (nil) src fields onto a dst
+	Diff          bool     // also generate functions listing the mapped fields of a dst that differ from those converted from the srcs
+	Bidirectional bool     // also generate the reverse constructors of each src from its dst
+	Collections   bool     // also generate the constructors of slices and maps of each dst from those of its src
+	AnyMap        bool     // also generate the constructors of each dst from a map[string]interface{} of its fields by key, and back; Src may then be empty
+	Null          string   // how invalid sql.Null* values map: nil (default) pointers, or zero values and back
+	Optional      bool     // convert the zero values of plain src fields into nil pointer dst fields, as unset optional fields (e.g. of OpenAPI), instead of pointers to them
+	Generics      bool     // convert slices and maps with generic helpers generated once per package, instead of a function per type
+	ArraySlice    bool     // also convert arrays to slices and slices to arrays element by element
+	Style         string   // function (default), or method generating d.FromSrc(s) instead of constructors, and d.ToSrc() with Bidirectional
+	SkipNil       bool     // with Populate, InPlace or Style method, leave dst fields untouched when their src pointers are nil
+	Args          []string // command-line arguments recorded in the header of the generated code
+	Report        string   // format of the mapping report Run writes to standard output; only "json"
+	Summary       bool     // Run logs the functions it generated (e.g. when run by go generate)
+	CacheDir      string   // directory of an on-disk cache of the type-checked packages, keyed by the hashes of their files
+	Verbosity     int      // of the diagnostics logged: -1 only errors, 0 warnings (default), 1 also the decision of each field, 2 also the lookups and matching traces
+	LogFormat     string   // of the diagnostics: text (default), or json with one object of the level and msg per line
+}
+
+// Converter converts every src field of type Src into a dst field of type
+// Dst, unless the field sets its own converter function. Types are named
+// by import path (e.g. github.com/shopspring/decimal.Decimal or *int).
+type Converter struct {
+	Src  string `json:"src"`
+	Dst  string `json:"dst"`
+	Func string `json:"func"` // function as the using option, or method of Src after a dot (e.g. .String)
+}
+
+// Report is the mapping report of a run: how each dst field of each
+// generated converter was mapped, or why it was not.
+type Report struct {
+	Converters []ConverterReport `json:"converters"`
+}
+
+// ConverterReport is the mapping report of a generated converter.
+// Types are named by import path.
+type ConverterReport struct {
+	Func   string        `json:"func"`
+	Srcs   []string      `json:"srcs"`
+	Dst    string        `json:"dst"`
+	Fields []FieldReport `json:"fields"`
+	Unused []string      `json:"unused,omitempty"` // src fields no dst field is mapped from
+}
+
+// FieldReport is the mapping of a dst field. Status is "mapped", with
+// the src field, the rule that matched it (mapping, name, tag, column,
+// getter, setter, the Match strategy, path, concat, oneof, embedded or
+// default) and the strategy converting its value (direct, conversion,
+// nested constructor, custom func or default), or "ignored" or "skipped"
+// with the reason.
+type FieldReport struct {
+	Dst      string `json:"dst"`
+	Status   string `json:"status"`
+	Src      string `json:"src,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+	Strategy string `json:"strategy,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// result is the code generated for a run.
+type result struct {
+	dir      string // absolute destination directory
+	dstName  string // first dst type, naming the default output file
+	testFile bool   // the code uses types of _test.go files, so it is a test file too
+	code     []byte
+	test     []byte     // for GenTest
+	files    []pairFile // the code of each pair, for Layout pair
+	report   Report
+	funcs    []string // functions and methods declared by code, for Summary
+	logger   *logger
+	dirs     []string // directories of the packages read in the module, for Watch
+	// buildFlags are those the packages were loaded with, to type-check
+	// the code with.
+	buildFlags []string
+	stale      []string // stale generated files, removed by Run
+}
+
+// pairFile is the code of a pair and of the converters it uses first,
+// written into a file named after its dst for Layout pair.
+type pairFile struct {
+	dstName string
+	code    []byte
+}
+
+// output is a file written by Run.
+type output struct {
+	name  string
+	code  []byte
+	funcs []string // functions and methods declared by code, for Summary
+}
+
+// outputs returns the files r writes for opts: that of its code, or of
+// the code of each pair for Layout pair.
+func (r *result) outputs(opts Options) []output {
+	if len(r.files) == 0 {
+		return []output{{name: outputFile(opts, r.dir, r.dstName, r.testFile), code: r.code, funcs: r.funcs}}
+	}
+	var outputs []output
+	for _, f := range r.files {
+		outputs = append(outputs, output{name: outputFile(opts, r.dir, f.dstName, r.testFile), code: f.code, funcs: declaredFuncs(f.code)})
+	}
+	return outputs
+}
+
+// Generate returns the code generated for opts. Output, Stdout and
+// Check are ignored, and the test of GenTest is only written by Run. With
+// Layout pair, the code of all the pairs is returned as one file.
+func Generate(opts Options) ([]byte, error) {
+	r, err := generate(opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.code, nil
+}
+
+// Run generates the code for opts and writes it to the output file, or
+// to standard output with Stdout. With Check or List, nothing is written.
+func Run(opts Options) error {
+	_, err := run(opts, nil)
+	return err
+}
+
+// run runs opts as Run, returning the generated code. The packages are
+// loaded from cache, if any.
+func run(opts Options, cache *packageCache) (*result, error) {
+	if opts.GenTest && opts.Stdout {
+		return nil, errors.New("-gentest cannot write to standard output")
+	}
+	if opts.Report != "" && opts.Stdout {
+		return nil, errors.New("-report cannot share standard output with the generated code")
+	}
+	r, err := generate(opts, cache)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Report != "" {
+		report, err := json.MarshalIndent(r.report, "", "  ")
+		if err != nil {
+			return nil, errors.Wrapf(err, "report: %s", err)
+		}
+		if _, err = os.Stdout.Write(append(report, '\n')); err != nil {
+			return nil, errors.Wrapf(err, "Writing report: %s", err)
+		}
+	}
+
+	if opts.List {
+		outputs := []output{{name: "standard output", funcs: r.funcs}}
+		if !opts.Stdout {
+			outputs = r.outputs(opts)
+		}
+		return r, writeList(os.Stdout, outputs, r)
+	}
+
+	if opts.Stdout {
+		if _, err = os.Stdout.Write(r.code); err != nil {
+			return nil, errors.Wrapf(err, "Writing output: %s", err)
+		}
+		return r, nil
+	}
+
+	// Write to file.
+	outputs := r.outputs(opts)
+	if opts.Output != "" {
+		if info, err := os.Stat(filepath.Dir(outputs[0].name)); err != nil || !info.IsDir() {
+			return nil, errors.Errorf("Output directory %s does not exist", filepath.Dir(outputs[0].name))
+		}
+	}
+	// The test of all the pairs goes next to the first file.
+	testName := strings.TrimSuffix(outputs[0].name, ".go") + "_test.go"
+	if opts.Check {
+		// Check all the files, so that a single run reports all the diffs.
+		var diffs []string
+		for _, o := range outputs {
+			if err := checkOutput(o.name, o.code); err != nil {
+				diffs = append(diffs, err.Error())
+			}
+		}
+		if opts.GenTest {
+			if err := checkOutput(testName, r.test); err != nil {
+				diffs = append(diffs, err.Error())
+			}
+		}
+		for _, name := range r.stale {
+			diffs = append(diffs, fmt.Sprintf("%s is stale: its pairs are generated into %s", name, outputs[0].name))
+		}
+		if len(diffs) > 0 {
+			return nil, errors.New(strings.Join(diffs, "\n"))
+		}
+		return nil, nil
+	}
+	if !opts.Force {
+		// Refuse before writing anything, so that no file is left half done.
+		var names []string
+		for _, o := range outputs {
+			names = append(names, o.name)
+		}
+		if opts.GenTest {
+			names = append(names, testName)
+		}
+		for _, name := range names {
+			if err := checkOverwrite(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := r.typeCheck(outputs, testName); err != nil {
+		return nil, err
+	}
+	// Packages are not loaded while the files are being written.
+	loading.Lock()
+	defer loading.Unlock()
+	for _, o := range outputs {
+		if err = ioutil.WriteFile(o.name, o.code, 0644); err != nil {
+			return nil, errors.Wrapf(err, "Writing output: %s", err)
+		}
+	}
+	if opts.GenTest {
+		if err = ioutil.WriteFile(testName, r.test, 0644); err != nil {
+			return nil, errors.Wrapf(err, "Writing test: %s", err)
+		}
+	}
+	for _, name := range r.stale {
+		if err = os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "Removing stale file: %s", err)
+		}
+		r.logger.printf(levelNotice, "removed %s: its pairs are generated into %s", name, outputs[0].name)
+	}
+	if cache != nil {
+		cache.forget(filepath.Dir(outputs[0].name))
+	}
+	if opts.Summary {
+		for _, o := range outputs {
+			r.logger.printf(levelNotice, "wrote %s: %s", o.name, strings.Join(o.funcs, ", "))
+		}
+	}
+	return r, nil
+}
+
+// generate generates the code of opts. The files generated by other
+// layouts or file names of its dsts (e.g. user_repack.go of the dst layout
+// for -layout package) that declare its pairs are stale: the code is
+// generated again without them, for Run to remove them.
+func generate(opts Options, cache *packageCache) (*result, error) {
+	stale := map[string]bool{}
+	for {
+		r, err := generateWithout(opts, cache, stale)
+		if err != nil || len(r.stale) == len(stale) {
+			return r, err
+		}
+		for _, name := range r.stale {
+			stale[name] = true
+		}
+	}
+}
+
+// generateWithout generates the code of opts as generate, leaving out the
+// stale generated files, by absolute name: they are neither loaded nor
+// shared. The result names them, and those found stale too.
+func generateWithout(opts Options, cache *packageCache, stale map[string]bool) (*result, error) {
+	if opts.Src == "" && !opts.AnyMap || opts.Dst == "" {
+		return nil, errors.New("src and dst must be set")
+	}
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.TagKey == "" {
+		opts.TagKey = "repack"
+	}
+	switch opts.Layout {
+	case "", "dst", "package":
+	case "pair":
+		if opts.Output != "" {
+			return nil, errors.New("-layout pair names the file of each pair; use -filename instead of -output")
+		}
+	default:
+		return nil, errors.Errorf("-layout: unknown layout %s; use dst, package or pair", opts.Layout)
+	}
+	if _, err := outputBaseName(opts, "Dst"); err != nil {
+		return nil, errors.Wrapf(err, "-filename: %s", err)
+	}
+	ok, err := isDirectory(opts.Dir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !ok {
+		return nil, errors.New("Directory must be specified")
+	}
+
+	g := &Generator{cache: cache, cacheDir: opts.CacheDir, stale: map[string]bool{}}
+	for name := range stale {
+		g.stale[name] = true
+	}
+	if g.logger, err = newLogger(opts); err != nil {
+		return nil, err
+	}
+	if opts.Filename != "" || opts.Layout == "package" {
+		g.generatedGlob = generatedGlob(filenameTemplate(opts))
+	}
+	g.funcNames = map[string]bool{}
+	g.deepCopyTypes = map[string]types.Type{}
+	g.methods = map[string]bool{}
+	g.packages = map[string]*Package{}
+	g.importDirs = map[string]string{}
+	g.pkgNames = map[string]string{}
+	g.dir = opts.Dir
+	g.withError = opts.WithError
+	g.getters = opts.Getters
+	g.rules = opts.Rules
+	g.tagKey = opts.TagKey
+	g.matchTags = opts.MatchTags
+	g.match = opts.Match
+	if g.match == "exact" {
+		g.match = ""
+	}
+	switch {
+	case opts.Fuzzy && g.match != "" && g.match != "normalized":
+		return nil, errors.Errorf("-fuzzy cannot be combined with -match=%s", g.match)
+	case opts.Fuzzy:
+		g.match = "normalized"
+	case g.match != "" && g.match != "case-insensitive" && g.match != "normalized":
+		return nil, errors.Errorf("-match: unknown strategy %s; use exact, case-insensitive or normalized", g.match)
+	}
+	g.method = opts.Method
+	g.strict = opts.Strict
+	g.allowEmpty = opts.AllowEmpty
+	g.includeTests = opts.IncludeTests
+	g.genTest = opts.GenTest
+	g.genFuzz = opts.GenFuzz
+	if g.genFuzz && (!g.genTest || !g.withError) {
+		return nil, errors.New("-genfuzz requires -gentest and -witherror")
+	}
+	g.genBench = opts.GenBench
+	if g.genBench && !g.genTest {
+		return nil, errors.New("-genbench requires -gentest")
+	}
+	g.skipNil = opts.SkipNil
+	g.args = opts.Args
+	if g.buildTag = strings.TrimSpace(opts.BuildTag); g.buildTag != "" {
+		if _, err := constraint.Parse("//go:build " + g.buildTag); err != nil {
+			return nil, errors.Errorf("-buildtag: %s: %s", g.buildTag, err)
+		}
+	}
+	if opts.Header != "" {
+		if g.header, err = readHeader(opts.Header); err != nil {
+			return nil, errors.Wrapf(err, "header: %s", err)
+		}
+	}
+	g.generics = opts.Generics
+	g.arraySlice = opts.ArraySlice
+	switch g.null = opts.Null; g.null {
+	case "", "nil", "zero":
+	default:
+		return nil, errors.Errorf("-null: unknown mapping %s; use nil or zero", g.null)
+	}
+	g.optional = opts.Optional
+	g.deepCopy = opts.DeepCopy
+	g.funcOptions = opts.FuncOptions
+	switch g.profile = opts.Profile; g.profile {
+	case "", "gorm", "sqlx", "ent":
+	default:
+		return nil, errors.Errorf("-profile: unknown ORM %s; use gorm, sqlx or ent", g.profile)
+	}
+	g.calls = map[string]string{}
+	g.helpers = map[string]bool{}
+	g.converters = map[string]*converter{}
+	g.samples = map[string]*converter{}
+	if opts.Bidirectional && opts.Populate {
+		return nil, errors.New("-bidirectional cannot populate srcs outside the destination package")
+	}
+	if opts.InPlace && opts.Populate {
+		return nil, errors.New("-inplace cannot be combined with -populate, which generates no constructors")
+	}
+	methodStyle := false
+	switch opts.Style {
+	case "", "function":
+	case "method":
+		methodStyle = true
+		for _, f := range []struct {
+			name string
+			set  bool
+		}{{"populate", opts.Populate}, {"inplace", opts.InPlace}, {"method", opts.Method}, {"collections", opts.Collections}} {
+			if f.set {
+				return nil, errors.Errorf("-style=method cannot be combined with -%s", f.name)
+			}
+		}
+	default:
+		return nil, errors.Errorf("-style: unknown style %s; use function or method", opts.Style)
+	}
+	if g.skipNil && !opts.Populate && !opts.InPlace && !methodStyle {
+		return nil, errors.New("-skipnil requires -populate, -inplace or -style=method")
+	}
+	switch opts.Merge {
+	case "", "zero", "nil":
+	default:
+		return nil, errors.Errorf("-merge: unknown semantics %s; use zero or nil", opts.Merge)
+	}
+	if opts.Collections && opts.Populate {
+		return nil, errors.New("-collections cannot populate slices and maps")
+	}
+	switch {
+	case opts.ByValue && (opts.Populate || methodStyle):
+		return nil, errors.New("-byvalue requires the constructors that -populate and -style=method do not generate")
+	case opts.ByValue && opts.Collections:
+		return nil, errors.New("-byvalue cannot be combined with -collections, whose elements are pointers")
+	}
+	if opts.Report != "" && opts.Report != "json" {
+		return nil, errors.Errorf("-report: unknown format %s; use json", opts.Report)
+	}
+	g.buildContext = build.Default
+	g.buildContext.BuildTags = buildTags(opts.Tags)
+
+	if opts.Mapping != "" {
+		if g.mappings, err = readMappings(opts.Mapping); err != nil {
+			return nil, errors.Wrapf(err, "mapping: %s", err)
+		}
+	}
+	g.mappings = append(g.mappings, opts.Mappings...)
+	for _, value := range opts.Maps {
+		m, err := parseMap(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "map: %s", err)
+		}
+		g.mappings = append(g.mappings, m)
+	}
+	g.mappings = mergeMappings(g.mappings)
+	g.typeConverters = opts.Converters
+
+	d, err := filepath.Abs(g.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Abs %s: %s", g.dir, err)
+	}
+	g.dir = d
+	if opts.OutDir != "" {
+		// The generated package, other than that of the dst types in d.
+		if ok, err := isDirectory(opts.OutDir); err != nil || !ok {
+			return nil, errors.Errorf("-outdir: directory %s does not exist", opts.OutDir)
+		}
+		if g.dir, err = filepath.Abs(opts.OutDir); err != nil {
+			return nil, errors.Wrapf(err, "Abs %s: %s", opts.OutDir, err)
+		}
+	}
+	if g.dir != d && (opts.Populate || opts.InPlace || methodStyle) {
+		return nil, errors.New("-outdir cannot declare the methods of the dst types outside their package")
+	}
+	dstPkg, err := g.parsePackageDir(d)
+	if err != nil {
+		return nil, err
+	}
+	// The src types named without an import path are in srcPkg.
+	srcPkg := dstPkg
+	if opts.SrcDir != "" {
+		dir, err := srcDir(opts.SrcDir, d)
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := isDirectory(dir); err != nil || !ok {
+			return nil, errors.Errorf("-srcdir: directory %s does not exist", dir)
+		}
+		if srcPkg, err = g.parsePackageDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	srcNames := strings.Split(opts.Src, ",")
+	dstNames := strings.Split(opts.Dst, ",")
+	var wildcardName string
+	if opts.Src == "" {
+		// -anymap converts the dsts alone, from and to maps.
+		srcNames = nil
+	} else {
+		if len(srcNames) != len(dstNames) {
+			return nil, errors.Errorf("-src and -dst must list the same number of types: %d != %d", len(srcNames), len(dstNames))
+		}
+		if srcNames, dstNames, wildcardName, err = g.expandWildcards(srcNames, dstNames, srcPkg, dstPkg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Several srcs populating the same dst need distinct method names.
+	dstCount := map[string]int{}
+	for _, name := range dstNames {
+		dstCount[strings.TrimSpace(name)]++
+	}
+
+	var srcTypes [][]Type
+	var dstTypes []Type
+	var populates []string // names of the methods filling each dst
+	var merges []string    // names of the merge functions of each dst
+	var srcImportPaths []string
+	for i := range srcNames {
+		// Types joined with + are merged into one dst.
+		var merged []Type
+		for _, name := range strings.Split(srcNames[i], "+") {
+			srcType, err := g.parseFullTypeString(strings.TrimSpace(name), srcPkg)
+			if err != nil {
+				return nil, err
+			}
+			if srcType.importPath == "" && srcPkg != dstPkg {
+				srcType.importPath = srcPkg.path
+			}
+			merged = append(merged, srcType)
+		}
+		if g.method && len(merged) > 1 {
+			return nil, errors.Errorf("-method cannot merge %s into one dst", srcNames[i])
+		}
+		for _, srcType := range merged {
+			if g.method && srcType.dir != g.dir {
+				return nil, errors.Errorf("-method requires %s to be in the package of the generated code", srcNames[i])
+			}
+			// Types in the generated package need no import, and those
+			// named without a path are in the dst package.
+			switch {
+			case srcType.dir == g.dir:
+			case srcType.importPath == "":
+				srcImportPaths = append(srcImportPaths, dstPkg.path)
+			default:
+				srcImportPaths = append(srcImportPaths, srcType.importPath)
+			}
+		}
+		srcTypes = append(srcTypes, merged)
+		dstType := Type{
+			dir:  d,
+			name: strings.TrimSpace(dstNames[i]),
+		}
+		// Methods of the method style are named after the srcs
+		// (e.g. FromBar), as are those of a dst listed more than once.
+		populate := "PopulateFrom"
+		if methodStyle {
+			populate = "From"
+		}
+		merge := "Merge" + dstType.name
+		for k, srcType := range merged {
+			if dstCount[dstType.name] > 1 {
+				srcPkg, err := g.parsePackageDir(srcType.dir)
+				if err != nil {
+					return nil, err
+				}
+				if k == 0 {
+					merge += "From"
+				}
+				populate += strings.Title(srcPkg.name) + srcType.name
+				merge += strings.Title(srcPkg.name) + srcType.name
+			} else if methodStyle {
+				populate += srcType.name
+			}
+		}
+		if opts.Populate || methodStyle {
+			dstType.populate = populate
+		}
+		// The reverse conversions are methods on the dst (e.g. d.ToBar()).
+		dstType.method = methodStyle
+		dstType.byValue = opts.ByValue
+		dstTypes = append(dstTypes, dstType)
+		populates = append(populates, populate)
+		merges = append(merges, merge)
+	}
+	if srcNames == nil {
+		for _, name := range dstNames {
+			dstTypes = append(dstTypes, Type{dir: d, name: strings.TrimSpace(name)})
+		}
+	}
+	outPkg, err := g.outPackage(dstPkg, opts.OutPkg)
+	if err != nil {
+		return nil, err
+	}
+	g.names = newImportNames(outPkg.path, outPkg.name)
+	g.outPath = outPkg.path
+	if g.params, err = g.parseParams(opts.Params); err != nil {
+		return nil, err
+	}
+	if outPkg != dstPkg {
+		srcImportPaths = append(srcImportPaths, dstPkg.path)
+	}
+
+	// Another run may have generated the same nested constructors into
+	// another file of the package, unless the code is not written into it.
+	outputs := []string{outputFile(opts, g.dir, dstTypes[0].name, false)}
+	if wildcardName != "" {
+		outputs = append(outputs, outputFile(opts, g.dir, wildcardName, false))
+	}
+	if opts.Layout == "pair" {
+		for _, dstType := range dstTypes {
+			outputs = append(outputs, outputFile(opts, g.dir, dstType.name, false))
+		}
+	}
+	if !opts.Stdout {
+		if g.shared, err = g.declaredElsewhere(outPkg.dir, outputs...); err != nil {
+			return nil, err
+		}
+	}
+
+	g.logger.printf(levelDebug, "Generating...")
+	// pairFuncs are the converters of the pairs, not of their fields.
+	var pairFuncs []string
+	// The code of each pair starts at its offset, for Layout pair.
+	pairStarts := make([]int, len(dstTypes))
+	for i := range srcTypes {
+		pairStarts[i] = g.buf.Len()
+		if opts.Collections && len(srcTypes[i]) == 1 {
+			if err = g.generateCollections(srcTypes[i][0], dstTypes[i]); err != nil {
+				return nil, errors.Wrapf(err, "generate: %s", err)
+			}
+		} else if opts.Collections {
+			g.logger.printf(levelInfo, "skip collections of %s: merged srcs", dstTypes[i].name)
+		}
+		var funcName string
+		if len(srcTypes[i]) > 1 {
+			funcName, err = g.generateMerged(srcTypes[i], dstTypes[i])
+		} else {
+			funcName, err = g.generate(srcTypes[i][0], dstTypes[i])
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "generate: %s", err)
+		}
+		pairFuncs = append(pairFuncs, funcName)
+		if err = g.checkMapped(funcName); err != nil {
+			return nil, err
+		}
+		if g.genTest {
+			g.generateTest(funcName)
+		}
+		constructor := funcName
+		if opts.Diff && funcName != "" {
+			g.generateDiff(funcName, "Diff"+strings.TrimPrefix(merges[i], "Merge"))
+		}
+		if opts.InPlace {
+			// The method filling an existing dst, next to the constructor.
+			inPlace := dstTypes[i]
+			inPlace.populate = populates[i]
+			if len(srcTypes[i]) > 1 {
+				funcName, err = g.generateMerged(srcTypes[i], inPlace)
+			} else {
+				funcName, err = g.generate(srcTypes[i][0], inPlace)
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "generate: %s", err)
+			}
+			pairFuncs = append(pairFuncs, funcName)
+			if g.genTest {
+				g.generateTest(funcName)
+			}
+		}
+		if opts.Merge != "" {
+			// The function copying the set src fields onto a dst (e.g. MergeFoo).
+			merge := dstTypes[i]
+			merge.populate, merge.merge = merges[i], opts.Merge
+			if len(srcTypes[i]) > 1 {
+				funcName, err = g.generateMerged(srcTypes[i], merge)
+			} else {
+				funcName, err = g.generate(srcTypes[i][0], merge)
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "generate: %s", err)
+			}
+			pairFuncs = append(pairFuncs, funcName)
+			if g.genTest {
+				g.generateTest(funcName)
+			}
+		}
+		if !opts.Bidirectional {
+			continue
+		}
+		// Each of the merged srcs gets its own reverse constructor.
+		for _, srcType := range srcTypes[i] {
+			if opts.Collections {
+				g.reverse = true
+				err = g.generateCollections(dstTypes[i], srcType)
+				g.reverse = false
+				if err != nil {
+					return nil, errors.Wrapf(err, "generate: %s", err)
+				}
+			}
+			srcType.byValue = opts.ByValue
+			g.reverse = true
+			funcName, err = g.generate(dstTypes[i], srcType)
+			g.reverse = false
+			if err != nil {
+				return nil, errors.Wrapf(err, "generate: %s", err)
+			}
+			pairFuncs = append(pairFuncs, funcName)
+			if g.genTest {
+				g.generateTest(funcName)
+				g.generateRoundTripTest(constructor, funcName)
+			}
+		}
+	}
+
+	pairsEnd := g.buf.Len()
+	anyMaps := make([][]byte, len(dstTypes))
+	if opts.AnyMap {
+		for i, dstType := range dstTypes {
+			start := g.buf.Len()
+			if _, _, err = g.generateAnyMap(dstType); err != nil {
+				return nil, errors.Wrapf(err, "generate: %s", err)
+			}
+			anyMaps[i] = g.buf.Bytes()[start:]
+		}
+	}
+
+	if g.strict && len(g.unmapped) > 0 {
+		return nil, errors.Errorf("unmapped dst fields:\n\t%s", strings.Join(g.unmapped, "\n\t"))
+	}
+	if g.testDecl != "" && opts.Output != "" && !strings.HasSuffix(opts.Output, "_test.go") {
+		return nil, errors.Errorf("-output %s must be a _test.go file, as %s", opts.Output, g.testDecl)
+	}
+	helpersStart := g.buf.Len()
+	if err = g.generateHelpers(outPkg, outputFile(opts, g.dir, dstTypes[0].name, g.testDecl != "")); err != nil {
+		return nil, err
+	}
+	// The pairs of a dst share its file.
+	var pairBodies [][]byte
+	var pairNames []string
+	pairFiles := map[string]int{}
+	if opts.Layout == "pair" {
+		for i := range dstTypes {
+			end := pairsEnd
+			if i+1 < len(srcTypes) {
+				end = pairStarts[i+1]
+			}
+			var pairBody []byte
+			if i < len(srcTypes) {
+				pairBody = append(pairBody, g.buf.Bytes()[pairStarts[i]:end]...)
+			}
+			pairBody = append(pairBody, anyMaps[i]...)
+			if i == 0 {
+				// The helpers are shared by the files of the package.
+				pairBody = append(pairBody, g.buf.Bytes()[helpersStart:]...)
+			}
+			name := strings.ToLower(dstTypes[i].name)
+			if j, ok := pairFiles[name]; ok {
+				pairBodies[j] = append(pairBodies[j], pairBody...)
+				continue
+			}
+			pairFiles[name] = len(pairBodies)
+			pairBodies = append(pairBodies, pairBody)
+			pairNames = append(pairNames, dstTypes[i].name)
+		}
+	}
+
+	// The head goes last, with the imports of the converter functions,
+	// and the test shares it, with those of its samples, each only
+	// importing the packages it uses.
+	body := append([]byte(nil), g.buf.Bytes()...)
+	importPaths := append(append([]string(nil), srcImportPaths...), g.imports...)
+	g.buf.Reset()
+	g.generateHead(outPkg.name, g.usedImports(g.testBuf.Bytes(), append(importPaths, g.testImports...)))
+	test := append(append([]byte(nil), g.buf.Bytes()...), g.testBuf.Bytes()...)
+	g.testBuf.Reset()
+	g.testBuf.Write(test)
+	g.buf.Reset()
+	g.generateHead(outPkg.name, g.usedImports(body, importPaths))
+	g.buf.Write(body)
+
+	// Format the output.
+	r := &result{dir: g.dir, dstName: dstTypes[0].name, testFile: g.testDecl != "", report: g.report, logger: g.logger, buildFlags: g.buildFlags()}
+	names := []string{wildcardName}
+	for _, dstType := range dstTypes {
+		names = append(names, dstType.name)
+	}
+	if r.stale, err = g.staleFiles(opts.Filename, outPkg.dir, names, outputs, pairFuncs); err != nil {
+		return nil, err
+	}
+	if r.code, err = g.goimport(g.buf.Bytes()); err != nil {
+		return nil, errors.Wrapf(err, "goimport: %s", err)
+	}
+	r.funcs = declaredFuncs(r.code)
+	if wildcardName != "" {
+		// The file does not change name as types are added.
+		r.dstName = wildcardName
+	}
+	for i, pairBody := range pairBodies {
+		g.buf.Reset()
+		g.generateHead(outPkg.name, g.usedImports(pairBody, importPaths))
+		g.buf.Write(pairBody)
+		code, err := g.goimport(g.buf.Bytes())
+		if err != nil {
+			return nil, errors.Wrapf(err, "goimport: %s", err)
+		}
+		r.files = append(r.files, pairFile{dstName: pairNames[i], code: code})
+	}
+	if roots := moduleRoots(d); len(roots) > 0 {
+		// The packages of the other modules of a workspace are watched too.
+		for dir := range g.packages {
+			for _, root := range roots {
+				if dir == root || strings.HasPrefix(dir, root+string(filepath.Separator)) {
+					r.dirs = append(r.dirs, dir)
+					break
+				}
+			}
+		}
+		sort.Strings(r.dirs)
+	}
+	if g.genTest {
+		if r.test, err = g.goimport(g.testBuf.Bytes()); err != nil {
+			return nil, errors.Wrapf(err, "goimport: %s", err)
+		}
+	}
+	return r, nil
+}
+
+// isWildcard reports whether the type name of a src (e.g. Model* or all)
+// selects the exported structs it matches.
+func isWildcard(name string) bool {
+	return name == "all" || strings.ContainsAny(name, "*?")
+}
+
+// expandWildcards replaces each wildcard src with the exported structs of
+// its package it matches, paired with the dsts named by its dst as a
+// format (e.g. %sDTO for UserDTO), %s if empty. Structs without such a dst
+// are skipped. It also returns the name of the package of the first src if
+// it is a wildcard, which names the default output file.
+func (g *Generator) expandWildcards(srcNames, dstNames []string, srcPkg, dstPkg *Package) ([]string, []string, string, error) {
+	var srcs, dsts []string
+	fileName := ""
+	for i, srcName := range srcNames {
+		srcName = strings.TrimSpace(srcName)
+		importPath, pattern := splitType(srcName)
+		if !isWildcard(pattern) {
+			srcs = append(srcs, srcName)
+			dsts = append(dsts, dstNames[i])
+			continue
+		}
+		if strings.Contains(srcName, "+") {
+			return nil, nil, "", errors.Errorf("-src cannot merge wildcard %s into one dst", srcName)
+		}
+		format := strings.TrimSpace(dstNames[i])
+		if format == "" {
+			format = "%s"
+		}
+		if !strings.Contains(format, "%s") {
+			return nil, nil, "", errors.Errorf("-dst %s of wildcard %s must name the dsts with %%s (e.g. %%sDTO)", format, srcName)
+		}
+		t, err := g.parseFullTypeString(srcName, srcPkg)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		pkg, err := g.parsePackageDir(t.dir)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		if i == 0 {
+			fileName = pkg.name
+		}
+		n := 0
+		for _, name := range pkg.types.Scope().Names() {
+			obj, ok := pkg.types.Scope().Lookup(name).(*types.TypeName)
+			if !ok || !obj.Exported() || obj.IsAlias() || !isStruct(obj.Type()) {
+				continue
+			}
+			if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				// Generic structs are named with their type arguments.
+				continue
+			}
+			if pattern != "all" {
+				if ok, err := filepath.Match(pattern, name); err != nil {
+					return nil, nil, "", errors.Wrapf(err, "-src %s: %s", srcName, err)
+				} else if !ok {
+					continue
+				}
+			}
+			dstName := strings.ReplaceAll(format, "%s", name)
+			if pkg.dir == dstPkg.dir && dstName == name {
+				continue
+			}
+			if obj, ok := dstPkg.types.Scope().Lookup(dstName).(*types.TypeName); !ok || !isStruct(obj.Type()) {
+				g.logger.printf(levelInfo, "skip %s: no struct %s in %s", name, dstName, dstPkg.name)
+				continue
+			}
+			if importPath != "" {
+				name = importPath + "." + name
+			}
+			srcs = append(srcs, name)
+			dsts = append(dsts, dstName)
+			n++
+		}
+		if n == 0 {
+			return nil, nil, "", errors.Errorf("-src %s matches no exported struct with a dst %s in %s", srcName, format, dstPkg.name)
+		}
+	}
+	return srcs, dsts, fileName, nil
+}
+
+// readHeader reads the header from the file. Lines that are not comments
+// yet are commented out, so that a plain license text can be used.
+func readHeader(fileName string) (string, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(strings.ReplaceAll(string(data), "\r\n", "\n"))
+	if strings.HasPrefix(text, "/*") && strings.HasSuffix(text, "*/") {
+		return text + "\n", nil
+	}
+	var header strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		switch line = strings.TrimRight(line, " \t"); {
+		case strings.HasPrefix(line, "//"):
+			header.WriteString(line)
+		case line == "":
+			header.WriteString("//")
+		default:
+			header.WriteString("// " + line)
+		}
+		header.WriteString("\n")
+	}
+	return header.String(), nil
+}
+
+// outputFile returns the output file name of opts, named after the dst
+// type in dir by the Filename template, the first one by default.
+func outputFile(opts Options, dir, dstName string, testFile bool) string {
+	if opts.Output != "" {
+		return opts.Output
+	}
+	baseName, err := outputBaseName(opts, dstName)
+	if err != nil {
+		// Reported by generate.
+		baseName = strings.ToLower(dstName) + "_repack.go"
+	}
+	if testFile {
+		baseName = strings.TrimSuffix(baseName, ".go") + "_test.go"
+	}
+	return filepath.Join(dir, baseName)
+}
+
+// filenameTemplate returns the template of the output file names of opts.
+func filenameTemplate(opts Options) string {
+	switch {
+	case opts.Filename != "":
+		return opts.Filename
+	case opts.Layout == "package":
+		return "repack_gen.go"
+	}
+	return "{{.Dst}}_repack.go"
+}
+
+// outputBaseName returns the name of the output file of the dst type by
+// the template of opts, which must name a Go file other than a test.
+func outputBaseName(opts Options, dstName string) (string, error) {
+	tmpl, err := template.New("filename").Option("missingkey=error").Parse(filenameTemplate(opts))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, struct{ Dst string }{strings.ToLower(dstName)}); err != nil {
+		return "", errors.WithStack(err)
+	}
+	name := buf.String()
+	if name == ".go" || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || strings.ContainsAny(name, `/\`) {
+		return "", errors.Errorf("%s is not the name of a Go file in the directory", name)
+	}
+	return name, nil
+}
+
+// templateActions matches the actions of a Filename template.
+var templateActions = regexp.MustCompile(`{{.*?}}`)
+
+// generatedGlob returns the glob of the file names of the template (e.g.
+// *_conv.go for {{.Dst}}_conv.go).
+func generatedGlob(filename string) string {
+	return templateActions.ReplaceAllString(filename, "*")
+}
+
+// generatedFiles returns the files of the directory holding generated
+// code: the *_repack.go ones, or with tests their _test.go files, and those
+// of generatedGlob or of -layout package marked as generated by repacker.
+func (g *Generator) generatedFiles(directory string, tests bool) []string {
+	globs := []string{"*_repack.go"}
+	if tests {
+		globs = append(globs, "*_repack_test.go", "*_repack_test_test.go")
+	}
+	names := map[string]bool{}
+	var files []string
+	for _, pattern := range globs {
+		matches, _ := filepath.Glob(filepath.Join(directory, pattern))
+		for _, name := range matches {
+			names[name] = true
+			files = append(files, name)
+		}
+	}
+	if abs, err := filepath.Abs(directory); err == nil {
+		for name := range g.stale {
+			if rel := filepath.Join(directory, filepath.Base(name)); filepath.Dir(name) == abs && !names[rel] {
+				names[rel] = true
+				files = append(files, rel)
+			}
+		}
+	}
+	globs = nil
+	for _, glob := range []string{g.generatedGlob, "repack_gen.go"} {
+		if glob == "" {
+			continue
+		}
+		globs = append(globs, glob)
+		if base := strings.TrimSuffix(glob, ".go"); tests {
+			globs = append(globs, base+"_test.go", base+"_test_test.go")
+		}
+	}
+	for _, pattern := range globs {
+		matches, _ := filepath.Glob(filepath.Join(directory, pattern))
+		for _, name := range matches {
+			if !names[name] && isGenerated(name) {
+				names[name] = true
+				files = append(files, name)
+			}
+		}
+	}
+	return files
+}
+
+// isGenerated reports whether the Go file was generated by repacker, with
+// its mark before the package clause.
+func isGenerated(name string) bool {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return false
+	}
+	if i := bytes.Index(data, []byte("\npackage ")); i >= 0 {
+		data = data[:i]
+	}
+	return bytes.Contains(data, []byte("// Code generated by \"repacker"))
+}
+
+// checkOverwrite reports whether the output file can be written: it does
+// not exist yet, or holds code generated by repacker, rather than a file
+// written by hand.
+func checkOverwrite(outputName string) error {
+	if _, err := os.Stat(outputName); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "Reading output: %s", err)
+	}
+	if !isGenerated(outputName) {
+		return errors.Errorf("%s was not generated by repacker; remove it, choose another output or use -force to overwrite it", outputName)
+	}
+	return nil
+}
+
+// checkOutput reports whether the file outputName holds srcCode,
+// printing a unified diff to standard output if it does not.
+func checkOutput(outputName string, srcCode []byte) error {
+	current, err := ioutil.ReadFile(outputName)
+	if os.IsNotExist(err) {
+		return errors.Errorf("%s does not exist", outputName)
+	} else if err != nil {
+		return errors.Wrapf(err, "Reading output: %s", err)
+	}
+	if bytes.Equal(current, srcCode) {
+		return nil
+	}
+	d, err := diff(current, srcCode, outputName)
+	if err != nil {
+		return errors.Wrapf(err, "diff: %s", err)
+	}
+	os.Stdout.Write(d)
+	return errors.Errorf("%s is out of date", outputName)
+}
+
+// typeCheck type-checks the package of the outputs, and of the test of
+// GenTest, with their code in place of the files, so that Run writes no
+// code that does not compile. Only the errors of the outputs are reported;
+// those of the other files are left to go build.
+func (r *result) typeCheck(outputs []output, testName string) error {
+	overlay := map[string][]byte{}
+	var names []string
+	add := func(name string, code []byte) error {
+		name, err := filepath.Abs(name)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		overlay[name] = code
+		names = append(names, name)
+		return nil
+	}
+	for _, o := range outputs {
+		if err := add(o.name, o.code); err != nil {
+			return err
+		}
+	}
+	tests := r.testFile
+	if r.test != nil {
+		if err := add(testName, r.test); err != nil {
+			return err
+		}
+		tests = true
+	}
+	// The stale files are removed.
+	for _, name := range r.stale {
+		f, err := parser.ParseFile(token.NewFileSet(), name, nil, parser.PackageClauseOnly)
+		if err != nil {
+			return errors.Wrapf(err, "parse %s: %s", name, err)
+		}
+		overlay[name] = []byte("package " + f.Name.Name + "\n")
+	}
+	dir := filepath.Dir(names[0])
+	cfg := &packages.Config{
+		Mode:       packages.NeedName | packages.NeedFiles | packages.NeedTypes,
+		Dir:        dir,
+		Env:        loadEnv(dir),
+		Tests:      tests,
+		Overlay:    overlay,
+		BuildFlags: r.buildFlags,
+	}
+	loading.RLock()
+	pkgs, err := packages.Load(cfg, ".")
+	loading.RUnlock()
+	if err != nil {
+		return errors.Wrapf(err, "type-check %s", dir)
+	}
+	var errs []string
+	seen := map[string]bool{}
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Errors {
+			for _, name := range names {
+				if strings.HasPrefix(e.Pos, name+":") && !seen[e.Error()] {
+					seen[e.Error()] = true
+					errs = append(errs, e.Error())
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("the generated code does not compile:\n\t%s", strings.Join(errs, "\n\t"))
+	}
+	return nil
+}
+
+// diff returns the unified diff of b1 and b2 by running diff -u,
+// as gofmt -d does.
+func diff(b1, b2 []byte, name string) ([]byte, error) {
+	f1, err := writeTempFile(b1)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+
+	f2, err := writeTempFile(b2)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+
+	data, err := exec.Command("diff", "-u", "--label", name+".orig", "--label", name, f1, f2).CombinedOutput()
+	if len(data) > 0 {
+		// diff exits with a non-zero status when the files don't match.
+		// Ignore that failure as long as we get output.
+		err = nil
+	}
+	return data, err
+}
+
+func writeTempFile(data []byte) (string, error) {
+	file, err := ioutil.TempFile("", "repacker")
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(data)
+	if err1 := file.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// Generator holds the state of the analysis. Primarily used to buffer
+// the output for format.Source.
+type Generator struct {
+	buf         bytes.Buffer
+	dir         string
+	funcNames   map[string]bool
+	methods     map[string]bool // funcNames generated as methods on src
+	nesting     int             // depth of the constructor being generated, 1 for the pairs
+	fset        *token.FileSet
+	packages    map[string]*Package // loaded by directory, shared by all the pairs
+	importDirs  map[string]string   // directories by source directory and import path
+	pkgNames    map[string]string   // names of the loaded packages and of their imports, by import path
+	names       *importNames        // names of the imports in the generated code
+	outPath     string              // import path of the package of the generated code
+	withError   bool
+	getters     bool // also read unexported src fields through GetX() methods
+	rules       bool // also note the rule of each mapped field in its comment
+	tagKey      string
+	matchTags   []string // tag keys matching fields after tagKey
+	match       string   // strategy matching the names left unmatched, if any
+	profile     string   // ORM of the entities whose columns match fields, if any
+	method      bool
+	strict      bool
+	allowEmpty  bool     // only warn of the converters mapping no dst field
+	unmapped    []string // dst fields without a src field, for -strict
+	skipNil     bool
+	mappings    []Mapping
+	args        []string // recorded in the head
+	buildTag    string   // //go:build expression of the head, if any
+	header      string   // comments at the top of the head, if any
+	imports     []string // import paths of the converter functions
+	params      []param  // extra parameters of the converters, for -params
+	testImports []string // import paths of the types of the test samples
+
+	typeConverters []Converter
+	report         Report
+
+	null        string // how invalid sql.Null* values map, for -null
+	optional    bool   // convert zero values into nil pointers, for -optional
+	deepCopy    bool   // deep-copy the references of the fields copied as is, for -deepcopy
+	funcOptions bool   // take functional options in the constructors, for -options
+	reverse     bool   // generating the reverse constructors of -bidirectional, which one-way options do not apply to
+	arraySlice  bool
+	generics    bool
+	calls       map[string]string // call formats of the collections converted by generic helpers, by funcName
+	helpers     map[string]bool   // generic helpers called
+
+	// shared are the files of the funcs and types of the other generated
+	// files of the package, by name, which are called instead of declared
+	// again.
+	shared map[string]string
+	// stale are the generated files left out, by absolute name, as they
+	// declare the pairs generated again (see generate).
+	stale map[string]bool
+
+	// deepCopyTypes are the types of the deep copies called, by funcName,
+	// generated with the helpers in the order of deepCopyNames.
+	deepCopyTypes map[string]types.Type
+	deepCopyNames []string
+
+	genTest    bool
+	genFuzz    bool
+	genBench   bool
+	testBuf    bytes.Buffer
+	converters map[string]*converter // by funcName, for -gentest
+	samples    map[string]*converter // by src type, for -gentest
+
+	buildContext build.Context
+	includeTests bool
+	testDecl     string        // a type of the code declared in a _test.go file, which the code must be too
+	cache        *packageCache // shared with other runs, if any
+	cacheDir     string        // of the on-disk cache, if any
+	logger       *logger
+
+	// generatedGlob matches the files named by the Filename template
+	// (e.g. *_conv.go), which hold generated code if marked so, besides
+	// the *_repack.go ones.
+	generatedGlob string
+}
+
+// converter is a generated converter, with the statements that populate
+// its srcs for the test and the dst fields the test expects to be set.
+type converter struct {
+	srcs       []Object
+	dst        Object
+	setup      []string
+	checked    []string
+	mapped     []string          // dst fields set from the srcs, for the diff
+	intact     map[string]string // checked dst fields copied as is, to the src fields they copy (e.g. s.Name)
+	fuzzed     []fuzzField       // src fields parsed by the converter, for -genfuzz
+	untestable string            // why the test cannot populate the srcs, if so
+}
+
+// fuzzField is a src field parsed by a converter. Strings and pointers to
+// them are set from the arguments of a fuzz target.
+type fuzzField struct {
+	src  string // param of the src
+	path string
+	typ  types.Type
+}
+
+// Mapping is the explicit field mapping of a src and dst pair,
+// read from the -mapping file. Src and Dst are type names.
+type Mapping struct {
+	Src         string            `json:"src"`
+	Dst         string            `json:"dst"`
+	Fields      map[string]string `json:"fields"`      // src field -> dst field
+	Ignore      []string          `json:"ignore"`      // dst fields left unset
+	Convert     map[string]string `json:"convert"`     // dst field -> converter function
+	Contributes []string          `json:"contributes"` // the only dst fields the src maps when merged, if set
+	Prefixes    map[string]string `json:"prefixes"`    // dst field prefix -> src path (e.g. Billing -> Billing for BillingCity)
+	// Oneofs map the cases of the oneof fields of protoc to plain fields
+	// of the other type, either way: oneof field -> case field -> field
+	// (e.g. Payload -> Text -> Body for the Text of Event_Text).
+	Oneofs map[string]map[string]string `json:"oneofs"`
+	// Impls are the concrete types of the interface fields of either
+	// type, converted into and, with a type switch, from them: interface
+	// field -> types (e.g. Payment -> Card and *Bank).
+	Impls map[string][]string `json:"impls"`
+}
+
+// readMappings reads the list of mappings from the YAML file, or from the
+// JSON file of another extension.
+func readMappings(fileName string) ([]Mapping, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if ext := strings.ToLower(filepath.Ext(fileName)); ext == ".yaml" || ext == ".yml" {
+		// The YAML is decoded by the json tags of Mapping.
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, errors.Wrapf(err, "%s", fileName)
+		}
+	}
+	var mappings []Mapping
+	if err = json.Unmarshal(data, &mappings); err != nil {
+		return nil, errors.Wrapf(err, "%s", fileName)
+	}
+	return mappings, nil
+}
+
+// parseMap parses the field mapping of -map (e.g. Src.Foo=Dst.Bar).
+func parseMap(value string) (Mapping, error) {
+	split := func(side string) (typeName, field string, err error) {
+		i := strings.Index(side, ".")
+		if i <= 0 || i == len(side)-1 {
+			return "", "", errors.Errorf("%s: want Type.Field", side)
+		}
+		return side[:i], side[i+1:], nil
+	}
+	sides := strings.Split(value, "=")
+	if len(sides) != 2 {
+		return Mapping{}, errors.Errorf("%s: want Src.Field=Dst.Field", value)
+	}
+	srcType, srcField, err := split(strings.TrimSpace(sides[0]))
+	if err != nil {
+		return Mapping{}, err
+	}
+	dstType, dstField, err := split(strings.TrimSpace(sides[1]))
+	if err != nil {
+		return Mapping{}, err
+	}
+	if strings.Contains(dstField, ".") {
+		return Mapping{}, errors.Errorf("%s: nested dst field %s", value, dstField)
+	}
+	return Mapping{Src: srcType, Dst: dstType, Fields: map[string]string{srcField: dstField}}, nil
+}
+
+// mergeMappings merges the mappings of each src and dst pair into the
+// first one, the fields of later mappings replacing those of earlier ones.
+// Src fields of a mapping are merged in the order of their names, so that
+// the last one wins if several map to the same dst field.
+func mergeMappings(mappings []Mapping) []Mapping {
+	var merged []Mapping
+	index := map[[2]string]int{}
+	for _, m := range mappings {
+		i, ok := index[[2]string{m.Src, m.Dst}]
+		if !ok {
+			index[[2]string{m.Src, m.Dst}] = len(merged)
+			merged = append(merged, Mapping{Src: m.Src, Dst: m.Dst})
+			i = len(merged) - 1
+		}
+		dst := &merged[i]
+		for _, srcName := range sortedKeys(m.Fields) {
+			dstName := m.Fields[srcName]
+			// A dst field is mapped from one src field, and no longer ignored.
+			for name, mappedName := range dst.Fields {
+				if mappedName == dstName {
+					delete(dst.Fields, name)
+				}
+			}
+			var ignore []string
+			for _, name := range dst.Ignore {
+				if name != dstName {
+					ignore = append(ignore, name)
+				}
+			}
+			if dst.Fields == nil {
+				dst.Fields = map[string]string{}
+			}
+			dst.Fields[srcName] = dstName
+			dst.Ignore = ignore
+		}
+		dst.Ignore = append(dst.Ignore, m.Ignore...)
+		dst.Contributes = append(dst.Contributes, m.Contributes...)
+		for prefix, path := range m.Prefixes {
+			if dst.Prefixes == nil {
+				dst.Prefixes = map[string]string{}
+			}
+			dst.Prefixes[prefix] = path
+		}
+		for dstName, convert := range m.Convert {
+			if dst.Convert == nil {
+				dst.Convert = map[string]string{}
+			}
+			dst.Convert[dstName] = convert
+		}
+		for field, cases := range m.Oneofs {
+			if dst.Oneofs == nil {
+				dst.Oneofs = map[string]map[string]string{}
+			}
+			dst.Oneofs[field] = cases
+		}
+		for field, impls := range m.Impls {
+			if dst.Impls == nil {
+				dst.Impls = map[string][]string{}
+			}
+			dst.Impls[field] = impls
+		}
+	}
+	return merged
+}
+
+// sortedOneofs returns the oneof fields of the oneofs of a mapping in
+// order, as sortedKeys.
+func sortedOneofs(oneofs map[string]map[string]string) []string {
+	fields := make([]string, 0, len(oneofs))
+	for field := range oneofs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// sortedKeys returns the keys of m in order, so that the generated code
+// does not depend on the order of iterating over the map.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mapping returns the mapping of the src and dst pair, or nil if none.
+func (g *Generator) mapping(src, dst Object) *Mapping {
+	for i, m := range g.mappings {
+		if m.Src == src.object.Name() && m.Dst == dst.object.Name() {
+			return &g.mappings[i]
+		}
+	}
+	return nil
+}
+
+// contributors returns the candidates, indexes of src fields of
+// fieldSrcs, that may map the dst field: those of the srcs whose mapping
+// contributes it if any, or else those of the srcs without a list of the
+// fields they contribute.
+func (g *Generator) contributors(candidates []int, fieldSrcs []Object, dst Object, dstName string) []int {
+	var listed, unlisted []int
+	for _, i := range candidates {
+		m := g.mapping(fieldSrcs[i], dst)
+		switch {
+		case m.contributes(dstName):
+			listed = append(listed, i)
+		case m == nil || len(m.Contributes) == 0:
+			unlisted = append(unlisted, i)
+		}
+	}
+	if len(listed) > 0 {
+		return listed
+	}
+	return unlisted
+}
+
+// ruleMatch is the src fields, by index, a rule matches with a dst field.
+type ruleMatch struct {
+	rule       string
+	candidates []int
+}
+
+// rankMatches orders the src fields matched with the dst field by the
+// -match strategy: those the constructor can read (exported, of a local
+// src or with a getter) first, and among them those differing only by case
+// before those differing by underscores too (e.g. UserID, then user_id,
+// for UserId).
+func (g *Generator) rankMatches(candidates []int, srcFields []Field, fieldSrcs []Object, dstName string) []int {
+	rank := func(i int) int {
+		var r int
+		if f := srcFields[i]; !f.Exported() && !fieldSrcs[i].local {
+			if _, err := lookupGetter(fieldSrcs[i].object, f.Var, g.getters); err != nil {
+				r += 2
+			}
+		}
+		if !strings.EqualFold(srcFields[i].Name(), dstName) {
+			r++
+		}
+		return r
+	}
+	ranked := append([]int(nil), candidates...)
+	sort.SliceStable(ranked, func(a, b int) bool { return rank(ranked[a]) < rank(ranked[b]) })
+	return ranked
+}
+
+// srcField returns the src field explicitly mapped to the dst field.
+func (m *Mapping) srcField(dstName string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	for srcName, name := range m.Fields {
+		if name == dstName {
+			return srcName, true
+		}
+	}
+	return "", false
+}
+
+// contributes reports whether the src of the mapping lists the dst field
+// among those it contributes.
+func (m *Mapping) contributes(dstName string) bool {
+	if m == nil {
+		return false
+	}
+	for _, name := range m.Contributes {
+		if name == dstName {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixPath returns the src path of the dst field named with one of the
+// prefixes, the longest if several (e.g. Billing.City for BillingCity).
+func (m *Mapping) prefixPath(dstName string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	best := ""
+	for prefix := range m.Prefixes {
+		rest := strings.TrimPrefix(dstName, prefix)
+		if rest == dstName || rest == "" || !unicode.IsUpper(rune(rest[0])) || len(prefix) <= len(best) {
+			continue
+		}
+		best = prefix
+	}
+	if best == "" {
+		return "", false
+	}
+	return m.Prefixes[best] + "." + dstName[len(best):], true
+}
+
+// ignored reports whether the dst field is left unset.
+func (m *Mapping) ignored(dstName string) bool {
+	if m == nil {
+		return false
+	}
+	for _, name := range m.Ignore {
+		if name == dstName {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Generator) parseFullTypeString(fullType string, pkg *Package) (Type, error) {
+	// Split full type (e.g. github.com/knqyf263/repackr.User)
+	importPath, typeName := splitType(fullType)
+	t := Type{
+		name: typeName,
+		dir:  pkg.dir, // default value
+	}
+	if importPath != "" && importPath != pkg.path {
+		dir, err := g.importDir(importPath, pkg.dir)
+		if err != nil {
+			return t, errors.Wrapf(err, "Import %s", importPath)
+		}
+		t.dir = dir
+		t.importPath = importPath
+	}
+	return t, nil
+}
+
+// importDir returns the directory of the package with the import path,
+// resolved from srcDir through the module graph as go build does, so that
+// packages in the module cache or a vendor directory are found too.
+func (g *Generator) importDir(importPath, srcDir string) (string, error) {
+	key := srcDir + " " + importPath
+	if dir, ok := g.importDirs[key]; ok {
+		return dir, nil
+	}
+	cfg := &packages.Config{
+		Mode:       packages.NeedName | packages.NeedFiles,
+		Dir:        srcDir,
+		Env:        loadEnv(srcDir),
+		BuildFlags: g.buildFlags(),
+	}
+	loading.RLock()
+	pkgs, err := packages.Load(cfg, importPath)
+	loading.RUnlock()
+	if err != nil {
+		return "", err
+	}
+	if len(pkgs) != 1 {
+		return "", errors.Errorf("%d packages match %s", len(pkgs), importPath)
+	}
+	if len(pkgs[0].Errors) > 0 {
+		return "", pkgs[0].Errors[0]
+	}
+	if len(pkgs[0].GoFiles) == 0 {
+		return "", errors.Errorf("no Go files in %s", importPath)
+	}
+	dir := filepath.Dir(pkgs[0].GoFiles[0])
+	g.importDirs[key] = dir
+	return dir, nil
+}
+
+// buildTags returns the tags, after those of -tags in GOFLAGS, which the
+// -tags flag of packages.Load would otherwise override.
+func buildTags(tags []string) []string {
+	var all []string
+	seen := map[string]bool{}
+	add := func(list string) {
+		for _, tag := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' }) {
+			if !seen[tag] {
+				seen[tag] = true
+				all = append(all, tag)
+			}
+		}
+	}
+	for _, flag := range strings.Fields(os.Getenv("GOFLAGS")) {
+		for _, prefix := range []string{"-tags=", "--tags="} {
+			if strings.HasPrefix(flag, prefix) {
+				add(strings.TrimPrefix(flag, prefix))
+			}
+		}
+	}
+	for _, tag := range tags {
+		add(tag)
+	}
+	return all
+}
+
+// buildFlags returns the flags of go build that apply the -tags.
+func (g *Generator) buildFlags() []string {
+	if len(g.buildContext.BuildTags) == 0 {
+		return nil
+	}
+	return []string{"-tags=" + strings.Join(g.buildContext.BuildTags, ",")}
+}
+
+func (g *Generator) parseType(t types.Type, pkg *Package) (Type, error) {
+	var typeName string
+	var isSlice, isMap, isPointer, isBasic bool
+	var mapKey types.Type
+	if s, ok := types.Unalias(t).(*types.Slice); ok {
+		isSlice = true
+		t = s.Elem()
+	} else if m, ok := types.Unalias(t).(*types.Map); ok {
+		isMap = true
+		mapKey = m.Key()
+		t = m.Elem()
+	}
+
+	if p, ok := types.Unalias(t).(*types.Pointer); ok {
+		isPointer = true
+		t = p.Elem()
+	}
+
+	var instance types.Type
+	switch s := types.Unalias(t).(type) {
+	case *types.Named:
+		typeName = s.String()
+		if isInstance(s) {
+			typeName, instance = s.Obj().Pkg().Path()+"."+s.Obj().Name(), s
+		} else if s.TypeArgs().Len() > 0 {
+			// The generic converter is called with the type parameters.
+			typeName = s.Obj().Pkg().Path() + "." + s.Obj().Name()
+		}
+	case *types.Struct:
+		typeName = s.String()
+	case *types.Basic:
+		isBasic = true
+		typeName = s.String()
+	default:
+		typeName = ""
+	}
+
+	typ, err := g.parseFullTypeString(typeName, pkg)
+	if err != nil {
+		return typ, err
+	}
+	typ.isSlice = isSlice
+	typ.isMap = isMap
+	typ.mapKey = mapKey
+	typ.isPointer = isPointer
+	typ.isBasic = isBasic
+	typ.instance = instance
+
+	return typ, nil
+}
+
+// isInstance reports whether t is a generic type instantiated with type
+// arguments other than type parameters (e.g. Page[User], not Page[T]).
+func isInstance(t *types.Named) bool {
+	if t.TypeArgs().Len() == 0 {
+		return false
+	}
+	for i := 0; i < t.TypeArgs().Len(); i++ {
+		if _, ok := t.TypeArgs().At(i).(*types.TypeParam); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sameTypeParams reports whether the generic types of a and b, or of their
+// elements, instantiated with type parameters (e.g. Box[T]) have the same
+// type arguments, which their generic converter keeps.
+func sameTypeParams(a, b types.Type) bool {
+	na, nb := elemNamed(a), elemNamed(b)
+	if na == nil || nb == nil || na.TypeArgs().Len() == 0 || isInstance(na) {
+		return true
+	}
+	return typeArgsString(na) == typeArgsString(nb)
+}
+
+// elemNamed returns the named type of t, or of the elements of t as
+// parseType reads them, if any.
+func elemNamed(t types.Type) *types.Named {
+	if s, ok := t.(*types.Slice); ok {
+		t = s.Elem()
+	} else if m, ok := t.(*types.Map); ok {
+		t = m.Elem()
+	}
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, _ := types.Unalias(t).(*types.Named)
+	return named
+}
+
+// typeArgsString returns the type arguments of t (e.g. T, K).
+func typeArgsString(t *types.Named) string {
+	var args []string
+	for i := 0; i < t.TypeArgs().Len(); i++ {
+		args = append(args, types.TypeString(t.TypeArgs().At(i), packagePath))
+	}
+	return strings.Join(args, ", ")
+}
+
+// typeArgName returns the name of the type argument t in the names of the
+// converters of instances (e.g. User for Page[User]).
+func typeArgName(t types.Type) string {
+	switch u := t.(type) {
+	case *types.Named:
+		name := u.Obj().Name()
+		for i := 0; i < u.TypeArgs().Len(); i++ {
+			name += typeArgName(u.TypeArgs().At(i))
+		}
+		return name
+	case *types.Basic:
+		return strings.Title(u.Name())
+	case *types.Pointer:
+		return "Ptr" + typeArgName(u.Elem())
+	case *types.Slice:
+		return typeArgName(u.Elem()) + "Slice"
+	case *types.Array:
+		return typeArgName(u.Elem()) + "Array"
+	case *types.Map:
+		return typeArgName(u.Key()) + typeArgName(u.Elem()) + "Map"
+	}
+	return ""
+}
+
+// parsePackageDir loads the package residing in the directory, type-checked
+// with go/packages so that module dependencies and replacements resolve as
+// in go build. Generated files are overlaid with their package clause only,
+// so that stale code doesn't break the type check.
+func (g *Generator) parsePackageDir(directory string) (*Package, error) {
+	if p, ok := g.packages[directory]; ok {
+		return p, nil
+	}
+	var p *Package
+	var err error
+	if g.cache != nil {
+		key := fmt.Sprintf("%s %v %s", directory, g.includeTests, strings.Join(g.buildFlags(), " "))
+		for _, name := range g.staleNames() {
+			// Loaded without the stale files.
+			key += " -" + name
+		}
+		p, err = g.cache.load(key, func() (*Package, error) {
+			return g.loadPackage(directory)
+		})
+	} else {
+		p, err = g.loadPackage(directory)
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.packages[directory] = p
+	// The types of the fields are declared in the package or its imports.
+	g.pkgNames[p.path] = p.name
+	for _, imp := range p.types.Imports() {
+		g.pkgNames[imp.Path()] = imp.Name()
+	}
+	return p, nil
+}
+
+// loadPackage loads the package of parsePackageDir.
+func (g *Generator) loadPackage(directory string) (*Package, error) {
+	overlay := map[string][]byte{}
+	for _, name := range g.generatedFiles(directory, true) {
+		f, err := parser.ParseFile(token.NewFileSet(), name, nil, parser.PackageClauseOnly)
+		if err != nil {
+			continue
+		}
+		if name, err = filepath.Abs(name); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		overlay[name] = []byte("package " + f.Name.Name + "\n")
+	}
+	cfg := &packages.Config{
+		Mode:       packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedImports,
+		Dir:        directory,
+		Env:        loadEnv(directory),
+		Tests:      g.includeTests,
+		Overlay:    overlay,
+		BuildFlags: g.buildFlags(),
+	}
+	var key string
+	if g.cacheDir != "" {
+		var err error
+		if key, err = cacheKey(cfg); err != nil {
+			g.logger.printf(levelWarn, "skip cache of %s: %s", directory, err)
+		} else if p := readCachedPackage(g.cacheDir, key, directory); p != nil {
+			return p, nil
+		}
+	}
+	loading.RLock()
+	pkgs, err := packages.Load(cfg, ".")
+	loading.RUnlock()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot process directory %s", directory)
+	}
+	pkg := selectPackage(pkgs)
+	if pkg == nil {
+		return nil, errors.Errorf("cannot process directory %s: no package", directory)
+	}
+	if len(pkg.Errors) > 0 && g.buildContext.CgoEnabled && strings.Contains(pkg.Errors[0].Msg, `could not import C `) {
+		// Without a C compiler, cgo files cannot be type-checked, but the
+		// types of the others still can.
+		g.logger.printf(levelWarn, "skip the cgo files of %s: %s", directory, pkg.Errors[0])
+		g.buildContext.CgoEnabled = false
+		if cfg.Env == nil {
+			cfg.Env = os.Environ()
+		}
+		cfg.Env = append(cfg.Env, "CGO_ENABLED=0")
+		loading.RLock()
+		pkgs, err = packages.Load(cfg, ".")
+		loading.RUnlock()
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot process directory %s", directory)
+		}
+		if pkg = selectPackage(pkgs); pkg == nil {
+			return nil, errors.Errorf("cannot process directory %s: no package", directory)
+		}
+	}
+	if len(pkg.Errors) > 0 {
+		return nil, errors.Errorf("cannot process directory %s: %s", directory, pkg.Errors[0])
+	}
+	p := &Package{
+		dir:   directory,
+		path:  pkg.PkgPath,
+		name:  pkg.Name,
+		types: pkg.Types,
+		docs:  typeDocs(pkg.Syntax),
+	}
+	if g.includeTests {
+		for _, f := range pkg.Syntax {
+			fileName := filepath.Base(pkg.Fset.File(f.Pos()).Name())
+			if !strings.HasSuffix(fileName, "_test.go") {
+				continue
+			}
+			for _, decl := range f.Decls {
+				if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.TYPE {
+					for _, spec := range decl.Specs {
+						if p.testTypes == nil {
+							p.testTypes = map[string]string{}
+						}
+						p.testTypes[spec.(*ast.TypeSpec).Name.Name] = fileName
+					}
+				}
+			}
+		}
+	}
+	if key != "" {
+		if err := writeCachedPackage(g.cacheDir, key, p, pkg.Fset); err != nil {
+			g.logger.printf(levelWarn, "skip cache of %s: %s", directory, err)
+		}
+	}
+	return p, nil
+}
+
+// typeDocs returns the doc comments of the types declared in the files and
+// of the fields of their structs, by name (e.g. User and User.Name).
+func typeDocs(files []*ast.File) map[string]string {
+	docs := map[string]string{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.TypeSpec)
+				doc := spec.Doc
+				if doc == nil && len(decl.Specs) == 1 {
+					doc = decl.Doc
+				}
+				if text := strings.TrimSpace(doc.Text()); text != "" {
+					docs[spec.Name.Name] = text
+				}
+				s, ok := spec.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				for _, field := range s.Fields.List {
+					doc := field.Doc
+					if doc == nil {
+						doc = field.Comment
+					}
+					text := strings.TrimSpace(doc.Text())
+					for _, name := range field.Names {
+						if text != "" {
+							docs[spec.Name.Name+"."+name.Name] = text
+						}
+					}
+				}
+			}
+		}
+	}
+	return docs
+}
+
+// commentLines returns the text as lines of comments, indented by indent.
+func commentLines(indent, text string) string {
+	var buf bytes.Buffer
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(&buf, "%s// %s\n", indent, line)
+	}
+	return strings.Replace(buf.String(), "// \n", "//\n", -1)
+}
+
+// selectPackage returns the package of the directory among those loaded
+// for it, compiled with its _test.go files if they were loaded.
+func selectPackage(pkgs []*packages.Package) *packages.Package {
+	var pkg *packages.Package
+	for _, p := range pkgs {
+		switch p.ID {
+		case p.PkgPath:
+			if pkg == nil {
+				pkg = p
+			}
+		case fmt.Sprintf("%s [%s.test]", p.PkgPath, p.PkgPath):
+			// The package compiled with its _test.go files.
+			pkg = p
+		}
+	}
+	return pkg
+}
+
+// outPackage returns the package of the generated code in g.dir: dstPkg,
+// or the package there named name if set, which may have no Go files yet.
+func (g *Generator) outPackage(dstPkg *Package, name string) (*Package, error) {
+	p := dstPkg
+	if p.dir != g.dir {
+		if names, _ := filepath.Glob(filepath.Join(g.dir, "*.go")); len(names) == 0 {
+			if name == "" {
+				name = filepath.Base(g.dir)
+			}
+			if !token.IsIdentifier(name) {
+				return nil, errors.Errorf("-outpkg: invalid package name %s", name)
+			}
+			p = &Package{dir: g.dir, name: name, types: types.NewPackage("", name)}
+			g.packages[g.dir] = p
+			return p, nil
+		}
+		var err error
+		if p, err = g.parsePackageDir(g.dir); err != nil {
+			return nil, err
+		}
+	}
+	if name != "" && name != p.name {
+		return nil, errors.Errorf("-outpkg %s does not match package %s in %s", name, p.name, g.dir)
+	}
+	return p, nil
+}
+
+// stdImports are the import paths of the standard packages the generated
+// code calls, by name (e.g. strconv.Itoa or fmt.Errorf).
+var stdImports = map[string]string{
+	"fmt":     "fmt",
+	"json":    "encoding/json",
+	"reflect": "reflect",
+	"sql":     "database/sql",
+	"strconv": "strconv",
+	"strings": "strings",
+	"testing": "testing",
+	"time":    "time",
+}
+
+// usedImports returns the import paths of the packages that the body of
+// code uses, from importPaths, the loaded packages and the standard
+// packages, so that goimports neither has to remove nor guess any.
+// A path of an unknown package name is kept for goimports.
+func (g *Generator) usedImports(code []byte, importPaths []string) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), "", append([]byte("package p\n"), code...), 0)
+	if err != nil {
+		// Left to goimport, which reports the error.
+		return importPaths
+	}
+	unresolved := map[*ast.Ident]bool{}
+	for _, id := range f.Unresolved {
+		unresolved[id] = true
+	}
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && unresolved[id] {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+
+	var paths []string
+	use := func(path, name string) {
+		if used[name] {
+			paths = append(paths, path)
+			delete(used, name)
+		}
+	}
+	for _, path := range importPaths {
+		if name, ok := g.pkgNames[path]; ok {
+			use(path, g.names.current(path, name))
+		} else {
+			paths = append(paths, path)
+		}
+	}
+	// The packages the code refers to, under their aliases if any, take
+	// their names first.
+	for _, path := range g.names.sortedPaths() {
+		if name, ok := g.pkgNames[path]; ok {
+			use(path, g.names.current(path, name))
+		}
+	}
+	for _, path := range sortedKeys(g.pkgNames) {
+		// The generated package itself is never imported.
+		if p, ok := g.packages[g.dir]; !ok || p.path != path {
+			use(path, g.pkgNames[path])
+		}
+	}
+	for name, path := range stdImports {
+		use(path, name)
+	}
+	return paths
+}
+
+// Printf prints
+func (g *Generator) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *Generator) generateHead(pkgName string, importPaths []string) {
+	if g.header != "" {
+		g.Printf("%s\n", g.header)
+	}
+	if g.buildTag != "" {
+		g.Printf("//go:build %s\n\n", g.buildTag)
+	}
+	g.Printf("// Code generated by \"%s\"; DO NOT EDIT\n", strings.Join(append([]string{"repacker"}, g.args...), " "))
+	g.Printf("\n")
+	g.Printf("package %s", pkgName)
+	g.Printf("\n")
+	imported := map[string]bool{}
+	for _, importPath := range importPaths {
+		if imported[importPath] {
+			continue
+		}
+		imported[importPath] = true
+		if alias := g.names.importName(importPath); alias != "" {
+			g.Printf("import %s \"%s\"\n", alias, imports.VendorlessPath(importPath))
+			continue
+		}
+		g.Printf("import \"%s\"\n", imports.VendorlessPath(importPath))
+	}
+}
+
+type Type struct {
+	dir        string
+	importPath string
+	name       string
+	isSlice    bool
+	isMap      bool
+	mapKey     types.Type
+	isPointer  bool
+	isBasic    bool
+	populate   string     // name of the method filling this type, if any
+	merge      string     // semantics of the merge function named populate instead (zero or nil), if any
+	method     bool       // whether it is converted by its own methods (e.g. d.ToBar())
+	byValue    bool       // whether its constructor takes and returns values instead of pointers, for -byvalue
+	named      bool       // whether a slice or map gets a function of its own even with -generics
+	instance   types.Type // the instance of a generic type (e.g. Page[User]), converted by a function of its own
+}
+
+func (g *Generator) generate(srcType, dstType Type) (funcName string, err error) {
+	if reflect.DeepEqual(srcType, dstType) {
+		g.logger.printf(levelDebug, "same type")
+		return "", nil
+	}
+	if srcType.isSlice != dstType.isSlice {
+		return "", errors.New("One type is slice")
+	}
+	if srcType.isMap != dstType.isMap {
+		return "", errors.New("One type is map")
+	}
+	if srcType.isMap && types.TypeString(srcType.mapKey, packagePath) != types.TypeString(dstType.mapKey, packagePath) {
+		return "", errors.New("Map key types differ")
+	}
+	srcPkg, err := g.parsePackageDir(srcType.dir)
+	if err != nil {
+		return "", err
+	}
+	dstPkg, err := g.parsePackageDir(dstType.dir)
+	if err != nil {
+		return "", err
+	}
+
+	srcObj, err := g.lookup(srcPkg, srcType)
+	if err != nil {
+		return "", errors.Wrapf(err, "Lookup: %s.%s", srcPkg.name, srcType.name)
+	}
+
+	dstObj, err := g.lookup(dstPkg, dstType)
+	if err != nil {
+		return "", errors.Wrapf(err, "Lookup: %s.%s", dstPkg.name, dstType.name)
+	}
+
+	if srcObj == nil || dstObj == nil {
+		return "", errors.New("package not found")
+	}
+	if srcType.instance != nil {
+		srcObj = types.NewTypeName(srcObj.Pos(), srcObj.Pkg(), srcObj.Name(), srcType.instance)
+	}
+	if dstType.instance != nil {
+		dstObj = types.NewTypeName(dstObj.Pos(), dstObj.Pkg(), dstObj.Name(), dstType.instance)
+	}
+	if !isStruct(srcObj.Type()) || !isStruct(dstObj.Type()) {
+		return "", errors.Errorf("%s and %s must be structs", srcObj.Name(), dstObj.Name())
+	}
+
+	src := Object{
+		pkg:    srcPkg,
+		typ:    srcType,
+		object: srcObj,
+		local:  srcPkg.dir == g.dir,
+		param:  "s",
+		names:  g.names,
+	}
+	dst := Object{
+		pkg:    dstPkg,
+		typ:    dstType,
+		object: dstObj,
+		local:  dstPkg.dir == g.dir,
+		names:  g.names,
+	}
+	srcParams := src.typeParams()
+	dst.typeParams()
+	if src.typeArgs != dst.typeArgs && (srcType.instance == nil || dstType.instance == nil) {
+		return "", errors.Errorf("type parameters of %s%s and %s%s do not match",
+			srcObj.Name(), src.typeArgs, dstObj.Name(), dst.typeArgs)
+	}
+	if srcParams != "" && (srcType.isSlice || srcType.isMap) && g.generics {
+		return "", errors.Errorf("-generics cannot convert collections of generic type %s", srcObj.Name())
+	}
+
+	if srcType.isSlice {
+		return g.generateSliceCode(src, dst)
+	}
+	if srcType.isMap {
+		return g.generateMapCode(src, dst)
+	}
+
+	return g.generateCode([]Object{src}, dst)
+}
+
+// generateCollections generates the constructors of map[K]*dst from
+// map[K]*src, for any comparable K, and of []*dst from []*src, together
+// with the constructor of dst from src. The map comes first, so that
+// the fields of the same map type in dst call it rather than a helper
+// of their own key type.
+func (g *Generator) generateCollections(srcType, dstType Type) error {
+	srcType.isPointer, dstType.isPointer = true, true
+	srcType.named, dstType.named = true, true
+	srcMap, dstMap := srcType, dstType
+	srcMap.isMap, dstMap.isMap = true, true
+	if _, err := g.generate(srcMap, dstMap); err != nil {
+		return err
+	}
+	srcSlice, dstSlice := srcType, dstType
+	srcSlice.isSlice, dstSlice.isSlice = true, true
+	_, err := g.generate(srcSlice, dstSlice)
+	return err
+}
+
+// generateMerged generates the constructor of dst from all of the srcs,
+// mapping each dst field from the first src with a matching field.
+func (g *Generator) generateMerged(srcTypes []Type, dstType Type) (funcName string, err error) {
+	dstPkg, err := g.parsePackageDir(dstType.dir)
+	if err != nil {
+		return "", err
+	}
+
+	dstObj, err := g.lookup(dstPkg, dstType)
+	if err != nil {
+		return "", errors.Wrapf(err, "Lookup: %s.%s", dstPkg.name, dstType.name)
+	}
+	if !isStruct(dstObj.Type()) {
+		return "", errors.Errorf("%s must be a struct", dstObj.Name())
+	}
+	dst := Object{
+		pkg:    dstPkg,
+		typ:    dstType,
+		object: dstObj,
+		local:  dstPkg.dir == g.dir,
+		names:  g.names,
+	}
+	dst.typeParams()
+
+	var srcs []Object
+	params := map[string]bool{}
+	for _, srcType := range srcTypes {
+		if srcType.isSlice || srcType.isMap {
+			return "", errors.Errorf("cannot merge collections of %s", srcType.name)
+		}
+		srcPkg, err := g.parsePackageDir(srcType.dir)
+		if err != nil {
+			return "", err
+		}
+		srcObj, err := g.lookup(srcPkg, srcType)
+		if err != nil {
+			return "", errors.Wrapf(err, "Lookup: %s.%s", srcPkg.name, srcType.name)
+		}
+		if !isStruct(srcObj.Type()) {
+			return "", errors.Errorf("%s must be a struct", srcObj.Name())
+		}
+		src := Object{
+			pkg:    srcPkg,
+			typ:    srcType,
+			object: srcObj,
+			local:  srcPkg.dir == g.dir,
+			param:  strings.ToLower(srcObj.Name()[:1]),
+			names:  g.names,
+		}
+		src.typeParams()
+		if src.typeArgs != dst.typeArgs {
+			return "", errors.Errorf("type parameters of %s%s and %s%s do not match",
+				srcObj.Name(), src.typeArgs, dstObj.Name(), dst.typeArgs)
+		}
+		params[src.param] = true
+		srcs = append(srcs, src)
+	}
+	// Name the params after the srcs (e.g. u for User) unless the names
+	// clash, also with the receiver and with the t of the generated test.
+	if len(params) < len(srcs) || params["d"] || params["t"] {
+		for i := range srcs {
+			srcs[i].param = fmt.Sprintf("s%d", i+1)
+		}
+	}
+
+	return g.generateCode(srcs, dst)
+}
+
+// handWritten reports whether the package of the generated code already
+// declares the constructor funcName of dst from srcs (e.g. to tune a
+// tricky conversion by hand), which is then called instead of generated.
+// It fails if the function has another signature than the generated one.
+func (g *Generator) handWritten(funcName string, srcs []Object, dst Object) (bool, error) {
+	pkg, err := g.parsePackageDir(g.dir)
+	if err != nil {
+		// A new -outdir package declares nothing yet.
+		return false, nil
+	}
+	fn, ok := pkg.types.Scope().Lookup(funcName).(*types.Func)
+	if !ok {
+		return false, nil
+	}
+	var params, results []*types.Var
+	for _, p := range g.params {
+		params = append(params, types.NewParam(token.NoPos, nil, p.name, p.typ))
+	}
+	for _, src := range srcs {
+		params = append(params, types.NewParam(token.NoPos, nil, "", types.NewPointer(src.object.Type())))
+	}
+	results = append(results, types.NewParam(token.NoPos, nil, "", types.NewPointer(dst.object.Type())))
+	if g.withError {
+		results = append(results, types.NewParam(token.NoPos, nil, "", types.Universe.Lookup("error").Type()))
+	}
+	want := types.NewSignatureType(nil, nil, nil, types.NewTuple(params...), types.NewTuple(results...), false)
+	sig := fn.Type().(*types.Signature)
+	if sig.Variadic() || !sameTypes(sig.Params(), want.Params()) || !sameTypes(sig.Results(), want.Results()) {
+		return false, errors.Errorf("%s of the package is %s, not %s", funcName,
+			types.TypeString(sig, packageName), types.TypeString(want, packageName))
+	}
+	return true, nil
+}
+
+// sameTypes reports whether the variables of a and b are of the same
+// types, compared by import path, as the packages may be loaded apart.
+func sameTypes(a, b *types.Tuple) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	for i := 0; i < a.Len(); i++ {
+		if types.TypeString(a.At(i).Type(), nil) != types.TypeString(b.At(i).Type(), nil) {
+			return false
+		}
+	}
+	return true
+}
+
+// todoReason returns the reason a field was skipped without the prefix
+// naming the field (e.g. "type mismatch int vs string" for "skip field
+// (ID): type mismatch int vs string").
+func todoReason(reason string) string {
+	if !strings.HasPrefix(reason, "skip ") {
+		return reason
+	}
+	if i := strings.Index(reason, ")"); i >= 0 {
+		reason = strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(reason[i+1:], ":")), "due to ")
+	}
+	return reason
+}
+
+// checkMapped fails if the converter funcName maps none of the fields of
+// its dst, even by default, but skips some of them, which almost always
+// means that the src or dst is the wrong type, unless allowEmpty. The
+// dst fields may all be ignored on purpose.
+func (g *Generator) checkMapped(funcName string) error {
+	conv := g.converters[funcName]
+	if conv == nil {
+		return nil
+	}
+	skipped := false
+	for _, report := range g.report.Converters {
+		if report.Func != funcName {
+			continue
+		}
+		for _, field := range report.Fields {
+			switch field.Status {
+			case "mapped":
+				return nil
+			case "skipped":
+				skipped = true
+			}
+		}
+	}
+	if !skipped {
+		return nil
+	}
+	var srcNames []string
+	for _, src := range conv.srcs {
+		srcNames = append(srcNames, types.TypeString(src.object.Type(), packageName))
+	}
+	msg := fmt.Sprintf("%s maps no field of %s from %s", funcName, conv.dst.object.Name(), strings.Join(srcNames, " and "))
+	if g.allowEmpty {
+		g.logger.printf(levelWarn, "%s", msg)
+		return nil
+	}
+	return errors.Errorf("%s; check -src and -dst, or set -allowempty", msg)
+}
+
+func (g *Generator) lookup(pkg *Package, typ Type) (types.Object, error) {
+	g.logger.printf(levelDebug, "Lookup %s.%s", pkg.name, typ.name)
+	obj := pkg.types.Scope().Lookup(typ.name)
+	if fileName, ok := pkg.testTypes[typ.name]; ok && obj != nil {
+		// Only the tests of the package see its _test.go files.
+		if pkg.dir != g.dir {
+			return nil, fmt.Errorf("%s is declared in %s, a _test.go file of another package than the generated code", typ.name, fileName)
+		}
+		g.testDecl = fmt.Sprintf("%s is declared in %s", typ.name, fileName)
+	}
+	if obj == nil {
+		if name, reason := g.excludedDecl(pkg.dir, typ.name); name != "" {
+			return nil, fmt.Errorf("Failed to lookup: %s, declared in %s excluded by %s", typ.name, name, reason)
+		}
+		return nil, fmt.Errorf("Failed to lookup: %s", typ.name)
+	}
+	return obj, nil
+}
+
+// excludedDecl returns the file of dir declaring the type name that the
+// build excludes, and why, if any.
+func (g *Generator) excludedDecl(dir, name string) (string, string) {
+	fileNames, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, fileName := range fileNames {
+		if strings.HasSuffix(fileName, "_test.go") && !g.includeTests {
+			continue
+		}
+		matched, err := g.buildContext.MatchFile(dir, filepath.Base(fileName))
+		if err != nil || matched && g.buildContext.CgoEnabled {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), fileName, nil, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		// MatchFile leaves the cgo files to the build.
+		cgo := false
+		for _, imp := range f.Imports {
+			cgo = cgo || imp.Path.Value == `"C"`
+		}
+		if matched && !cgo {
+			continue
+		}
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				if spec.(*ast.TypeSpec).Name.Name != name {
+					continue
+				}
+				if matched {
+					return filepath.Base(fileName), "its import of C; enable cgo with a C compiler (CGO_ENABLED=1 and CC)"
+				}
+				reason := "its file name"
+				for _, group := range f.Comments {
+					if group.Pos() > f.Package {
+						break
+					}
+					for _, c := range group.List {
+						if constraint.IsGoBuild(c.Text) {
+							reason = c.Text
+						}
+					}
+				}
+				return filepath.Base(fileName), reason + "; set -tags or GOOS and GOARCH as for go build"
+			}
+		}
+	}
+	return "", ""
+}
+
+type Object struct {
+	pkg      *Package
+	typ      Type
+	object   types.Object
+	local    bool   // whether the object lives in the package of the generated code
+	typeArgs string // type arguments of a generic type (e.g. [K, V])
+	param    string // name of the src parameter in the generated code
+	names    *importNames
+}
+
+// funcName returns the name of the type in the names of the converters,
+// with the type arguments of an instance (e.g. PageUser for Page[User]).
+func (o Object) funcName() string {
+	name := o.object.Name()
+	if named, ok := o.object.Type().(*types.Named); ok && isInstance(named) {
+		name = typeArgName(named)
+	}
+	return name
+}
+
+func (o Object) Name() string {
+	return fmt.Sprintf("*%s%s", o.object.Name(), o.typeArgs)
+}
+func (o Object) SliceName() (name string) {
+	if (o.typ.isSlice || o.typ.isMap) && !o.typ.isPointer {
+		return o.object.Name() + o.typeArgs
+	}
+	return o.Name()
+}
+
+func (o Object) FullName() string {
+	if o.local {
+		return o.Name()
+	}
+	return fmt.Sprintf("*%s.%s%s", o.pkgName(), o.object.Name(), o.typeArgs)
+}
+
+func (o Object) SliceFullName() (name string) {
+	if o.local {
+		return o.SliceName()
+	}
+	if (o.typ.isSlice || o.typ.isMap) && !o.typ.isPointer {
+		return fmt.Sprintf("%s.%s%s", o.pkgName(), o.object.Name(), o.typeArgs)
+	}
+	return o.FullName()
+}
+
+func (o Object) PtrName() string {
+	if o.local {
+		return fmt.Sprintf("&%s%s", o.object.Name(), o.typeArgs)
+	}
+	return fmt.Sprintf("&%s.%s%s", o.pkgName(), o.object.Name(), o.typeArgs)
+}
+
+// pkgName returns the name the generated code refers to the package of
+// the object by, its alias if its name is taken.
+func (o Object) pkgName() string {
+	return o.names.name(o.pkg.path, o.pkg.name)
+}
+
+// qualifier qualifies types in generated code by package name, or by the
+// alias of the import, leaving types of the generated package unqualified.
+func (o Object) qualifier(p *types.Package) string {
+	if o.local && p == o.object.Pkg() {
+		return ""
+	}
+	return o.names.name(p.Path(), p.Name())
+}
+
+// typeParams returns the type parameter list of the generic type
+// (e.g. [K comparable, V any]) and sets the type arguments to match.
+func (o *Object) typeParams() string {
+	named, ok := o.object.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() == 0 {
+		return ""
+	}
+	if isInstance(named) {
+		// An instance is converted by a function of its own.
+		var args []string
+		for i := 0; i < named.TypeArgs().Len(); i++ {
+			args = append(args, types.TypeString(named.TypeArgs().At(i), o.qualifier))
+		}
+		o.typeArgs = fmt.Sprintf("[%s]", strings.Join(args, ", "))
+		return ""
+	}
+	var params, args []string
+	for i := 0; i < named.TypeParams().Len(); i++ {
+		tp := named.TypeParams().At(i)
+		params = append(params, fmt.Sprintf("%s %s", tp.Obj().Name(), types.TypeString(tp.Constraint(), o.qualifier)))
+		args = append(args, tp.Obj().Name())
+	}
+	o.typeArgs = fmt.Sprintf("[%s]", strings.Join(args, ", "))
+	return fmt.Sprintf("[%s]", strings.Join(params, ", "))
+}
+
+// Field is a struct field, possibly promoted from an embedded struct.
+type Field struct {
+	*types.Var
+	tag   string
+	path  string // selector from the struct value (e.g. BaseModel.ID)
+	depth int
+}
+
+// structFields returns the fields of the struct together with the fields
+// promoted from its embedded structs. As in Go, a shallower field shadows
+// deeper ones of the same name, and names that are ambiguous at the same
+// depth are dropped. Embedded pointers are not followed to avoid nil
+// dereferences.
+func structFields(s *types.Struct) []Field {
+	var all []Field
+	var collect func(s *types.Struct, prefix string, depth int)
+	collect = func(s *types.Struct, prefix string, depth int) {
+		for i := 0; i < s.NumFields(); i++ {
+			v := s.Field(i)
+			f := Field{Var: v, tag: s.Tag(i), path: prefix + v.Name(), depth: depth}
+			all = append(all, f)
+			if embedded, ok := v.Type().Underlying().(*types.Struct); ok && v.Anonymous() {
+				collect(embedded, f.path+".", depth+1)
+			}
+		}
+	}
+	collect(s, "", 0)
+
+	minDepth := map[string]int{}
+	count := map[string]int{}
+	for _, f := range all {
+		d, ok := minDepth[f.Name()]
+		switch {
+		case !ok || f.depth < d:
+			minDepth[f.Name()] = f.depth
+			count[f.Name()] = 1
+		case f.depth == d:
+			count[f.Name()]++
+		}
+	}
+	var fields []Field
+	for _, f := range all {
+		if f.depth == minDepth[f.Name()] && count[f.Name()] == 1 {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// unalias returns t with its aliases resolved, also in the types it is
+// composed of (e.g. []int64 for []ID, with type ID = int64).
+func unalias(t types.Type) types.Type {
+	switch u := types.Unalias(t).(type) {
+	case *types.Pointer:
+		return types.NewPointer(unalias(u.Elem()))
+	case *types.Slice:
+		return types.NewSlice(unalias(u.Elem()))
+	case *types.Array:
+		return types.NewArray(unalias(u.Elem()), u.Len())
+	case *types.Map:
+		return types.NewMap(unalias(u.Key()), unalias(u.Elem()))
+	case *types.Chan:
+		return types.NewChan(u.Dir(), unalias(u.Elem()))
+	default:
+		return u
+	}
+}
+
+// pathCode resolves the dotted path of fields (e.g. Address.City) from the
+// src struct. It returns the selector, the nil checks of the pointers along
+// the path and the type of the last field.
+func pathCode(src Object, path string) (selector string, nilChecks []string, typ types.Type, err error) {
+	selector = src.param
+	typ = src.object.Type()
+	for i, name := range strings.Split(path, ".") {
+		if p, ok := typ.(*types.Pointer); ok && i > 0 {
+			nilChecks = append(nilChecks, selector+" != nil")
+			typ = p.Elem()
+		}
+		if _, ok := typ.Underlying().(*types.Struct); !ok {
+			return "", nil, nil, fmt.Errorf("%s is not a struct", selector)
+		}
+		obj, _, _ := types.LookupFieldOrMethod(typ, true, src.object.Pkg(), name)
+		field, ok := obj.(*types.Var)
+		if !ok || !field.IsField() {
+			return "", nil, nil, fmt.Errorf("%s has no field %s", selector, name)
+		}
+		if !field.Exported() && !src.local {
+			return "", nil, nil, fmt.Errorf("%s.%s is unexported", selector, name)
+		}
+		selector += "." + name
+		typ = field.Type()
+	}
+	return selector, nilChecks, typ, nil
+}
+
+// srcsPathCode resolves the dotted path of fields as pathCode does, from
+// the first of the srcs with the path. The provenance names the path in
+// the comment of the generated code.
+func srcsPathCode(srcs []Object, path string) (selector string, nilChecks []string, typ types.Type, provenance string, err error) {
+	for _, src := range srcs {
+		selector, nilChecks, typ, err = pathCode(src, path)
+		if err == nil {
+			provenance = path
+			if len(srcs) > 1 {
+				provenance = src.param + "." + path
+			}
+			return selector, nilChecks, typ, provenance, nil
+		}
+	}
+	return "", nil, nil, "", err
+}
+
+// lookupGetter returns the name of the exported method of obj that returns
+// the unexported field, e.g. Secret() for secret or ID() for id, and with
+// prefixed GetSecret() too, for -getters.
+func lookupGetter(obj types.Object, field *types.Var, prefixed bool) (string, error) {
+	names := getterNames(field.Name())
+	if prefixed {
+		for _, name := range names {
+			names = append(names, "Get"+name)
+		}
+	}
+	for _, name := range names {
+		m, _, _ := types.LookupFieldOrMethod(types.NewPointer(obj.Type()), true, obj.Pkg(), name)
+		getter, ok := m.(*types.Func)
+		if !ok {
+			continue
+		}
+		sig := getter.Type().(*types.Signature)
+		if sig.Params().Len() != 0 || sig.Results().Len() != 1 ||
+			!types.Identical(sig.Results().At(0).Type(), field.Type()) {
+			return "", fmt.Errorf("getter %s() must take no arguments and return %s", name, field.Type())
+		}
+		return name, nil
+	}
+	return "", fmt.Errorf("unexported field without getter %s()", strings.Join(names, "() or "))
+}
+
+// lookupSetter returns the name of the exported method of *obj that sets
+// the unexported field, e.g. SetSecret(v) for secret or SetID(v) for id.
+func lookupSetter(obj types.Object, field *types.Var) (string, error) {
+	var names []string
+	for _, name := range getterNames(field.Name()) {
+		names = append(names, "Set"+name)
+	}
+	for _, name := range names {
+		m, _, _ := types.LookupFieldOrMethod(types.NewPointer(obj.Type()), true, obj.Pkg(), name)
+		setter, ok := m.(*types.Func)
+		if !ok {
+			continue
+		}
+		sig := setter.Type().(*types.Signature)
+		if sig.Params().Len() != 1 || sig.Results().Len() != 0 ||
+			!types.Identical(sig.Params().At(0).Type(), field.Type()) {
+			return "", fmt.Errorf("setter %s() must take %s and return nothing", name, field.Type())
+		}
+		return name, nil
+	}
+	return "", fmt.Errorf("unexported field without setter %s()", strings.Join(names, "() or "))
+}
+
+// commonInitialisms are the words getters spell in upper case (e.g. ID()
+// for id), after golint.
+var commonInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true,
+	"EOF": true, "GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true,
+	"IP": true, "JSON": true, "LHS": true, "QPS": true, "RAM": true, "RHS": true,
+	"RPC": true, "SLA": true, "SMTP": true, "SQL": true, "SSH": true, "TCP": true,
+	"TLS": true, "TTL": true, "UDP": true, "UI": true, "UID": true, "UUID": true,
+	"URI": true, "URL": true, "UTF8": true, "VM": true, "XML": true, "XMPP": true,
+	"XSRF": true, "XSS": true,
+}
+
+// getterNames returns the names of the getter of the unexported field: its
+// name in title case, and with its initialisms in upper case if that
+// differs (e.g. UserId and UserID for userId).
+func getterNames(field string) []string {
+	title := strings.Title(field)
+	var words []string
+	start := 0
+	for i, r := range title {
+		if i > 0 && unicode.IsUpper(r) {
+			words = append(words, title[start:i])
+			start = i
+		}
+	}
+	words = append(words, title[start:])
+	for i, word := range words {
+		if commonInitialisms[strings.ToUpper(word)] {
+			words[i] = strings.ToUpper(word)
+		}
+	}
+	if upper := strings.Join(words, ""); upper != title {
+		return []string{title, upper}
+	}
+	return []string{title}
+}
+
+// lookupTag returns the name in the struct tag under the tag key,
+// without options such as ",omitempty".
+func (g *Generator) lookupTag(tag string) (string, bool) {
+	return lookupTagKey(tag, g.tagKey)
+}
+
+// lookupTagKey returns the name in the struct tag under the key.
+func lookupTagKey(tag, key string) (string, bool) {
+	value, ok := reflect.StructTag(tag).Lookup(key)
+	if !ok {
+		return "", false
+	}
+	name := strings.Split(value, ",")[0]
+	return name, name != ""
+}
+
+// fieldOption returns the value of the option (e.g. layout=2006-01-02)
+// set in the tag of the dst field, or else of the src field.
+func (g *Generator) fieldOption(dstTag, srcTag, option string) (string, bool) {
+	for _, tag := range []string{dstTag, srcTag} {
+		value, ok := reflect.StructTag(tag).Lookup(g.tagKey)
+		if !ok {
+			continue
+		}
+		for _, opt := range strings.Split(value, ",")[1:] {
+			if strings.HasPrefix(opt, option+"=") {
+				return strings.TrimPrefix(opt, option+"="), true
+			}
+		}
+	}
+	return "", false
+}
+
+// identical reports whether a and b are the same type. Types of the src
+// package are compared by import path and name, because the src package
+// and the copy imported by the dst package are type-checked separately,
+// and by the types their aliases denote.
+func identical(a, b types.Type) bool {
+	return types.Identical(a, b) || types.TypeString(unalias(a), packagePath) == types.TypeString(unalias(b), packagePath)
+}
+
+// assignable reports whether a value of type a can be assigned to b as is,
+// e.g. a concrete type to an interface it implements.
+func assignable(a, b types.Type) bool {
+	return identical(a, b) || types.AssignableTo(a, b)
+}
+
+// sameUnderlying reports whether a converts to b because both types, or
+// the types both pointers point to, have identical underlying types.
+// Structs are left to the generated converters.
+func sameUnderlying(a, b types.Type) bool {
+	if pa, ok := a.(*types.Pointer); ok {
+		pb, ok := b.(*types.Pointer)
+		if !ok {
+			return false
+		}
+		a, b = pa.Elem(), pb.Elem()
+	}
+	return !isStruct(a) && identical(a.Underlying(), b.Underlying())
+}
+
+// castable reports whether a converts to b by a plain conversion, as
+// between types of the same underlying type or between numeric types.
+// Complex numbers only convert to complex numbers.
+func castable(a, b types.Type) bool {
+	if sameUnderlying(a, b) {
+		return true
+	}
+	ba, ok := a.Underlying().(*types.Basic)
+	if !ok {
+		return false
+	}
+	bb, ok := b.Underlying().(*types.Basic)
+	return ok && ba.Info()&types.IsNumeric != 0 && bb.Info()&types.IsNumeric != 0 &&
+		ba.Info()&types.IsComplex == bb.Info()&types.IsComplex
+}
+
+// narrowing reports whether the numeric conversion of a to b may lose
+// values, as from int64 to int32, from floats to integers or from signed
+// to unsigned integers. Sizes are those of the target architecture.
+func (g *Generator) narrowing(a, b types.Type) bool {
+	ba, ok := a.Underlying().(*types.Basic)
+	if !ok || ba.Info()&types.IsNumeric == 0 {
+		return false
+	}
+	bb, ok := b.Underlying().(*types.Basic)
+	if !ok || bb.Info()&types.IsNumeric == 0 {
+		return false
+	}
+	sizes := types.SizesFor("gc", g.buildContext.GOARCH)
+	if sizes == nil {
+		sizes = types.SizesFor("gc", "amd64")
+	}
+	sa, sb := sizes.Sizeof(ba), sizes.Sizeof(bb)
+	ia, ib := ba.Info(), bb.Info()
+	switch {
+	case ia&types.IsInteger != 0 && ib&types.IsInteger != 0:
+		if ia&types.IsUnsigned == ib&types.IsUnsigned {
+			return sb < sa
+		}
+		// Only a larger signed integer holds every unsigned one.
+		return ia&types.IsUnsigned == 0 || sb <= sa
+	case ia&types.IsInteger != 0 && ib&types.IsFloat != 0:
+		// float32 holds 24 bits of integers exactly and float64 53 bits.
+		return sa >= sb
+	case ia&types.IsFloat != 0 && ib&types.IsFloat != 0,
+		ia&types.IsComplex != 0 && ib&types.IsComplex != 0:
+		return sb < sa
+	}
+	return true
+}
+
+// isStruct reports whether the underlying type of t is a struct.
+func isStruct(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Struct)
+	return ok
+}
+
+// isBytes reports whether the underlying type of t is a slice of bytes.
+func isBytes(t types.Type) bool {
+	s, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	b, ok := s.Elem().Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Byte
+}
+
+// isString reports whether the underlying type of t is string.
+func isString(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == types.String
+}
+
+// durationUnits are the constants of the units of the unit option.
+var durationUnits = map[string]string{
+	"ns": "time.Nanosecond",
+	"us": "time.Microsecond",
+	"µs": "time.Microsecond",
+	"ms": "time.Millisecond",
+	"s":  "time.Second",
+	"m":  "time.Minute",
+	"h":  "time.Hour",
+}
+
+// isDuration reports whether t is time.Duration.
+func isDuration(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Duration"
+}
+
+// durationCode returns the conversion of expr of type src into dst, one of
+// them time.Duration and the other a number of the unit (e.g. ms for
+// int64(s.Timeout / time.Millisecond)).
+func durationCode(expr string, src, dst types.Type, unit, dstName string) (string, error) {
+	unitCode, ok := durationUnits[unit]
+	if !ok {
+		return "", errors.Errorf("unknown unit %q; use ns, us, ms, s, m or h", unit)
+	}
+	float := func(t types.Type) bool {
+		b, ok := t.Underlying().(*types.Basic)
+		return ok && b.Info()&types.IsFloat != 0
+	}
+	switch {
+	case isDuration(src) && float(dst):
+		return fmt.Sprintf("%s / %s", conversionCode(dstName, expr), conversionCode(dstName, unitCode)), nil
+	case isDuration(src):
+		return conversionCode(dstName, fmt.Sprintf("%s / %s", expr, unitCode)), nil
+	case float(src):
+		if !types.Identical(src, types.Typ[types.Float64]) {
+			expr = conversionCode("float64", expr)
+		}
+		return conversionCode(dstName, fmt.Sprintf("%s * float64(%s)", expr, unitCode)), nil
+	}
+	return fmt.Sprintf("%s * %s", conversionCode(dstName, expr), unitCode), nil
+}
+
+// isNumeric reports whether t is of a numeric type.
+func isNumeric(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsNumeric != 0
+}
+
+// isBytesOrString reports whether a and b, or the types they point to,
+// are a byte slice and a string, or a string and a byte slice.
+func isBytesOrString(a, b types.Type) bool {
+	if p, ok := a.(*types.Pointer); ok {
+		a = p.Elem()
+	}
+	if p, ok := b.(*types.Pointer); ok {
+		b = p.Elem()
+	}
+	return isBytes(a) && isString(b) || isString(a) && isBytes(b)
+}
+
+// isStringOrPtr reports whether t is string or *string.
+func isStringOrPtr(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	return types.Identical(t, types.Typ[types.String])
+}
+
+// isStructOrPtr reports whether t is a struct or a pointer to one.
+func isStructOrPtr(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	return isStruct(t)
+}
+
+// isStringer reports whether t is a named type with the String() string
+// method of fmt.Stringer.
+func isStringer(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return false
+	}
+	m, _, _ := types.LookupFieldOrMethod(named, false, named.Obj().Pkg(), "String")
+	method, ok := m.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := method.Type().(*types.Signature)
+	return sig.Params().Len() == 0 && sig.Results().Len() == 1 &&
+		types.Identical(sig.Results().At(0).Type(), types.Typ[types.String])
+}
+
+// isRawMessage reports whether t is json.RawMessage, which may be an alias.
+func isRawMessage(t types.Type) bool {
+	var obj *types.TypeName
+	switch t := t.(type) {
+	case *types.Alias:
+		obj = t.Obj()
+	case *types.Named:
+		obj = t.Obj()
+	default:
+		return false
+	}
+	return obj.Pkg() != nil && obj.Pkg().Path() == "encoding/json" && obj.Name() == "RawMessage"
+}
+
+// encodesJSON reports whether the field of the tags stores JSON in a
+// string, with the encoding option (e.g. repack:"payload,encoding=json").
+func (g *Generator) encodesJSON(dstTag, srcTag string) bool {
+	encoding, _ := g.fieldOption(dstTag, srcTag, "encoding")
+	return encoding == "json"
+}
+
+// unmarshalsJSON reports whether a src field of type src converts into a
+// dst field of type dst with json.Unmarshal: from json.RawMessage, or from
+// a string of JSON by encodesJSON, into a struct or a pointer to one.
+func unmarshalsJSON(src, dst types.Type, option bool) bool {
+	return (isRawMessage(src) || option && isString(src)) && isNestedStruct(dst)
+}
+
+// marshalsJSON reports whether a src field of type src converts into a dst
+// field of type dst with json.Marshal, as the reverse of unmarshalsJSON.
+func marshalsJSON(src, dst types.Type, option bool) bool {
+	return isNestedStruct(src) && (isRawMessage(dst) || option && isString(dst))
+}
+
+// isTextMarshaler reports whether the method set of t, a named type or a
+// pointer to it, has MarshalText() ([]byte, error) of encoding.TextMarshaler.
+func isTextMarshaler(t types.Type) bool {
+	elem := t
+	if p, ok := t.(*types.Pointer); ok {
+		elem = p.Elem()
+	}
+	if _, ok := types.Unalias(elem).(*types.Named); !ok {
+		return false
+	}
+	m, _, _ := types.LookupFieldOrMethod(t, false, nil, "MarshalText")
+	method, ok := m.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := method.Type().(*types.Signature)
+	return sig.Params().Len() == 0 && sig.Results().Len() == 2 &&
+		types.TypeString(sig.Results().At(0).Type(), nil) == "[]byte" &&
+		types.TypeString(sig.Results().At(1).Type(), nil) == "error"
+}
+
+// textUnmarshaler returns the named type t is or points to, if its pointer
+// has UnmarshalText([]byte) error of encoding.TextUnmarshaler, unless it is
+// parsable by a parser of lookupParser.
+func textUnmarshaler(t types.Type) (*types.Named, bool) {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return nil, false
+	}
+	if _, err := lookupParser(named); err == nil && isParsable(named) {
+		return nil, false
+	}
+	m, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), false, nil, "UnmarshalText")
+	method, ok := m.(*types.Func)
+	if !ok {
+		return nil, false
+	}
+	sig := method.Type().(*types.Signature)
+	return named, sig.Params().Len() == 1 && sig.Results().Len() == 1 &&
+		types.TypeString(sig.Params().At(0).Type(), nil) == "[]byte" &&
+		types.TypeString(sig.Results().At(0).Type(), nil) == "error"
+}
+
+// isSetCode returns the condition that expr of type t is set, that is not
+// the zero value. With nilOnly, only the types that may be nil are checked,
+// and the others get no condition. Types are named by qualifier.
+func isSetCode(expr string, t types.Type, nilOnly bool, qualifier types.Qualifier) string {
+	if _, ok := t.(*types.TypeParam); ok {
+		if nilOnly {
+			return ""
+		}
+		return fmt.Sprintf("!reflect.ValueOf(%s).IsZero()", expr)
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Pointer, *types.Slice, *types.Map, *types.Chan, *types.Signature, *types.Interface:
+		return expr + " != nil"
+	case *types.Basic:
+		switch info := u.Info(); {
+		case u.Kind() == types.UnsafePointer:
+			return expr + " != nil"
+		case nilOnly:
+			return ""
+		case info&types.IsString != 0:
+			return expr + ` != ""`
+		case info&types.IsBoolean != 0:
+			return expr
+		case info&types.IsNumeric != 0:
+			return expr + " != 0"
+		}
+	}
+	if nilOnly {
+		return ""
+	}
+	// Structs and arrays (e.g. time.Time) by their IsZero method, if any.
+	if m, _, _ := types.LookupFieldOrMethod(t, true, nil, "IsZero"); m != nil {
+		if sig, ok := m.Type().(*types.Signature); ok && sig.Params().Len() == 0 && sig.Results().Len() == 1 &&
+			types.Identical(sig.Results().At(0).Type(), types.Typ[types.Bool]) {
+			return fmt.Sprintf("!%s.IsZero()", expr)
+		}
+	}
+	// Arrays of comparable elements by their zero value (e.g. [4]byte{}).
+	if _, ok := t.Underlying().(*types.Array); ok && types.Comparable(t) {
+		literal := types.TypeString(t, qualifier) + "{}"
+		if !strings.HasPrefix(literal, "[") {
+			// A composite literal of a type name is parenthesized in a condition.
+			literal = "(" + literal + ")"
+		}
+		return fmt.Sprintf("%s != %s", expr, literal)
+	}
+	return fmt.Sprintf("!reflect.ValueOf(%s).IsZero()", expr)
+}
+
+// isNamedBasic reports whether t is a named type of a basic type,
+// such as an enum (e.g. type Status int).
+func isNamedBasic(t types.Type) bool {
+	_, named := types.Unalias(t).(*types.Named)
+	_, basic := t.Underlying().(*types.Basic)
+	return named && basic
+}
+
+// lookupParser returns the function that parses a string into the named
+// type t by convention, e.g. ParseStatus(string) (Status, error) for Status,
+// or else Parse or FromString of its package, e.g. uuid.Parse for uuid.UUID.
+func lookupParser(t types.Type) (*types.Func, error) {
+	named := types.Unalias(t).(*types.Named)
+	name := "Parse" + named.Obj().Name()
+	if named.Obj().Pkg() == nil {
+		return nil, fmt.Errorf("no %s(string) (%s, error)", name, named.Obj().Name())
+	}
+	for _, candidate := range []string{name, "Parse", "FromString"} {
+		parser, ok := named.Obj().Pkg().Scope().Lookup(candidate).(*types.Func)
+		if !ok {
+			continue
+		}
+		sig := parser.Type().(*types.Signature)
+		if sig.Params().Len() != 1 || !types.Identical(sig.Params().At(0).Type(), types.Typ[types.String]) ||
+			sig.Results().Len() != 2 || !identical(sig.Results().At(0).Type(), t) ||
+			types.TypeString(sig.Results().At(1).Type(), nil) != "error" {
+			if candidate == name {
+				return nil, fmt.Errorf("%s must take a string and return (%s, error)", name, named.Obj().Name())
+			}
+			continue
+		}
+		return parser, nil
+	}
+	return nil, fmt.Errorf("no %s(string) (%s, error)", name, named.Obj().Name())
+}
+
+// parsable returns the named type t is or points to, if its values are
+// basic but not strings, or arrays (e.g. uuid.UUID), which lookupParser
+// may parse from strings.
+func parsable(t types.Type) (*types.Named, bool) {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return nil, false
+	}
+	switch u := named.Underlying().(type) {
+	case *types.Basic:
+		return named, u.Info()&types.IsString == 0
+	case *types.Array:
+		return named, true
+	}
+	return nil, false
+}
+
+func isParsable(t types.Type) bool {
+	_, ok := parsable(t)
+	return ok
+}
+
+// enumConstants returns the constants of the named type t of an integer
+// or string type, in the order of declaration, skipping the aliases of the
+// values already listed. Those of other packages than the generated one,
+// qualified by qualifier, must be exported.
+func enumConstants(t types.Type, qualifier types.Qualifier) []*types.Const {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return nil
+	}
+	if basic, ok := named.Underlying().(*types.Basic); !ok || basic.Info()&(types.IsInteger|types.IsString) == 0 {
+		return nil
+	}
+	local := qualifier(named.Obj().Pkg()) == ""
+	scope := named.Obj().Pkg().Scope()
+	var consts []*types.Const
+	for _, name := range scope.Names() {
+		c, ok := scope.Lookup(name).(*types.Const)
+		if ok && (c.Exported() || local) && identical(c.Type(), t) {
+			consts = append(consts, c)
+		}
+	}
+	sort.Slice(consts, func(i, j int) bool {
+		return consts[i].Pos() < consts[j].Pos()
+	})
+	values := map[string]bool{}
+	var unique []*types.Const
+	for _, c := range consts {
+		if value := c.Val().ExactString(); !values[value] {
+			values[value] = true
+			unique = append(unique, c)
+		}
+	}
+	return unique
+}
+
+// enumName folds the name of the constant c of the named type t, so that
+// the constants of different types match by the rest of their names,
+// ignoring case and underscores (e.g. StatusActive and State_ACTIVE).
+func enumName(c *types.Const, t types.Type) string {
+	return normalizeName(strings.TrimPrefix(c.Name(), types.Unalias(t).(*types.Named).Obj().Name()))
+}
+
+// enumCases returns the pairs of the src and dst constants of the same
+// name, and the src constants without a dst constant, if both types are
+// enums (e.g. type Status int with constants).
+func enumCases(src, dst types.Type, srcQualifier, dstQualifier types.Qualifier) (cases [][2]*types.Const, unmatched []*types.Const) {
+	dstConsts := map[string]*types.Const{}
+	for _, c := range enumConstants(dst, dstQualifier) {
+		dstConsts[enumName(c, dst)] = c
+	}
+	if len(dstConsts) == 0 {
+		return nil, nil
+	}
+	for _, c := range enumConstants(src, srcQualifier) {
+		if d, ok := dstConsts[enumName(c, src)]; ok {
+			cases = append(cases, [2]*types.Const{c, d})
+		} else {
+			unmatched = append(unmatched, c)
+		}
+	}
+	return cases, unmatched
+}
+
+// lookupEnum returns the constant of the named type t of the fallback
+// option, by its name or the rest of it after the name of the type
+// (e.g. StatusUnknown or Unknown for Status).
+func lookupEnum(t types.Type, name string) (*types.Const, error) {
+	named := types.Unalias(t).(*types.Named)
+	scope := named.Obj().Pkg().Scope()
+	for _, candidate := range []string{name, named.Obj().Name() + name} {
+		if c, ok := scope.Lookup(candidate).(*types.Const); ok && identical(c.Type(), t) {
+			return c, nil
+		}
+	}
+	return nil, errors.Errorf("no constant %s of %s", name, named.Obj().Name())
+}
+
+// constCode returns the code of the constant c in the package of qualifier.
+func constCode(c *types.Const, qualifier types.Qualifier) string {
+	if q := qualifier(c.Pkg()); q != "" {
+		return q + "." + c.Name()
+	}
+	return c.Name()
+}
+
+// conversionCode returns the conversion of expr to the named type.
+func conversionCode(typeName, expr string) string {
+	if strings.HasPrefix(typeName, "*") || strings.HasPrefix(typeName, "<-") || strings.HasPrefix(typeName, "func") {
+		return fmt.Sprintf("(%s)(%s)", typeName, expr)
+	}
+	return fmt.Sprintf("%s(%s)", typeName, expr)
+}
+
+// hasFieldOption reports whether any of the options is set in the tag
+// of the dst field or the src field.
+func (g *Generator) hasFieldOption(dstTag, srcTag string, options ...string) bool {
+	for _, option := range options {
+		if _, ok := g.fieldOption(dstTag, srcTag, option); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isTime reports whether t is time.Time or *time.Time.
+func isTime(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Time"
+}
+
+func (g *Generator) generateCode(srcs []Object, dst Object) (funcName string, err error) {
+	dstInternal := dst.object.Type().Underlying().(*types.Struct)
+
+	var code bytes.Buffer
+	var body bytes.Buffer
+	var calls bytes.Buffer // the setter calls after the struct literal
+	var variables bytes.Buffer
+
+	src := srcs[0]
+	// The constructors of -byvalue take and return values, so there is
+	// nothing to check for nil.
+	byValue := dst.typ.byValue && dst.typ.populate == ""
+	dstName, dstLiteral := dst.FullName(), dst.PtrName()
+	if byValue {
+		dstName, dstLiteral = strings.TrimPrefix(dstName, "*"), strings.TrimPrefix(dstLiteral, "&")
+	}
+	funcName = fmt.Sprintf("New%sFrom", dst.funcName())
+	var srcFullNames, nilGuards []string
+	params := g.paramDecls()
+	for _, src := range srcs {
+		srcName := src.FullName()
+		if byValue {
+			srcName = strings.TrimPrefix(srcName, "*")
+		}
+		funcName += strings.Title(src.pkgName()) + src.funcName()
+		params = append(params, fmt.Sprintf("%s %s", src.param, srcName))
+		srcFullNames = append(srcFullNames, srcName)
+		nilGuards = append(nilGuards, src.param+" == nil")
+	}
+	docName := funcName
+	// The options of -options follow the other parameters.
+	optionType := g.optionType(dst)
+	if optionType != "" {
+		params = append(params, "opts ..."+optionType)
+	}
+	signature := fmt.Sprintf("%s%s (%s)", funcName, src.typeParams(), strings.Join(params, ", "))
+	if dst.typ.merge != "" {
+		docName, funcName = dst.typ.populate, dst.typ.populate
+		signature = fmt.Sprintf("%s%s(%s, d %s)", funcName, src.typeParams(), strings.Join(params, ", "), dst.FullName())
+		nilGuards = []string{"d == nil || " + strings.Join(nilGuards, " && ")}
+	} else if dst.typ.populate != "" {
+		docName = dst.typ.populate
+		funcName = fmt.Sprintf("%s.%s", dst.object.Name(), docName)
+		signature = fmt.Sprintf("(d %s) %s%s(%s)", dst.FullName(), docName, src.typeParams(), strings.Join(params, ", "))
+	} else if (g.method || src.typ.method) && src.local {
+		docName = "To" + dst.object.Name()
+		funcName = fmt.Sprintf("%s.%s", src.object.Name(), docName)
+		methodParams := g.paramDecls()
+		if optionType != "" {
+			methodParams = append(methodParams, "opts ..."+optionType)
+		}
+		signature = fmt.Sprintf("(s %s) %s(%s)", srcFullNames[0], docName, strings.Join(methodParams, ", "))
+		g.methods[funcName] = true
+	}
+	if g.funcNames[funcName] {
+		if conv := g.converters[funcName]; conv != nil && conv.dst.typ.byValue != dst.typ.byValue {
+			err := errors.Errorf("-byvalue: %s converts both a pair, by value, and a nested field, by pointer", funcName)
+			if g.nesting > 0 {
+				// The field is skipped.
+				g.logger.printf(levelWarn, "%s", err)
+			}
+			return "", err
+		}
+		return funcName, nil
+	}
+	g.nesting++
+	defer func() { g.nesting-- }()
+	if g.nesting > 1 && dst.typ.populate == "" && !g.methods[funcName] {
+		declared, err := g.handWritten(funcName, srcs, dst)
+		if err != nil {
+			g.logger.printf(levelWarn, "%s", err)
+			return "", err
+		}
+		if declared {
+			g.logger.printf(levelInfo, "call %s of the package", funcName)
+			g.funcNames[funcName] = true
+			return funcName, nil
+		}
+	}
+	if !g.methods[funcName] && dst.typ.populate == "" && g.share(funcName) {
+		return funcName, nil
+	}
+	g.funcNames[funcName] = true
+	conv := &converter{srcs: srcs, dst: dst, intact: map[string]string{}}
+	g.converters[funcName] = conv
+	if srcType := types.TypeString(src.object.Type(), packageName); len(srcs) == 1 && g.samples[srcType] == nil {
+		g.samples[srcType] = conv
+	}
+	// locals are the names of the variables of the converter, besides its
+	// srcs, its params, its dst d and its options.
+	locals := g.localNames()
+	locals.reserve("d", "opts")
+	for _, src := range srcs {
+		locals.reserve(src.param)
+	}
+	for _, p := range g.params {
+		locals.reserve(p.name)
+	}
+
+	// skipNil leaves the dst fields read through nil src pointers untouched.
+	skipNil := dst.typ.populate != "" && (g.skipNil || dst.typ.merge != "")
+	// mergeCheck returns the condition that the src value expr of type t
+	// is set, for a merge function, if any.
+	mergeCheck := func(expr string, t types.Type) string {
+		if dst.typ.merge == "" {
+			return ""
+		}
+		return isSetCode(expr, t, dst.typ.merge == "nil", dst.qualifier)
+	}
+
+	// errResult precedes the error in the early returns.
+	errResult := "nil, "
+	if byValue {
+		errResult = dstLiteral + "{}, "
+	}
+	// semanticsAt is the end of the doc comment, where the fields deep-copied
+	// and those sharing memory with the srcs are noted once known.
+	var semanticsAt int
+	deepCopied, shared := map[string]bool{}, map[string]bool{}
+	// assignFormat writes a mapped field and the src field it came from,
+	// as a struct literal entry or as an assignment onto the receiver.
+	assignFormat := "		%s:  %s, // from %s\n"
+	if dst.typ.populate != "" {
+		errResult = ""
+		assignFormat = "	d.%s = %s // from %s\n"
+		if dst.typ.merge == "zero" {
+			fmt.Fprintf(&code, "// %s sets the fields of d mapped from the non-zero fields of %s\n", docName, strings.Join(srcFullNames, " and "))
+		} else if dst.typ.merge != "" {
+			fmt.Fprintf(&code, "// %s sets the fields of d mapped from the non-nil fields of %s\n", docName, strings.Join(srcFullNames, " and "))
+		} else {
+			fmt.Fprintf(&code, "// %s sets the fields of %s mapped from %s\n", docName, dst.FullName(), strings.Join(srcFullNames, " and "))
+		}
+		code.WriteString(srcDocs(srcs))
+		semanticsAt = code.Len()
+		if g.withError {
+			fmt.Fprintf(&code, "func %s error {\n", signature)
+			fmt.Fprintf(&code, "	if %s {\n		return nil\n	}\n", strings.Join(nilGuards, " && "))
+		} else {
+			fmt.Fprintf(&code, "func %s {\n", signature)
+			fmt.Fprintf(&code, "	if %s {\n		return\n	}\n", strings.Join(nilGuards, " && "))
+		}
+	} else {
+		g.declareOptionType(&code, dst)
+		fmt.Fprintf(&code, "// %s creates %s from %s\n", docName, dstName, strings.Join(srcFullNames, " and "))
+		code.WriteString(srcDocs(srcs))
+		semanticsAt = code.Len()
+		if g.withError {
+			fmt.Fprintf(&code, "func %s (%s, error) {\n", signature, dstName)
+		} else {
+			fmt.Fprintf(&code, "func %s %s {\n", signature, dstName)
+		}
+		switch {
+		case byValue:
+		case g.withError:
+			fmt.Fprintf(&code, "	if %s {\n		return nil, nil\n	}\n", strings.Join(nilGuards, " && "))
+		default:
+			fmt.Fprintf(&code, "	if %s {\n		return nil\n	}\n", strings.Join(nilGuards, " && "))
+		}
+	}
+	if len(srcs) > 1 && !byValue {
+		// A nil src leaves its fields with the zero values.
+		for _, src := range srcs {
+			fmt.Fprintf(&code, "	if %s == nil {\n		%s = &%s{}\n	}\n", src.param, src.param, strings.TrimPrefix(src.FullName(), "*"))
+		}
+	}
+	var srcFields []Field
+	var fieldSrcs []Object // the src of each of srcFields
+	for _, src := range srcs {
+		// Fields tagged "-" are never mapped, nor those promoted from them.
+		var excluded []string
+	fields:
+		for _, f := range structFields(src.object.Type().Underlying().(*types.Struct)) {
+			for _, prefix := range excluded {
+				if strings.HasPrefix(f.path, prefix) {
+					continue fields
+				}
+			}
+			if tag, _ := g.lookupTag(f.tag); tag == "-" || g.profileIgnored(f.tag) {
+				excluded = append(excluded, f.path+".")
+				continue
+			}
+			srcFields = append(srcFields, f)
+			fieldSrcs = append(fieldSrcs, src)
+		}
+	}
+	dstNames := map[string]bool{}
+	for j := 0; j < dstInternal.NumFields(); j++ {
+		dstNames[dstInternal.Field(j).Name()] = true
+	}
+	ignored := map[string]bool{}
+	profileReasons := map[string]string{} // dst fields ignored by -profile -> why
+	notCopied := map[string]string{}      // dst fields of locks, channels and funcs -> why
+	setters := map[string]string{}        // unexported dst fields of another package -> their setters
+	for j := 0; j < dstInternal.NumFields(); j++ {
+		// Fields tagged "-" are never mapped, but may have a default.
+		if tag, _ := g.lookupTag(dstInternal.Tag(j)); tag == "-" && !g.hasFieldOption(dstInternal.Tag(j), "", "default") {
+			ignored[dstInternal.Field(j).Name()] = true
+		}
+		if g.profileIgnored(dstInternal.Tag(j)) {
+			ignored[dstInternal.Field(j).Name()] = true
+			profileReasons[dstInternal.Field(j).Name()] = fmt.Sprintf("tagged %s:\"-\"", profileTagKeys[g.profile])
+		}
+		// Unexported fields of a dst in another package are set through
+		// their setters, or else cannot be set.
+		if !dstInternal.Field(j).Exported() && !dst.local {
+			if setter, err := lookupSetter(dst.object, dstInternal.Field(j)); err == nil {
+				setters[dstInternal.Field(j).Name()] = setter
+			} else {
+				g.logger.printf(levelDebug, "%s.%s: %s", dst.object.Name(), dstInternal.Field(j).Name(), err)
+				ignored[dstInternal.Field(j).Name()] = true
+			}
+		}
+		for _, src := range srcs {
+			if g.mapping(src, dst).ignored(dstInternal.Field(j).Name()) {
+				ignored[dstInternal.Field(j).Name()] = true
+			}
+		}
+		// Locks are never copied, and channels and funcs only shared if
+		// the mapping names their src field.
+		if reason, lock := notCopyable(dstInternal.Field(j).Type(), packageName); reason != "" && !ignored[dstInternal.Field(j).Name()] {
+			explicit := false
+			for _, src := range srcs {
+				if _, ok := g.mapping(src, dst).srcField(dstInternal.Field(j).Name()); ok {
+					explicit = true
+				}
+			}
+			if lock && explicit {
+				g.logger.printf(levelWarn, "%s.%s: %s, never copied", dst.object.Name(), dstInternal.Field(j).Name(), reason)
+			}
+			if lock || !explicit {
+				ignored[dstInternal.Field(j).Name()] = true
+				notCopied[dstInternal.Field(j).Name()] = reason
+			}
+		}
+	}
+	// Index the src fields by name, by tag and by fuzzy name, in the order
+	// of the srcs, to match each dst field in one lookup.
+	byName := map[string][]int{}
+	byTag := map[string][]int{}
+	byFuzzy := map[string][]int{}
+	byMatchTag := map[string]map[string][]int{} // by key of -matchtags, then by tag
+	byGetter := map[string][]int{}              // unexported fields of other packages by their getters without Get, for -getters
+	byColumn := map[string][]int{}              // fields other than associations by column, for -profile
+	for i, f := range srcFields {
+		if g.getters && !f.Exported() && !fieldSrcs[i].local {
+			if getter, err := lookupGetter(fieldSrcs[i].object, f.Var, true); err == nil {
+				for _, name := range getterNames(f.Name()) {
+					if getter == name || getter == "Get"+name {
+						byGetter[name] = append(byGetter[name], i)
+						break
+					}
+				}
+			}
+		}
+		byName[f.Name()] = append(byName[f.Name()], i)
+		if tag, ok := g.lookupTag(f.tag); ok {
+			byTag[tag] = append(byTag[tag], i)
+		}
+		for _, key := range g.matchTags {
+			if tag, ok := lookupTagKey(f.tag, key); ok && tag != "-" {
+				if byMatchTag[key] == nil {
+					byMatchTag[key] = map[string][]int{}
+				}
+				byMatchTag[key][tag] = append(byMatchTag[key][tag], i)
+			}
+		}
+		if g.profile != "" && !g.isAssociation(f.Var, f.tag) {
+			byColumn[g.column(f.Name(), f.tag)] = append(byColumn[g.column(f.Name(), f.tag)], i)
+		}
+		if !dstNames[f.Name()] {
+			byFuzzy[g.matchName(f.Name())] = append(byFuzzy[g.matchName(f.Name())], i)
+		}
+	}
+	provenanceOf := func(i int) string {
+		if len(srcs) > 1 {
+			return fieldSrcs[i].param + "." + srcFields[i].path
+		}
+		return srcFields[i].path
+	}
+	mapped := map[string]string{}     // dst field name -> src field path
+	rules := map[string]string{}      // dst field name -> rule that mapped it, for the report
+	strategies := map[string]string{} // dst field name -> how its value is converted, for the report
+	skipped := map[string]string{}    // dst field name -> why it was skipped
+	var embeddedFuncs []string        // converters of the embedded dst structs from the srcs, for the report
+	// The entries of the mapped dst fields, written in the order of the
+	// dst struct whatever the rule that mapped them.
+	entries := make([]bytes.Buffer, dstInternal.NumFields())
+	setterCalls := make([]bytes.Buffer, dstInternal.NumFields())
+	// assign writes the dst field j mapped from code by the rule, as an
+	// entry or, for an unexported field of another package, as a call of
+	// its setter.
+	assign := func(j int, code, provenance, rule string) {
+		name := dstInternal.Field(j).Name()
+		if g.rules && rule != "default" {
+			provenance += " by " + rule
+		}
+		switch setter := setters[name]; {
+		case setter == "":
+			fmt.Fprintf(&entries[j], assignFormat, name, code, provenance)
+		case dst.typ.populate != "":
+			fmt.Fprintf(&entries[j], "	d.%s(%s) // from %s\n", setter, code, provenance)
+		default:
+			// Called on the constructed dst, after the struct literal.
+			fmt.Fprintf(&setterCalls[j], "	d.%s(%s) // from %s\n", setter, code, provenance)
+		}
+	}
+	skip := func(dstName, format string, args ...interface{}) {
+		skipped[dstName] = strings.TrimSpace(fmt.Sprintf(format, args...))
+	}
+	// Dst fields named with a prefix of the mapping (e.g. BillingCity) are
+	// mapped from the src path (e.g. Billing.City) if it holds a field of
+	// the same type, unless they have a tag or a src field of their name.
+	prefixed := map[int]string{}
+	prefixSrcs := map[int]Object{}
+	for j := 0; j < dstInternal.NumFields(); j++ {
+		dstField := dstInternal.Field(j)
+		if _, ok := g.lookupTag(dstInternal.Tag(j)); ok || len(byName[dstField.Name()]) > 0 {
+			continue
+		}
+		for _, src := range srcs {
+			path, ok := g.mapping(src, dst).prefixPath(dstField.Name())
+			if !ok {
+				continue
+			}
+			if _, ok := g.mapping(src, dst).srcField(dstField.Name()); ok {
+				break
+			}
+			_, _, typ, err := pathCode(src, path)
+			if err != nil {
+				continue
+			}
+			if types.TypeString(typ, packagePath) != types.TypeString(dstField.Type(), packagePath) {
+				skip(dstField.Name(), "skip field (%s): %s is %s, not %s", dstField.Name(), path,
+					types.TypeString(typ, packageName), types.TypeString(dstField.Type(), packageName))
+				break
+			}
+			prefixed[j], prefixSrcs[j] = path, src
+			break
+		}
+	}
+	// pathOf returns the src field the dst field is mapped from by the
+	// mapping, or else by its tag, with the srcs that may hold it.
+	pathOf := func(j int) (path string, pathSrcs []Object, explicit bool) {
+		for _, src := range srcs {
+			if name, ok := g.mapping(src, dst).srcField(dstInternal.Field(j).Name()); ok {
+				return name, []Object{src}, true
+			}
+		}
+		if path, ok := prefixed[j]; ok {
+			return path, []Object{prefixSrcs[j]}, true
+		}
+		path, _ = g.lookupTag(dstInternal.Tag(j))
+		return path, srcs, false
+	}
+	// The dst fields of the oneofs of the mappings, and the plain dst
+	// fields of the cases of the src ones, are mapped below.
+	dstIndex := map[string]int{}
+	for j := 0; j < dstInternal.NumFields(); j++ {
+		dstIndex[dstInternal.Field(j).Name()] = j
+	}
+	srcOneof := func(src Object, name string) (int, bool) {
+		for i, f := range srcFields {
+			if fieldSrcs[i].param == src.param && f.path == name && isOneof(f.Type()) {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	oneofDsts := map[string]bool{}
+	for _, src := range srcs {
+		for field, cases := range g.oneofs(src, dst) {
+			if j, ok := dstIndex[field]; ok && isOneof(dstInternal.Field(j).Type()) {
+				oneofDsts[field] = true
+			} else if _, ok := srcOneof(src, field); ok {
+				for _, name := range cases {
+					oneofDsts[name] = true
+				}
+			}
+		}
+	}
+	for j := 0; j < dstInternal.NumFields(); j++ {
+		dstField := dstInternal.Field(j)
+		dstTag, dstTagFound := g.lookupTag(dstInternal.Tag(j))
+		// The variables of the field, moved under the check of a merge.
+		start := variables.Len()
+		if path, _, _ := pathOf(j); strings.ContainsAny(path, ".+") || dstTag == "-" {
+			// Mapped from the dotted path, the concatenation or the default below.
+			continue
+		}
+		if ignored[dstField.Name()] || oneofDsts[dstField.Name()] {
+			continue
+		}
+
+		// The mapping file takes precedence over names, names over tags,
+		// tags over those of -matchtags, these over the columns of
+		// -profile, those over getters and setters, and those over fuzzy
+		// names.
+		var candidates []int
+		var tagged []int
+		for _, key := range g.matchTags {
+			if tag, ok := lookupTagKey(dstInternal.Tag(j), key); ok && tag != "-" && len(byMatchTag[key][tag]) > 0 {
+				tagged = byMatchTag[key][tag]
+				break
+			}
+		}
+		var rule string
+		explicit := false
+		for _, src := range srcs {
+			name, ok := g.mapping(src, dst).srcField(dstField.Name())
+			if !ok {
+				continue
+			}
+			explicit = true
+			for _, i := range byName[name] {
+				if fieldSrcs[i].param == src.param {
+					candidates = append(candidates, i)
+				}
+			}
+		}
+		// qualified are the src fields each rule matches, in the order of
+		// precedence: the first rule wins, and the src fields only the
+		// others match make the field ambiguous all the same.
+		var qualified []ruleMatch
+		qualify := func(rule string, candidates []int) {
+			if len(candidates) > 0 {
+				qualified = append(qualified, ruleMatch{rule: rule, candidates: candidates})
+			}
+		}
+		qualify("name", byName[dstField.Name()])
+		if dstTagFound {
+			qualify("tag", byTag[dstTag])
+		}
+		qualify("tag", tagged)
+		if g.profile != "" && !g.isAssociation(dstField, dstInternal.Tag(j)) {
+			qualify("column", byColumn[g.column(dstField.Name(), dstInternal.Tag(j))])
+		}
+		qualify("getter", byGetter[dstField.Name()])
+		if setters[dstField.Name()] != "" {
+			qualify("setter", byName[strings.TrimPrefix(setters[dstField.Name()], "Set")])
+		}
+		if g.match != "" {
+			qualify(g.match, g.rankMatches(byFuzzy[g.matchName(dstField.Name())], srcFields, fieldSrcs, dstField.Name()))
+		}
+		var others []int // the src fields of the rules that lost
+		switch {
+		case explicit:
+			rule = "mapping"
+			if len(candidates) == 0 {
+				skip(dstField.Name(), "skip field (%s): no src field in the mapping", dstField.Name())
+			}
+		case len(qualified) > 0:
+			candidates, rule = qualified[0].candidates, qualified[0].rule
+			seen := map[int]bool{}
+			for _, i := range candidates {
+				seen[i] = true
+			}
+			for _, q := range qualified[1:] {
+				for _, i := range q.candidates {
+					if !seen[i] {
+						seen[i] = true
+						others = append(others, i)
+					}
+				}
+			}
+		}
+		if !explicit {
+			candidates = g.contributors(candidates, fieldSrcs, dst, dstField.Name())
+			others = g.contributors(others, fieldSrcs, dst, dstField.Name())
+		}
+		if len(candidates) > 0 {
+			var names []string
+			for _, i := range candidates {
+				names = append(names, provenanceOf(i))
+			}
+			g.logger.printf(levelDebug, "match %s.%s by %s: %s", dst.object.Name(), dstField.Name(), rule, strings.Join(names, ", "))
+		} else {
+			g.logger.printf(levelDebug, "match %s.%s: no src field by mapping, name or tag", dst.object.Name(), dstField.Name())
+		}
+		if len(candidates) == 0 && dstField.Anonymous() && !explicit && isStructOrPtr(dstField.Type()) && dst.typ.merge != "" {
+			skip(dstField.Name(), "skip embedded field (%s): cannot merge its promoted fields", dstField.Name())
+			continue
+		}
+		if len(candidates) == 0 && dstField.Anonymous() && !explicit && isStructOrPtr(dstField.Type()) {
+			// An embedded struct without a src field is converted from the
+			// srcs, so that its promoted fields are mapped too.
+			embeddedType, err := g.parseType(dstField.Type(), dst.pkg)
+			if err != nil {
+				return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+			}
+			var args, names []string
+			var embeddedFuncName string
+			for _, src := range srcs {
+				if byValue {
+					// The nested constructors take pointers.
+					args = append(args, "&"+src.param)
+				} else {
+					args = append(args, src.param)
+				}
+				names = append(names, src.object.Name())
+			}
+			if len(srcs) > 1 {
+				var srcTypes []Type
+				for _, src := range srcs {
+					srcTypes = append(srcTypes, src.typ)
+				}
+				embeddedFuncName, err = g.generateMerged(srcTypes, embeddedType)
+			} else {
+				embeddedFuncName, err = g.generate(src.typ, embeddedType)
+			}
+			if err != nil || embeddedFuncName == "" {
+				skip(dstField.Name(), "skip embedded field (%s): cannot convert %s", dstField.Name(), strings.Join(names, " and "))
+				continue
+			}
+			srcFieldCode := fmt.Sprintf("%s(%s)", embeddedFuncName, g.paramArgs(args...))
+			if g.methods[embeddedFuncName] {
+				srcFieldCode = g.callCode(embeddedFuncName, src.param, true)
+			}
+			if g.withError {
+				converted := locals.temp(toLowerFirstChar(dstField.Name()))
+				fmt.Fprintf(&variables, "	%s, err := %s\n", converted, srcFieldCode)
+				fmt.Fprint(&variables, errorCheck(errResult, dstField.Name()))
+				srcFieldCode = converted
+			}
+			if !embeddedType.isPointer {
+				// The srcs are not nil here, so neither is the converted struct.
+				srcFieldCode = "*" + srcFieldCode
+			}
+			provenance := strings.Join(names, "+")
+			assign(j, srcFieldCode, provenance, "embedded")
+			mapped[dstField.Name()] = provenance
+			embeddedFuncs = append(embeddedFuncs, embeddedFuncName)
+			rules[dstField.Name()] = "embedded"
+			strategies[dstField.Name()] = "nested constructor"
+			if nested := g.converters[embeddedFuncName]; nested != nil && len(nested.srcs) == len(srcs) {
+				// The test populates the src fields the embedded struct is
+				// converted from too.
+				conv.adopt(nested)
+				if len(nested.checked) > 0 {
+					conv.checked = append(conv.checked, dstField.Name())
+				}
+			}
+			continue
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		if rivals := append(candidates[1:len(candidates):len(candidates)], others...); len(rivals) > 0 {
+			g.logger.printf(levelWarn, "ambiguous field (%s): both %s and %s match; use %s",
+				dstField.Name(), provenanceOf(candidates[0]), provenanceOf(rivals[0]), provenanceOf(candidates[0]))
+		}
+		f := srcFields[candidates[0]]
+		src := fieldSrcs[candidates[0]]
+		m := g.mapping(src, dst)
+		srcField := f.Var
+		provenance := provenanceOf(candidates[0])
+		if reason, lock := notCopyable(srcField.Type(), packageName); lock {
+			// Converting the lock would copy it all the same.
+			skip(dstField.Name(), "skip field (%s): %s", srcField.Name(), reason)
+			continue
+		}
+
+		srcAccess := fmt.Sprintf("%s.%s", src.param, f.path)
+		if !srcField.Exported() && !src.local {
+			getter, err := lookupGetter(src.object, srcField, g.getters)
+			if err != nil {
+				skip(dstField.Name(), "skip field (%s): %s", srcField.Name(), err)
+				continue
+			}
+			srcAccess = fmt.Sprintf("%s.%s()", src.param, getter)
+		}
+		srcFieldCode := srcAccess
+		// The temps of the field are named after the src field, once.
+		var tmp string
+		temp := func() string {
+			if tmp == "" {
+				tmp = locals.temp(toLowerFirstChar(srcField.Name()))
+			}
+			return tmp
+		}
+		conversion := &fieldConversion{
+			src: src, dst: dst, srcField: srcField, dstField: dstField,
+			srcTag: f.tag, dstTag: dstInternal.Tag(j), access: srcAccess, code: srcAccess,
+			variables: &variables, errResult: errResult, locals: locals, temp: temp, skipNil: skipNil,
+		}
+		convert, hasConvert := g.fieldOption(dstInternal.Tag(j), f.tag, "convert")
+		option := "convert"
+		if using, ok := g.fieldOption(dstInternal.Tag(j), f.tag, "using"); ok && !hasConvert {
+			convert, hasConvert, option = using, true, "using"
+		}
+		transform, hasTransform := g.fieldOption(dstInternal.Tag(j), f.tag, "transform")
+		if hasTransform {
+			option = "transform"
+		}
+		if g.reverse && (hasConvert || hasTransform) {
+			// The functions of the tags convert the other way; the reverse
+			// option names those of the reverse conversion, as a transform.
+			convert, hasConvert = "", false
+			transform, hasTransform = g.fieldOption(dstInternal.Tag(j), f.tag, "reverse")
+			if !hasTransform && (m == nil || m.Convert[dstField.Name()] == "") {
+				skip(dstField.Name(), "skip field (%s): the %s option is one-way; name the reverse funcs with reverse", srcField.Name(), option)
+				continue
+			}
+		}
+		if m != nil && m.Convert[dstField.Name()] != "" {
+			convert, hasConvert = m.Convert[dstField.Name()], true
+		}
+		// converted is the src field converted by the function of the
+		// field, or else of its types, if any.
+		var converted string
+		var sig *types.Signature
+		if hasTransform && hasConvert {
+			return "", errors.Errorf("%s.%s: transform option with a convert function; name one of them", dst.object.Name(), dstField.Name())
+		}
+		if hasTransform {
+			if converted, sig, err = g.transformCode(transform, srcAccess, dstField.Type(), dst.qualifier); err != nil {
+				return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+			}
+		} else if hasConvert {
+			name, fnSig, err := g.funcCode(convert)
+			if err != nil {
+				return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+			}
+			passed, err := g.passedParams(name, fnSig)
+			if err != nil {
+				return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+			}
+			converted, sig = fmt.Sprintf("%s(%s)", name, strings.Join(append(passed, srcAccess), ", ")), fnSig
+		} else if c := g.typeConverter(srcField.Type(), dstField.Type()); c != nil {
+			if converted, sig, err = g.typeConverterCode(c, srcAccess, srcField.Type()); err != nil {
+				return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+			}
+		} else if code, ok := g.wellKnownCode(srcField.Type(), dstField.Type(), srcAccess,
+			temp(), &variables); ok {
+			converted = code
+		} else if code, ok := g.sqlNullCode(srcField.Type(), dstField.Type(), srcAccess,
+			temp(), &variables, dst.qualifier); ok {
+			converted = code
+		} else if code, parsed, skipped, err := g.bigNumberCode(srcField.Type(), dstField.Type(), dstInternal.Tag(j), f.tag, srcAccess,
+			temp(), errResult, srcField.Name(), &variables, conversion.nilSafe, dst.qualifier); err != nil {
+			return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+		} else if skipped != "" {
+			skip(dstField.Name(), "skip field (%s) %s", srcField.Name(), skipped)
+			continue
+		} else if code != "" {
+			converted, conversion.parses = code, parsed
+		} else if code, skipped, err := g.timeCode(srcField.Type(), dstField.Type(), dstInternal.Tag(j), f.tag, srcAccess,
+			temp(), errResult, srcField.Name(), &variables, conversion.nilSafe, dst.qualifier); err != nil {
+			return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+		} else if skipped != "" {
+			skip(dstField.Name(), "skip field (%s) %s", srcField.Name(), skipped)
+			continue
+		} else if code != "" {
+			converted = code
+		} else if impls, ok := g.impls(src, dst, dstInternal.Tag(j), f.tag, dstField.Name(), srcField.Name()); !ok {
+		} else if code, skipped, err := g.implCode(srcField.Type(), dstField.Type(), impls, srcAccess,
+			temp(), errResult, srcField.Name(), src, dst, &variables); err != nil {
+			return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+		} else if skipped != "" {
+			skip(dstField.Name(), "skip field (%s) %s", srcField.Name(), skipped)
+			continue
+		} else if code != "" {
+			converted = code
+		}
+		if fallible(sig) {
+			if !g.withError {
+				skip(dstField.Name(), "skip field (%s) due to fallible conversion; use -witherror", srcField.Name())
+				continue
+			}
+			tmpSrcField := temp()
+			fmt.Fprintf(&variables, "	%s, err := %s\n", tmpSrcField, converted)
+			fmt.Fprint(&variables, errorCheck(errResult, srcField.Name()))
+			converted = tmpSrcField
+			conversion.parses = true
+		}
+		verb, hasVerb := g.fieldOption(dstInternal.Tag(j), f.tag, "fmt")
+		if hasVerb && verb == "" {
+			g.logger.printf(levelWarn, "empty fmt option of field (%s); use %%v", dstField.Name())
+		}
+		// strategy is how the value is converted, for the report: by the
+		// function of the field or of its types, by the code of repacker,
+		// or by the converter of the nested structs.
+		strategy := "direct"
+		if converted != "" {
+			srcFieldCode = converted
+			strategy = "conversion"
+			if sig != nil {
+				strategy = "custom func"
+			}
+		} else if verb != "" && isStringOrPtr(dstField.Type()) {
+			strategy = "conversion"
+			_, srcIsPointer := srcField.Type().(*types.Pointer)
+			_, dstIsPointer := dstField.Type().(*types.Pointer)
+			formatted := fmt.Sprintf("fmt.Sprintf(%s, %s)", strconv.Quote(verb), srcFieldCode)
+			tmpSrcField := temp()
+			switch {
+			case srcIsPointer && dstIsPointer:
+				fmt.Fprint(&variables, nilCheckedCode(tmpSrcField, "string", srcFieldCode,
+					fmt.Sprintf("fmt.Sprintf(%s, *%s)", strconv.Quote(verb), srcFieldCode)))
+				srcFieldCode = tmpSrcField
+			case srcIsPointer:
+				// A nil src pointer leaves the empty string.
+				fmt.Fprintf(&variables, "	var %s string\n", tmpSrcField)
+				fmt.Fprintf(&variables, "	if %s != nil {\n		%s = fmt.Sprintf(%s, *%s)\n	}\n",
+					srcFieldCode, tmpSrcField, strconv.Quote(verb), srcFieldCode)
+				srcFieldCode = tmpSrcField
+			case dstIsPointer:
+				fmt.Fprintf(&variables, "	%s := %s\n", tmpSrcField, formatted)
+				srcFieldCode = "&" + tmpSrcField
+			default:
+				srcFieldCode = formatted
+			}
+		} else if !assignable(srcField.Type(), dstField.Type()) {
+			if err := g.convert(conversion); err != nil {
+				return "", err
+			}
+			if conversion.skipped != "" {
+				skip(dstField.Name(), "%s", conversion.skipped)
+				continue
+			}
+			srcFieldCode = conversion.code
+			strategy = "conversion"
+			if conversion.nested {
+				strategy = "nested constructor"
+			}
+		}
+		// The references of the fields copied as is are shared, unless
+		// deep-copied.
+		copied := srcFieldCode == srcAccess
+		if copied && g.sharesMemory(srcField.Type()) {
+			deep, err := g.deepCopies(dstInternal.Tag(j), f.tag)
+			if err != nil {
+				return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+			}
+			if deep {
+				srcFieldCode = g.deepCopyCode(srcFieldCode, srcField.Type())
+			}
+			deepCopied[dstField.Name()] = srcFieldCode != srcAccess
+			shared[dstField.Name()] = srcFieldCode == srcAccess
+		}
+		if g.optional {
+			srcFieldCode = optionalCode(&variables, start, srcAccess, srcField.Type(), dstField.Type(), srcFieldCode, dst.qualifier)
+		}
+		check := mergeCheck(srcAccess, srcField.Type())
+		if check != "" {
+			// The src field is converted only when it is set.
+			fmt.Fprintf(&entries[j], "	if %s {\n", check)
+			entries[j].Write(variables.Bytes()[start:])
+			variables.Truncate(start)
+		}
+		if conversion.guard != "" && check != conversion.guard+" != nil" {
+			fmt.Fprintf(&entries[j], "	if %s != nil {\n", conversion.guard)
+			assign(j, srcFieldCode, provenance, rule)
+			fmt.Fprintf(&entries[j], "	}\n")
+		} else {
+			assign(j, srcFieldCode, provenance, rule)
+		}
+		if check != "" {
+			fmt.Fprintf(&entries[j], "	}\n")
+		}
+		mapped[dstField.Name()] = provenance
+		rules[dstField.Name()] = rule
+		strategies[dstField.Name()] = strategy
+		if conversion.parses {
+			conv.fuzzed = append(conv.fuzzed, fuzzField{src: src.param, path: f.path, typ: srcField.Type()})
+		}
+		if sample := g.testSample(conv, src, m, f, dstField, dstInternal.Tag(j)); sample != "" {
+			conv.setup = append(conv.setup, fmt.Sprintf("%s.%s = %s", src.param, f.path, sample))
+			conv.checked = append(conv.checked, dstField.Name())
+			if copied && types.Identical(srcField.Type(), dstField.Type()) && setters[dstField.Name()] == "" {
+				conv.intact[dstField.Name()] = src.param + "." + f.path
+			}
+		}
+	}
+	for j := 0; j < dstInternal.NumFields(); j++ {
+		dstField := dstInternal.Field(j)
+		path, pathSrcs, explicit := pathOf(j)
+		if !strings.Contains(path, ".") || strings.Contains(path, "+") || ignored[dstField.Name()] {
+			continue
+		}
+		rule := "path"
+		if explicit {
+			rule = "mapping"
+		}
+		selector, nilChecks, typ, provenance, err := srcsPathCode(pathSrcs, path)
+		if err != nil {
+			return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+		}
+		if types.TypeString(typ, packagePath) != types.TypeString(dstField.Type(), packagePath) {
+			return "", errors.Errorf("%s.%s: %s is %s, not %s", dst.object.Name(), dstField.Name(), path,
+				types.TypeString(typ, packageName), types.TypeString(dstField.Type(), packageName))
+		}
+		srcFieldCode := selector
+		if g.sharesMemory(typ) {
+			deep, err := g.deepCopies(dstInternal.Tag(j), "")
+			if err != nil {
+				return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+			}
+			if deep {
+				srcFieldCode = g.deepCopyCode(selector, typ)
+			}
+			deepCopied[dstField.Name()] = srcFieldCode != selector
+			shared[dstField.Name()] = srcFieldCode == selector
+		}
+		if dst.typ.merge != "" {
+			// Set only when the path is not nil, and its value is set.
+			if check := mergeCheck(selector, typ); check != "" {
+				nilChecks = append(nilChecks, check)
+			}
+			if len(nilChecks) > 0 {
+				fmt.Fprintf(&entries[j], "	if %s {\n", strings.Join(nilChecks, " && "))
+				assign(j, srcFieldCode, provenance, rule)
+				fmt.Fprintf(&entries[j], "	}\n")
+			} else {
+				assign(j, srcFieldCode, provenance, rule)
+			}
+			mapped[dstField.Name()] = provenance
+			rules[dstField.Name()] = rule
+			strategies[dstField.Name()] = "direct"
+			continue
+		}
+		if len(nilChecks) > 0 {
+			variable := locals.temp(toLowerFirstChar(dstField.Name()))
+			fmt.Fprintf(&variables, "	var %s %s\n", variable, types.TypeString(dstField.Type(), dst.qualifier))
+			fmt.Fprintf(&variables, "	if %s {\n		%s = %s\n	}\n", strings.Join(nilChecks, " && "), variable, srcFieldCode)
+			srcFieldCode = variable
+		}
+		assign(j, srcFieldCode, provenance, rule)
+		mapped[dstField.Name()] = provenance
+		rules[dstField.Name()] = rule
+		strategies[dstField.Name()] = "direct"
+	}
+	for j := 0; j < dstInternal.NumFields(); j++ {
+		dstField := dstInternal.Field(j)
+		paths, pathSrcs, explicit := pathOf(j)
+		if !strings.Contains(paths, "+") || ignored[dstField.Name()] {
+			continue
+		}
+		rule := "concat"
+		if explicit {
+			rule = "mapping"
+		}
+		if !isString(dstField.Type()) {
+			return "", errors.Errorf("%s.%s: cannot concatenate %s into %s", dst.object.Name(), dstField.Name(),
+				paths, types.TypeString(dstField.Type(), packageName))
+		}
+		sep := " "
+		if v, ok := g.fieldOption(dstInternal.Tag(j), "", "sep"); ok {
+			sep = v
+		}
+		var parts, provenances []string
+		// checks are those of the parts that are set, for a merge.
+		var checks []string
+		unchecked := false
+		for i, path := range strings.Split(paths, "+") {
+			selector, nilChecks, typ, provenance, err := srcsPathCode(pathSrcs, path)
+			if err != nil {
+				return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+			}
+			if !isString(typ) {
+				return "", errors.Errorf("%s.%s: %s is %s, not string", dst.object.Name(), dstField.Name(),
+					path, types.TypeString(typ, packageName))
+			}
+			if !types.Identical(typ, types.Typ[types.String]) {
+				selector = conversionCode("string", selector)
+			}
+			if len(nilChecks) > 0 {
+				part := locals.temp(fmt.Sprintf("%s%d", toLowerFirstChar(dstField.Name()), i))
+				fmt.Fprintf(&variables, "	var %s string\n", part)
+				fmt.Fprintf(&variables, "	if %s {\n		%s = %s\n	}\n", strings.Join(nilChecks, " && "), part, selector)
+				selector = part
+			}
+			parts = append(parts, selector)
+			provenances = append(provenances, provenance)
+			switch {
+			case dst.typ.merge == "zero":
+				checks = append(checks, selector+` != ""`)
+			case len(nilChecks) > 0:
+				checks = append(checks, "("+strings.Join(nilChecks, " && ")+")")
+			default:
+				unchecked = true
+			}
+		}
+		srcFieldCode := strings.Join(parts, " + "+strconv.Quote(sep)+" + ")
+		if !types.Identical(dstField.Type(), types.Typ[types.String]) {
+			srcFieldCode = conversionCode(types.TypeString(dstField.Type(), dst.qualifier), srcFieldCode)
+		}
+		if dst.typ.merge != "" && !unchecked {
+			// Set when any of the parts is.
+			fmt.Fprintf(&entries[j], "	if %s {\n", strings.Join(checks, " || "))
+			assign(j, srcFieldCode, strings.Join(provenances, "+"), rule)
+			fmt.Fprintf(&entries[j], "	}\n")
+		} else {
+			assign(j, srcFieldCode, strings.Join(provenances, "+"), rule)
+		}
+		mapped[dstField.Name()] = strings.Join(provenances, "+")
+		rules[dstField.Name()] = rule
+		strategies[dstField.Name()] = "conversion"
+	}
+	for _, src := range srcs {
+		oneofs := g.oneofs(src, dst)
+		for _, field := range sortedOneofs(oneofs) {
+			cases := oneofs[field]
+			if j, ok := dstIndex[field]; ok && isOneof(dstInternal.Field(j).Type()) && !ignored[field] {
+				// The dst oneof is set to the case of the first set src field.
+				wrappers := oneofWrappers(dstInternal.Field(j).Type())
+				var switchCode bytes.Buffer
+				var names []string
+				for _, caseName := range sortedKeys(cases) {
+					srcName := cases[caseName]
+					wrapper, ok := wrappers[caseName]
+					candidates := byName[srcName]
+					if !ok || len(candidates) == 0 {
+						g.logger.printf(levelWarn, "%s.%s: no case %s of oneof %s or src field %s", dst.object.Name(), field, caseName, field, srcName)
+						continue
+					}
+					i := candidates[0]
+					expr := fieldSrcs[i].param + "." + srcFields[i].path
+					caseField := wrapper.Underlying().(*types.Struct).Field(0)
+					check, ok := oneofCheck(expr, srcFields[i].Type())
+					pre, code, converts := g.oneofCode(expr, locals.temp(toLowerFirstChar(caseName)), srcFields[i].Type(), caseField.Type(), fieldSrcs[i], dst, errResult, srcName)
+					if !ok || !converts {
+						g.logger.printf(levelWarn, "%s.%s: skip case %s of oneof %s: cannot convert %s", dst.object.Name(), field, caseName, field, srcName)
+						continue
+					}
+					fmt.Fprintf(&switchCode, "	case %s:\n%s", check, pre)
+					fmt.Fprintf(&switchCode, "		d.%s = &%s{%s: %s} // from %s\n", field, types.TypeString(wrapper, dst.qualifier), caseName, code, srcName)
+					names = append(names, srcName)
+				}
+				if len(names) == 0 {
+					skip(field, "skip field (%s): no case of the oneof converts", field)
+					continue
+				}
+				target := &setterCalls[j]
+				if dst.typ.populate != "" {
+					target = &entries[j]
+				}
+				fmt.Fprintf(target, "	switch {\n%s	}\n", switchCode.String())
+				mapped[field] = strings.Join(names, "|")
+				rules[field] = "oneof"
+				strategies[field] = "conversion"
+				continue
+			}
+			i, ok := srcOneof(src, field)
+			if !ok {
+				continue
+			}
+			// The plain dst fields are set from the case the src oneof holds.
+			wrappers := oneofWrappers(srcFields[i].Type())
+			var switchCode bytes.Buffer
+			var assigned []int
+			for _, caseName := range sortedKeys(cases) {
+				dstName := cases[caseName]
+				j, ok := dstIndex[dstName]
+				if !ok || ignored[dstName] {
+					continue
+				}
+				dstField := dstInternal.Field(j)
+				wrapper, ok := wrappers[caseName]
+				if !ok {
+					skip(dstName, "skip field (%s): no case %s of oneof %s", dstName, caseName, field)
+					continue
+				}
+				caseField := wrapper.Underlying().(*types.Struct).Field(0)
+				variable := locals.temp(toLowerFirstChar(dstName))
+				pre, code, ok := g.oneofCode("v."+caseName, variable+"Value", caseField.Type(), dstField.Type(), src, dst, errResult, dstName)
+				if !ok {
+					skip(dstName, "skip field (%s): type mismatch %s vs %s", field+"."+caseName,
+						types.TypeString(caseField.Type(), packageName), types.TypeString(dstField.Type(), packageName))
+					continue
+				}
+				if dst.typ.merge != "" {
+					// A merge leaves the fields of the other cases untouched.
+					fmt.Fprintf(&switchCode, "	case *%s:\n%s		d.%s = %s // from %s\n", types.TypeString(wrapper, dst.qualifier), pre, dstName, code, provenanceOf(i)+"."+caseName)
+				} else {
+					fmt.Fprintf(&variables, "	var %s %s\n", variable, types.TypeString(dstField.Type(), dst.qualifier))
+					fmt.Fprintf(&switchCode, "	case *%s:\n%s		%s = %s\n", types.TypeString(wrapper, dst.qualifier), pre, variable, code)
+					assign(j, variable, provenanceOf(i)+"."+caseName, "oneof")
+				}
+				mapped[dstName] = provenanceOf(i) + "." + caseName
+				rules[dstName] = "oneof"
+				strategies[dstName] = "conversion"
+				assigned = append(assigned, j)
+			}
+			switch {
+			case len(assigned) > 0 && dst.typ.merge != "":
+				fmt.Fprintf(&entries[assigned[0]], "	switch v := %s.%s.(type) {\n%s	}\n", src.param, srcFields[i].path, switchCode.String())
+			case len(assigned) > 0:
+				fmt.Fprintf(&variables, "	switch v := %s.%s.(type) {\n%s	}\n", src.param, srcFields[i].path, switchCode.String())
+			}
+		}
+	}
+	for j := 0; j < dstInternal.NumFields(); j++ {
+		dstField := dstInternal.Field(j)
+		if _, ok := mapped[dstField.Name()]; ok || ignored[dstField.Name()] {
+			continue
+		}
+		// Associations and bookkeeping fields are left to the ORM.
+		if reason := g.profileReason(dst, dstField, dstInternal.Tag(j)); reason != "" && skipped[dstField.Name()] == "" &&
+			!g.hasFieldOption(dstInternal.Tag(j), "", "default") {
+			ignored[dstField.Name()] = true
+			profileReasons[dstField.Name()] = reason
+			continue
+		}
+		if value, ok := g.fieldOption(dstInternal.Tag(j), "", "default"); ok && dst.typ.merge != "" {
+			// A merge leaves the fields without a src field untouched.
+			continue
+		} else if ok {
+			literal, err := defaultCode(dstField.Type(), value, dst.qualifier)
+			if err != nil {
+				return "", errors.Wrapf(err, "%s.%s", dst.object.Name(), dstField.Name())
+			}
+			assign(j, literal, "default", "default")
+			rules[dstField.Name()] = "default"
+			strategies[dstField.Name()] = "default"
+			continue
+		}
+		unmapped := fmt.Sprintf("%s.%s (%s)", dst.object.Name(), dstField.Name(), types.TypeString(dstField.Type(), packageName))
+		if reason, ok := skipped[dstField.Name()]; ok {
+			unmapped += ": " + reason
+		}
+		g.unmapped = append(g.unmapped, unmapped)
+	}
+	for j := 0; j < dstInternal.NumFields(); j++ {
+		// The diff cannot read the fields set through setters.
+		if rule := rules[dstInternal.Field(j).Name()]; rule != "" && rule != "default" && setters[dstInternal.Field(j).Name()] == "" &&
+			!(rule == "oneof" && isOneof(dstInternal.Field(j).Type())) {
+			conv.mapped = append(conv.mapped, dstInternal.Field(j).Name())
+		}
+	}
+	report := ConverterReport{Func: funcName, Dst: types.TypeString(dst.object.Type(), nil)}
+	// todos are the comments of the skipped fields, so that reviewers see
+	// the gaps in the generated code.
+	var todos bytes.Buffer
+	for _, src := range srcs {
+		report.Srcs = append(report.Srcs, types.TypeString(src.object.Type(), nil))
+	}
+	for j := 0; j < dstInternal.NumFields(); j++ {
+		dstField := dstInternal.Field(j)
+		name := dstField.Name()
+		field := FieldReport{Dst: name, Status: "skipped"}
+		tag, _ := g.lookupTag(dstInternal.Tag(j))
+		switch {
+		case rules[name] != "":
+			field.Status, field.Src, field.Rule, field.Strategy = "mapped", mapped[name], rules[name], strategies[name]
+		case tag == "-":
+			field.Status, field.Reason = "ignored", fmt.Sprintf("tagged %s:\"-\"", g.tagKey)
+		case profileReasons[name] != "":
+			field.Status, field.Reason = "ignored", profileReasons[name]
+		case notCopied[name] != "":
+			field.Status, field.Reason = "ignored", "not copyable: "+notCopied[name]
+		case ignored[name] && !dstField.Exported() && !dst.local:
+			field.Status, field.Reason = "ignored", "unexported field of another package"
+		case ignored[name]:
+			field.Status, field.Reason = "ignored", "ignored by the mapping"
+		case skipped[name] != "":
+			field.Reason = skipped[name]
+		default:
+			field.Reason = "no src field"
+		}
+		report.Fields = append(report.Fields, field)
+		switch {
+		case field.Status == "skipped":
+			fmt.Fprintf(&todos, "	// TODO(repacker): field %s skipped: %s\n", name, todoReason(field.Reason))
+			if doc := dst.pkg.docs[dst.object.Name()+"."+name]; doc != "" {
+				todos.WriteString(commentLines("	", doc))
+			}
+		case field.Status == "ignored" && (dstField.Exported() || dst.local):
+			// Noted too, so that the analyzer tells them from dst fields
+			// added since.
+			fmt.Fprintf(&todos, "	// repacker: field %s ignored: %s\n", name, field.Reason)
+		}
+		if field.Status == "mapped" {
+			g.logger.printf(levelInfo, "%s: %s mapped from %s by %s", funcName, name, field.Src, field.Rule)
+		} else {
+			g.logger.printf(levelInfo, "%s: %s %s: %s", funcName, name, field.Status, field.Reason)
+		}
+	}
+	// The src fields mapped into an embedded struct are used too.
+	var used []string
+	for name, provenance := range mapped {
+		if rules[name] != "embedded" {
+			used = append(used, provenance)
+		}
+	}
+	for _, c := range g.report.Converters {
+		for _, embeddedFuncName := range embeddedFuncs {
+			if c.Func != embeddedFuncName {
+				continue
+			}
+			for _, f := range c.Fields {
+				used = append(used, f.Src)
+			}
+		}
+	}
+	report.Unused = unusedFields(srcFields, fieldSrcs, provenanceOf, used)
+	g.report.Converters = append(g.report.Converters, report)
+	for j := range entries {
+		body.Write(entries[j].Bytes())
+		calls.Write(setterCalls[j].Bytes())
+	}
+	if dst.typ.populate == "" {
+		// The hook and then the options follow the mapping of the fields.
+		hook, err := g.hookCode(srcs, dst, byValue, errResult)
+		if err != nil {
+			return "", err
+		}
+		calls.WriteString(hook)
+		if optionType != "" {
+			calls.WriteString(optionsCode(byValue))
+		}
+	}
+	code.Write(variables.Bytes())
+	code.Write(todos.Bytes())
+	switch {
+	case dst.typ.populate != "":
+	case calls.Len() > 0:
+		fmt.Fprintf(&code, "	d := %s{\n", dstLiteral)
+	default:
+		fmt.Fprintf(&code, "	return %s{\n", dstLiteral)
+	}
+	code.Write(body.Bytes())
+	switch {
+	case dst.typ.populate != "" && g.withError:
+		code.WriteString("	return nil\n")
+	case dst.typ.populate != "":
+	case calls.Len() > 0 && g.withError:
+		code.WriteString("	}\n")
+		code.Write(calls.Bytes())
+		code.WriteString("	return d, nil\n")
+	case calls.Len() > 0:
+		code.WriteString("	}\n")
+		code.Write(calls.Bytes())
+		code.WriteString("	return d\n")
+	case g.withError:
+		code.WriteString("	}, nil\n")
+	default:
+		code.WriteString("	}\n")
+	}
+	code.WriteString("}\n")
+
+	// The doc comment notes the fields sharing memory with the srcs.
+	var deepCopiedNames, sharedNames []string
+	for j := 0; j < dstInternal.NumFields(); j++ {
+		if name := dstInternal.Field(j).Name(); deepCopied[name] {
+			deepCopiedNames = append(deepCopiedNames, name)
+		} else if shared[name] {
+			sharedNames = append(sharedNames, name)
+		}
+	}
+	g.buf.Write(code.Bytes()[:semanticsAt])
+	g.buf.WriteString(copySemantics(deepCopiedNames, sharedNames))
+	g.buf.Write(code.Bytes()[semanticsAt:])
+	return funcName, nil
+}
+
+// unusedFields returns the provenances of the src fields that none of the
+// used provenances reads, whole or in part, for the report. Embedded structs
+// are left to their promoted fields, and the unexported fields of other
+// packages, which cannot be read, are left out.
+func unusedFields(srcFields []Field, fieldSrcs []Object, provenanceOf func(int) string, provenances []string) []string {
+	var used []string
+	for _, provenance := range provenances {
+		used = append(used, strings.FieldsFunc(provenance, func(r rune) bool { return r == '+' || r == '|' })...)
+	}
+	var unused []string
+fields:
+	for i, f := range srcFields {
+		if !f.Exported() && !fieldSrcs[i].local {
+			continue
+		}
+		for k := range srcFields {
+			if fieldSrcs[k].param == fieldSrcs[i].param && strings.HasPrefix(srcFields[k].path, f.path+".") {
+				continue fields
+			}
+		}
+		provenance := provenanceOf(i)
+		for _, u := range used {
+			if u == provenance || strings.HasPrefix(u, provenance+".") || strings.HasPrefix(provenance, u+".") {
+				continue fields
+			}
+		}
+		unused = append(unused, provenance)
+	}
+	return unused
+}
+
+// srcDocs returns the paragraphs of the doc comment of a converter that
+// describe its srcs with their own doc comments, if any.
+func srcDocs(srcs []Object) string {
+	var buf bytes.Buffer
+	for _, src := range srcs {
+		if doc := src.pkg.docs[src.object.Name()]; doc != "" {
+			buf.WriteString("//\n")
+			buf.WriteString(commentLines("", strings.TrimPrefix(src.FullName(), "*")+": "+doc))
+		}
+	}
+	return buf.String()
+}
+
+// generateDiff generates the function named diffName that lists the mapped
+// fields of a dst differing from those the converter converts from the srcs.
+func (g *Generator) generateDiff(funcName, diffName string) {
+	conv, ok := g.converters[funcName]
+	if !ok || g.funcNames[diffName] {
+		return
+	}
+	if conv.dst.typeArgs != "" {
+		g.logger.printf(levelInfo, "skip diff of %s: generic types", funcName)
+		return
+	}
+	g.funcNames[diffName] = true
+	g.helpers["FieldDiff"] = true
+	var args, srcFullNames, nilGuards []string
+	params := g.paramDecls()
+	for _, src := range conv.srcs {
+		params = append(params, fmt.Sprintf("%s %s", src.param, src.FullName()))
+		args = append(args, src.param)
+		srcFullNames = append(srcFullNames, src.FullName())
+		nilGuards = append(nilGuards, src.param+" == nil")
+	}
+	results, errResult := "[]FieldDiff", ""
+	if g.withError {
+		results, errResult = "([]FieldDiff, error)", ", nil"
+	}
+	g.Printf("\n// %s lists the fields of d that differ from those mapped from %s\n", diffName, strings.Join(srcFullNames, " and "))
+	g.Printf("func %s(%s, d %s) %s {\n", diffName, strings.Join(params, ", "), conv.dst.FullName(), results)
+	g.Printf("	if %s {\n		return nil%s\n	}\n", strings.Join(nilGuards, " && "), errResult)
+	g.Printf("	if d == nil {\n		d = %s{}\n	}\n", conv.dst.PtrName())
+	if conv.dst.typ.byValue && len(conv.srcs) > 1 {
+		// A nil src leaves its fields with the zero values, as in the
+		// constructors by pointer.
+		for _, src := range conv.srcs {
+			g.Printf("	if %s == nil {\n		%s = &%s{}\n	}\n", src.param, src.param, strings.TrimPrefix(src.FullName(), "*"))
+		}
+	}
+	call := g.constructorCall(funcName, conv, args)
+	// A populating method fills a copy of d, so that the fields it leaves
+	// untouched do not differ.
+	switch {
+	case conv.dst.typ.populate != "" && g.withError:
+		g.Printf("	c := *d\n	if err := c.%s(%s); err != nil {\n		return nil, err\n	}\n", conv.dst.typ.populate, g.paramArgs(args...))
+	case conv.dst.typ.populate != "":
+		g.Printf("	c := *d\n	c.%s(%s)\n", conv.dst.typ.populate, g.paramArgs(args...))
+	case g.withError:
+		g.Printf("	c, err := %s\n	if err != nil {\n		return nil, err\n	}\n", call)
+	default:
+		g.Printf("	c := %s\n", call)
+	}
+	g.Printf("	var diffs []FieldDiff\n")
+	for _, name := range conv.mapped {
+		g.Printf("	if !reflect.DeepEqual(c.%s, d.%s) {\n", name, name)
+		g.Printf("		diffs = append(diffs, FieldDiff{Field: %s, Old: d.%s, New: c.%s})\n	}\n", strconv.Quote(name), name, name)
+	}
+	g.Printf("	return diffs%s\n}\n", errResult)
+}
+
+// generateTest generates the test that the converter sets every checked
+// dst field from populated srcs, and copies the intact ones as they are.
+func (g *Generator) generateTest(funcName string) {
+	conv, ok := g.converters[funcName]
+	if !ok {
+		return
+	}
+	if g.genFuzz {
+		defer g.generateFuzz(funcName, conv)
+	}
+	if reason := conv.untested(); reason != "" {
+		g.logger.printf(levelInfo, "skip test of %s: %s", funcName, reason)
+		return
+	}
+	var buf bytes.Buffer
+	var args, checked, intact []string
+	fmt.Fprintf(&buf, "\nfunc Test%s(t *testing.T) {\n", strings.Replace(funcName, ".", "", -1))
+	for _, src := range conv.srcs {
+		fmt.Fprintf(&buf, "	%s := &%s{}\n", src.param, strings.TrimPrefix(src.FullName(), "*"))
+		args = append(args, src.param)
+	}
+	for _, stmt := range conv.setup {
+		fmt.Fprintf(&buf, "	%s\n", stmt)
+	}
+	buf.WriteString(g.paramsCode())
+	buf.WriteString(g.testCallCode(funcName, conv, args, "d"))
+	for _, name := range conv.checked {
+		checked = append(checked, strconv.Quote(name))
+		if want, ok := conv.intact[name]; ok {
+			intact = append(intact, fmt.Sprintf("{%s, d.%s, %s}", strconv.Quote(name), name, want))
+		}
+	}
+	fmt.Fprintf(&buf, "	for _, name := range []string{%s} {\n", strings.Join(checked, ", "))
+	fmt.Fprintf(&buf, "		if reflect.ValueOf(d).Elem().FieldByName(name).IsZero() {\n")
+	fmt.Fprintf(&buf, "			t.Errorf(\"%%s is not mapped\", name)\n		}\n	}\n")
+	buf.WriteString(intactTestCode(intact))
+	buf.WriteString("}\n")
+	g.testBuf.Write(buf.Bytes())
+	if g.genBench {
+		g.generateBench(funcName, conv)
+	}
+}
+
+// generateBench generates the benchmark of the converter on the srcs the
+// test populates, reporting its allocations, so that regressions of the
+// generated code show in benchstat.
+func (g *Generator) generateBench(funcName string, conv *converter) {
+	// The benchmark and its loop must not shadow the srcs.
+	bench, loop := "b", "i"
+	var args []string
+	for _, src := range conv.srcs {
+		args = append(args, src.param)
+		switch src.param {
+		case bench:
+			bench = "tb"
+		case loop:
+			loop = "n"
+		}
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\nfunc Benchmark%s(%s *testing.B) {\n", strings.Replace(funcName, ".", "", -1), bench)
+	for _, src := range conv.srcs {
+		fmt.Fprintf(&buf, "	%s := &%s{}\n", src.param, strings.TrimPrefix(src.FullName(), "*"))
+	}
+	for _, stmt := range conv.setup {
+		fmt.Fprintf(&buf, "	%s\n", stmt)
+	}
+	buf.WriteString(g.paramsCode())
+	call := g.constructorCall(funcName, conv, args)
+	fills := conv.dst.typ.populate != ""
+	if fills {
+		// The same dst is filled again and again.
+		fmt.Fprintf(&buf, "	d := %s{}\n", conv.dst.PtrName())
+		call = fmt.Sprintf("d.%s(%s)", conv.dst.typ.populate, g.paramArgs(args...))
+		if conv.dst.typ.merge != "" {
+			call = fmt.Sprintf("%s(%s, d)", funcName, g.paramArgs(args...))
+		}
+	}
+	fmt.Fprintf(&buf, "	%s.ReportAllocs()\n	%s.ResetTimer()\n", bench, bench)
+	fmt.Fprintf(&buf, "	for %s := 0; %s < %s.N; %s++ {\n", loop, loop, bench, loop)
+	switch {
+	case g.withError && fills:
+		fmt.Fprintf(&buf, "		if err := %s; err != nil {\n			%s.Fatal(err)\n		}\n", call, bench)
+	case g.withError:
+		fmt.Fprintf(&buf, "		if _, err := %s; err != nil {\n			%s.Fatal(err)\n		}\n", call, bench)
+	default:
+		fmt.Fprintf(&buf, "		%s\n", call)
+	}
+	buf.WriteString("	}\n}\n")
+	g.testBuf.Write(buf.Bytes())
+}
+
+// generateRoundTripTest generates the test that the fields of a src copied
+// intact to the dst by the converter are copied back by the reverse one.
+func (g *Generator) generateRoundTripTest(funcName, reverseName string) {
+	conv, reverse := g.converters[funcName], g.converters[reverseName]
+	if conv == nil || reverse == nil {
+		return
+	}
+	reason := conv.untested()
+	if reason == "" {
+		reason = reverse.untested()
+	}
+	if reason == "" && len(conv.srcs) > 1 {
+		reason = "merged srcs"
+	}
+	if reason != "" {
+		g.logger.printf(levelInfo, "skip round-trip test of %s: %s", funcName, reason)
+		return
+	}
+	src := conv.srcs[0]
+	var intact []string
+	for _, name := range conv.checked {
+		path := strings.TrimPrefix(conv.intact[name], src.param+".")
+		if want, ok := reverse.intact[path]; ok && want == reverse.srcs[0].param+"."+name {
+			intact = append(intact, fmt.Sprintf("{%s, got.%s, %s.%s}", strconv.Quote(path), path, src.param, path))
+		}
+	}
+	if len(intact) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\nfunc Test%sRoundTrip(t *testing.T) {\n", strings.Replace(funcName, ".", "", -1))
+	fmt.Fprintf(&buf, "	%s := &%s{}\n", src.param, strings.TrimPrefix(src.FullName(), "*"))
+	for _, stmt := range conv.setup {
+		fmt.Fprintf(&buf, "	%s\n", stmt)
+	}
+	buf.WriteString(g.paramsCode())
+	buf.WriteString(g.testCallCode(funcName, conv, []string{src.param}, "d"))
+	buf.WriteString(g.testCallCode(reverseName, reverse, []string{"d"}, "got"))
+	buf.WriteString(intactTestCode(intact))
+	buf.WriteString("}\n")
+	g.testBuf.Write(buf.Bytes())
+}
+
+// generateFuzz generates the fuzz target calling the converter with the
+// src strings it parses set from its arguments, so that the parsing of
+// malformed values can be fuzzed for panics.
+func (g *Generator) generateFuzz(funcName string, conv *converter) {
+	if conv.dst.typeArgs != "" {
+		g.logger.printf(levelInfo, "skip fuzz target of %s: generic types", funcName)
+		return
+	}
+	// The params of the fuzz function must not shadow the srcs, the dst
+	// and those of -params.
+	used := map[string]bool{"f": true, "t": true, "d": true}
+	for _, p := range g.params {
+		used[p.name] = true
+	}
+	var args []string
+	for _, src := range conv.srcs {
+		used[src.param] = true
+		args = append(args, src.param)
+	}
+	var params, seeds, setup []string
+	for _, field := range conv.fuzzed {
+		typ, pointer := field.typ, false
+		if p, ok := typ.(*types.Pointer); ok {
+			typ, pointer = p.Elem(), true
+		}
+		if !isString(typ) {
+			continue
+		}
+		param := toLowerFirstChar(strings.Replace(field.path, ".", "", -1))
+		if used[param] || token.IsKeyword(param) {
+			param += "Value"
+		}
+		used[param] = true
+		params = append(params, param)
+		seeds = append(seeds, strconv.Quote("1"))
+		value := param
+		typeName := types.TypeString(typ, conv.dst.qualifier)
+		switch {
+		case pointer && !types.Identical(typ, types.Typ[types.String]):
+			value = fmt.Sprintf("(*%s)(&%s)", typeName, param)
+		case pointer:
+			value = "&" + param
+		case !types.Identical(typ, types.Typ[types.String]):
+			value = fmt.Sprintf("%s(%s)", typeName, param)
+		}
+		setup = append(setup, fmt.Sprintf("%s.%s = %s", field.src, field.path, value))
+	}
+	if len(params) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\nfunc Fuzz%s(f *testing.F) {\n", strings.Replace(funcName, ".", "", -1))
+	fmt.Fprintf(&buf, "	f.Add(%s)\n", strings.Join(seeds, ", "))
+	fmt.Fprintf(&buf, "	f.Fuzz(func(t *testing.T, %s string) {\n", strings.Join(params, ", "))
+	for _, src := range conv.srcs {
+		fmt.Fprintf(&buf, "	%s := &%s{}\n", src.param, strings.TrimPrefix(src.FullName(), "*"))
+	}
+	for _, stmt := range setup {
+		fmt.Fprintf(&buf, "	%s\n", stmt)
+	}
+	buf.WriteString(g.paramsCode())
+	// Malformed values fail with errors, which are not checked.
+	call := g.constructorCall(funcName, conv, args)
+	switch {
+	case conv.dst.typ.merge != "":
+		fmt.Fprintf(&buf, "	_ = %s(%s, %s{})\n", funcName, g.paramArgs(args...), conv.dst.PtrName())
+	case conv.dst.typ.populate != "":
+		fmt.Fprintf(&buf, "	d := %s{}\n	_ = d.%s(%s)\n", conv.dst.PtrName(), conv.dst.typ.populate, g.paramArgs(args...))
+	default:
+		fmt.Fprintf(&buf, "	_, _ = %s\n", call)
+	}
+	buf.WriteString("	})\n}\n")
+	g.testBuf.Write(buf.Bytes())
+}
+
+// adopt adds the setup of the srcs of the nested converter, of the same
+// srcs, to that of conv, renaming its params, unless conv sets them already.
+func (conv *converter) adopt(nested *converter) {
+	if nested.untestable != "" && conv.untestable == "" {
+		conv.untestable = nested.untestable
+	}
+	for _, stmt := range nested.setup {
+		for i, src := range nested.srcs {
+			if strings.HasPrefix(stmt, src.param+".") {
+				stmt = conv.srcs[i].param + strings.TrimPrefix(stmt, src.param)
+				break
+			}
+		}
+		lhs := strings.SplitN(stmt, " = ", 2)[0] + " = "
+		var set bool
+		for _, s := range conv.setup {
+			set = set || strings.HasPrefix(s, lhs)
+		}
+		if !set {
+			conv.setup = append(conv.setup, stmt)
+		}
+	}
+}
+
+// untested returns why the converter gets no test, if so.
+func (conv *converter) untested() string {
+	if conv.dst.typeArgs != "" {
+		return "generic types"
+	}
+	return conv.untestable
+}
+
+// testCallCode returns the code of a test calling the converter with args,
+// setting the dst named result.
+func (g *Generator) testCallCode(funcName string, conv *converter, args []string, result string) string {
+	var buf bytes.Buffer
+	call := g.constructorCall(funcName, conv, args)
+	if conv.dst.typ.byValue && conv.dst.typ.populate == "" {
+		// The test checks the dst through a pointer all the same.
+		if g.withError {
+			fmt.Fprintf(&buf, "	%sValue, err := %s\n	if err != nil {\n		t.Fatal(err)\n	}\n", result, call)
+		} else {
+			fmt.Fprintf(&buf, "	%sValue := %s\n", result, call)
+		}
+		fmt.Fprintf(&buf, "	%s := &%sValue\n", result, result)
+		return buf.String()
+	}
+	switch {
+	case conv.dst.typ.populate != "":
+		fmt.Fprintf(&buf, "	%s := %s{}\n", result, conv.dst.PtrName())
+		call = fmt.Sprintf("%s.%s(%s)", result, conv.dst.typ.populate, g.paramArgs(args...))
+		if conv.dst.typ.merge != "" {
+			call = fmt.Sprintf("%s(%s, %s)", funcName, g.paramArgs(args...), result)
+		}
+		if g.withError {
+			fmt.Fprintf(&buf, "	if err := %s; err != nil {\n		t.Fatal(err)\n	}\n", call)
+		} else {
+			fmt.Fprintf(&buf, "	%s\n", call)
+		}
+	case g.withError:
+		fmt.Fprintf(&buf, "	%s, err := %s\n	if err != nil {\n		t.Fatal(err)\n	}\n", result, call)
+	default:
+		fmt.Fprintf(&buf, "	%s := %s\n", result, call)
+	}
+	return buf.String()
+}
+
+// constructorCall returns the call of the constructor funcName of conv on
+// args, the pointers to its srcs, which are dereferenced for -byvalue.
+func (g *Generator) constructorCall(funcName string, conv *converter, args []string) string {
+	if g.methods[funcName] {
+		// Methods of values are also methods of their pointers.
+		return g.callCode(funcName, args[0], true)
+	}
+	if conv.dst.typ.byValue && conv.dst.typ.populate == "" {
+		values := make([]string, len(args))
+		for i, arg := range args {
+			values[i] = "*" + arg
+		}
+		args = values
+	}
+	return fmt.Sprintf("%s(%s)", funcName, g.paramArgs(args...))
+}
+
+// intactTestCode returns the code of a test that each of the fields of
+// the table, of their name and the got and wanted values, are equal.
+func intactTestCode(table []string) string {
+	if len(table) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "	for _, tt := range []struct {\n		name      string\n		got, want interface{}\n	}{\n")
+	for _, row := range table {
+		fmt.Fprintf(&buf, "		%s,\n", row)
+	}
+	fmt.Fprintf(&buf, "	} {\n		if !reflect.DeepEqual(tt.got, tt.want) {\n")
+	fmt.Fprintf(&buf, "			t.Errorf(\"%%s = %%v, want %%v\", tt.name, tt.got, tt.want)\n		}\n	}\n")
+	return buf.String()
+}
+
+// testSample returns the code of a value of the src field that converts to
+// a non-zero dst field, or "" if the conversion cannot be relied on.
+func (g *Generator) testSample(conv *converter, src Object, m *Mapping, f Field, dstField *types.Var, dstTag string) string {
+	if g.hasFieldOption(dstTag, f.tag, "convert", "using", "transform") || (m != nil && m.Convert[dstField.Name()] != "") ||
+		g.typeConverter(f.Type(), dstField.Type()) != nil || isWellKnown(f.Type()) || isWellKnown(dstField.Type()) ||
+		isSQLNull(f.Type()) || isSQLNull(dstField.Type()) || isBigNumber(f.Type()) || isBigNumber(dstField.Type()) {
+		return ""
+	}
+	if !f.Exported() && !src.local {
+		return ""
+	}
+	if p, ok := f.Type().(*types.Pointer); ok && isString(p.Elem()) && isTime(dstField.Type()) {
+		layout := "time.RFC3339"
+		if l, ok := g.fieldOption(dstTag, f.tag, "layout"); ok {
+			layout = strconv.Quote(l)
+		}
+		return fmt.Sprintf("func() *string { v := time.Unix(1, 0).UTC().Format(%s); return &v }()", layout)
+	}
+	// unparsable returns no value for a dst the string parses into: the
+	// values ParseX or UnmarshalText accept are unknown, and the empty
+	// string fails to parse.
+	unparsable := func() string {
+		if g.withError && conv.untestable == "" {
+			var parserName string
+			if named, ok := textUnmarshaler(dstField.Type()); ok {
+				parserName = fmt.Sprintf("(*%s).UnmarshalText", named.Obj().Name())
+			} else if named, _ := parsable(dstField.Type()); named != nil {
+				parserName = "Parse" + named.Obj().Name()
+				if parser, err := lookupParser(named); err == nil {
+					parserName = parser.Name()
+				}
+			}
+			conv.untestable = fmt.Sprintf("no valid %s for %s", f.Name(), parserName)
+		}
+		return ""
+	}
+	_, unmarshals := textUnmarshaler(dstField.Type())
+	if unmarshalsJSON(f.Type(), dstField.Type(), g.encodesJSON(dstTag, f.tag)) {
+		// The empty src leaves the zero value, and any other must be JSON.
+		if g.withError && conv.untestable == "" {
+			conv.untestable = fmt.Sprintf("no valid %s for json.Unmarshal", f.Name())
+		}
+		return ""
+	}
+	if p, ok := f.Type().(*types.Pointer); ok && isString(p.Elem()) && (isParsable(dstField.Type()) || unmarshals) {
+		return unparsable()
+	}
+	// A duration of one unit converts to 1.
+	if unit, ok := g.fieldOption(dstTag, f.tag, "unit"); ok && durationUnits[unit] != "" && isDuration(f.Type()) {
+		return durationUnits[unit]
+	}
+	// An enum is populated with a constant mapped to a non-zero constant.
+	if cases, _ := enumCases(f.Type(), dstField.Type(), src.qualifier, conv.dst.qualifier); len(cases) > 0 {
+		for _, c := range cases {
+			if value := c[1].Val().ExactString(); value != "0" && value != `""` {
+				return constCode(c[0], src.qualifier)
+			}
+		}
+		return ""
+	}
+	if isString(f.Type()) {
+		switch {
+		case isTime(dstField.Type()):
+			layout := "time.RFC3339"
+			if l, ok := g.fieldOption(dstTag, f.tag, "layout"); ok {
+				layout = strconv.Quote(l)
+			}
+			return fmt.Sprintf("time.Unix(1, 0).UTC().Format(%s)", layout)
+		case g.hasFieldOption(dstTag, f.tag, "true", "false"):
+			trueValue, _ := g.fieldOption(dstTag, f.tag, "true")
+			return strconv.Quote(trueValue)
+		case isDuration(dstField.Type()):
+			return `"1s"`
+		case isParsable(dstField.Type()) && !sameUnderlying(f.Type(), dstField.Type()),
+			unmarshals && !castable(f.Type(), dstField.Type()):
+			return unparsable()
+		}
+	}
+	return g.sampleCode(conv, f.Type(), src.qualifier, 0)
+}
+
+// sampleCode returns the code of a non-zero value of type t,
+// or "" if there is none to write. A struct with a converter is
+// populated as in the test of the converter.
+func (g *Generator) sampleCode(conv *converter, t types.Type, qualifier types.Qualifier, depth int) string {
+	if isTime(t) {
+		if _, ok := t.(*types.Pointer); ok {
+			return "func() *time.Time { v := time.Unix(1, 0); return &v }()"
+		}
+		return "time.Unix(1, 0)"
+	}
+	if named, ok := types.Unalias(t).(*types.Named); ok && !named.Obj().Exported() && qualifier(named.Obj().Pkg()) != "" {
+		return ""
+	} else if ok && named.Obj().Pkg() != nil && qualifier(named.Obj().Pkg()) != "" {
+		// The test imports the packages of the samples (e.g. uuid.UUID{1}).
+		g.testImports = append(g.testImports, named.Obj().Pkg().Path())
+	}
+	typeName := types.TypeString(t, qualifier)
+	if nested, ok := g.samples[types.TypeString(t, packageName)]; ok && isStruct(t) {
+		if nested.untestable != "" && g.withError {
+			if conv.untestable == "" {
+				conv.untestable = nested.untestable
+			}
+			return ""
+		}
+		return fmt.Sprintf("*func() *%s {\n%s := &%s{}\n%s\nreturn %s\n}()",
+			typeName, nested.srcs[0].param, typeName, strings.Join(nested.setup, "\n"), nested.srcs[0].param)
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch info := u.Info(); {
+		case info&types.IsString != 0:
+			return `"1"`
+		case info&types.IsBoolean != 0:
+			return "true"
+		case info&types.IsNumeric != 0:
+			return "1"
+		}
+	case *types.Pointer:
+		elem := g.sampleCode(conv, u.Elem(), qualifier, depth)
+		if elem == "" {
+			return ""
+		}
+		if strings.HasPrefix(elem, "*func()") {
+			return elem[1:]
+		}
+		if isStruct(u.Elem()) {
+			return "&" + elem
+		}
+		return fmt.Sprintf("func() %s { var v %s = %s; return &v }()", typeName, types.TypeString(u.Elem(), qualifier), elem)
+	case *types.Slice:
+		if elem := g.sampleCode(conv, u.Elem(), qualifier, depth); elem != "" {
+			return fmt.Sprintf("%s{%s}", typeName, elem)
+		}
+	case *types.Array:
+		if elem := g.sampleCode(conv, u.Elem(), qualifier, depth); elem != "" && u.Len() > 0 {
+			return fmt.Sprintf("%s{%s}", typeName, elem)
+		}
+	case *types.Map:
+		key := g.sampleCode(conv, u.Key(), qualifier, depth)
+		elem := g.sampleCode(conv, u.Elem(), qualifier, depth)
+		if key != "" && elem != "" {
+			return fmt.Sprintf("%s{%s: %s}", typeName, key, elem)
+		}
+	case *types.Struct:
+		// Nested structs are populated to a few levels.
+		if depth > 2 {
+			return typeName + "{}"
+		}
+		var fields []string
+		for i := 0; i < u.NumFields(); i++ {
+			field := u.Field(i)
+			if !field.Exported() {
+				continue
+			}
+			if value := g.sampleCode(conv, field.Type(), qualifier, depth+1); value != "" {
+				fields = append(fields, fmt.Sprintf("%s: %s", field.Name(), value))
+			}
+		}
+		return fmt.Sprintf("%s{%s}", typeName, strings.Join(fields, ", "))
+	}
+	return ""
+}
+
+// anonymousCode returns the literal of the anonymous struct dst with the
+// fields of the same names of expr, of the struct src, assigned or
+// converted. Anonymous structs in them are mapped the same way, and the
+// other fields are skipped.
+func (g *Generator) anonymousCode(expr string, src, dst *types.Struct, local bool, qualifier types.Qualifier) string {
+	var fields []string
+	for i := 0; i < dst.NumFields(); i++ {
+		dstField := dst.Field(i)
+		var srcField *types.Var
+		for j := 0; j < src.NumFields(); j++ {
+			f := src.Field(j)
+			if f.Name() == dstField.Name() || (g.match != "" && g.matchName(f.Name()) == g.matchName(dstField.Name())) {
+				srcField = f
+				break
+			}
+		}
+		if srcField == nil || (!srcField.Exported() && !local) {
+			continue
+		}
+		value := fmt.Sprintf("%s.%s", expr, srcField.Name())
+		srcType, dstType := srcField.Type(), dstField.Type()
+		srcStruct, dstStruct := anonymousStruct(srcType), anonymousStruct(dstType)
+		_, srcIsPtr := srcType.(*types.Pointer)
+		_, dstIsPtr := dstType.(*types.Pointer)
+		switch {
+		case assignable(srcType, dstType):
+		case srcStruct != nil && dstStruct != nil && !srcIsPtr && (isAnonymous(srcType) || isAnonymous(dstType)):
+			value = g.anonymousCode(value, srcStruct, dstStruct, local, qualifier)
+			if dstIsPtr {
+				value = "&" + value
+			}
+		case sameUnderlying(srcType, dstType), castable(srcType, dstType) && !(g.strict && g.narrowing(srcType, dstType)):
+			value = conversionCode(types.TypeString(dstType, qualifier), value)
+		case types.Identical(dstType, types.Typ[types.String]) && isNumeric(srcType):
+			value = formatCode(value, srcType)
+		default:
+			g.logger.printf(levelInfo, "skip field (%s) due to difference types", value)
+			continue
+		}
+		fields = append(fields, fmt.Sprintf("%s: %s,\n", dstField.Name(), value))
+	}
+	return fmt.Sprintf("%s{\n%s}", structCode(dst, qualifier), strings.Join(fields, ""))
+}
+
+// structCode returns the code of the anonymous struct type s, with its
+// tags in back quotes as they are written, unlike types.TypeString.
+func structCode(s *types.Struct, qualifier types.Qualifier) string {
+	var fields []string
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		typeName := types.TypeString(f.Type(), qualifier)
+		if nested, ok := types.Unalias(f.Type()).(*types.Struct); ok {
+			typeName = structCode(nested, qualifier)
+		} else if p, ok := f.Type().(*types.Pointer); ok {
+			if nested, ok := p.Elem().(*types.Struct); ok {
+				typeName = "*" + structCode(nested, qualifier)
+			}
+		}
+		field := typeName
+		if !f.Embedded() {
+			field = f.Name() + " " + typeName
+		}
+		if tag := s.Tag(i); tag != "" && !strings.Contains(tag, "`") {
+			field += " `" + tag + "`"
+		} else if tag != "" {
+			field += " " + strconv.Quote(tag)
+		}
+		fields = append(fields, field)
+	}
+	return fmt.Sprintf("struct{%s}", strings.Join(fields, "; "))
+}
+
+// isAnonymous reports whether t is an anonymous struct, or a pointer,
+// slice, array or map of them.
+func isAnonymous(t types.Type) bool {
+	for {
+		switch u := t.(type) {
+		case *types.Pointer:
+			t = u.Elem()
+		case *types.Slice:
+			t = u.Elem()
+		case *types.Array:
+			t = u.Elem()
+		case *types.Map:
+			t = u.Elem()
+		case *types.Struct:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// anonymousStruct returns the struct t is or points to, if any.
+func anonymousStruct(t types.Type) *types.Struct {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	s, _ := t.Underlying().(*types.Struct)
+	return s
+}
+
+// share reports whether another generated file of the package declares
+// the func funcName, which is then called instead of declared again.
+func (g *Generator) share(funcName string) bool {
+	name, ok := g.shared[funcName]
+	if !ok {
+		return false
+	}
+	g.logger.printf(levelInfo, "share %s of %s", funcName, filepath.Base(name))
+	g.funcNames[funcName] = true
+	return true
+}
+
+func (g *Generator) generateSliceCode(src, dst Object) (funcName string, err error) {
+	dstType := dst.funcName()
+	if dst.typ.isPointer {
+		dstType = "Ptr" + dstType
+	}
+	srcType := strings.Title(src.pkgName()) + src.funcName()
+	if src.typ.isPointer {
+		srcType = "Ptr" + srcType
+	}
+	funcName = fmt.Sprintf("New%sSliceFrom%s", dstType, srcType)
+	if g.generics && !src.typ.named {
+		return funcName, g.generateGenericCall(funcName, src, dst)
+	}
+	if g.funcNames[funcName] || g.share(funcName) {
+		return funcName, nil
+	}
+	g.funcNames[funcName] = true
+
+	if g.generics {
+		if err := g.generateGenericCall(funcName, src, dst); err != nil {
+			return "", err
+		}
+		results := "[]" + dst.SliceFullName()
+		if g.withError {
+			results = fmt.Sprintf("(%s, error)", results)
+		}
+		g.Printf("// %s creates []%s from []%s\n", funcName, dst.SliceFullName(), src.SliceFullName())
+		g.Printf("func %s(%ss []%s) %s {\n	return %s\n}\n", funcName, g.paramsPrefix(), src.SliceFullName(), results, g.callCode(funcName, "s", true))
+		return funcName, nil
+	}
+
+	elemFunc, err := g.generateCode([]Object{src}, dst)
+	if err != nil {
+		return "", err
+	}
+	nestedFunc := g.callCode(elemFunc, "t", src.typ.isPointer)
+	if !dst.typ.isPointer && !g.withError {
+		nestedFunc = fmt.Sprintf("*%s", nestedFunc)
+	}
+	variable := "t"
+	if g.withError {
+		variable = "v"
+		if !dst.typ.isPointer {
+			variable = "*v"
+		}
+	}
+	result, zeroElem := "v", ""
+	if src.typ.isPointer && !dst.typ.isPointer {
+		nestedFunc, result, variable, zeroElem = g.zeroElemCode(nestedFunc, dst)
+	}
+	// Elements of a generic type (e.g. []*Tree[T]) keep its type parameters.
+	params := src.typeParams()
+
+	var code bytes.Buffer
+	fmt.Fprintf(&code, "// %s creates []%s from []%s\n", funcName, dst.SliceFullName(), src.SliceFullName())
+	if g.withError {
+		fmt.Fprintf(&code, "func %s%s (%ss []%s) (d []%s, err error) {\n", funcName, params, g.paramsPrefix(), src.SliceFullName(), dst.SliceFullName())
+		fmt.Fprintf(&code, "	if s == nil {\n		return nil, nil\n	}\n")
+		fmt.Fprintf(&code, "	d = make([]%s, 0, len(s))\n", dst.SliceFullName())
+		fmt.Fprintf(&code, "	for i, t := range s{\n")
+		fmt.Fprintf(&code, "		%s, err := %s\n", result, nestedFunc)
+		fmt.Fprintf(&code, "		if err != nil {\n")
+		fmt.Fprintf(&code, "			return nil, fmt.Errorf(\"[%%d]: %%w\", i, err)\n")
+		fmt.Fprintf(&code, "		}\n")
+		code.WriteString(zeroElem)
+		fmt.Fprintf(&code, "		d = append(d, %s)\n", variable)
+		fmt.Fprintf(&code, "	}\n")
+		fmt.Fprintf(&code, "	return d, nil\n")
+	} else {
+		fmt.Fprintf(&code, "func %s%s (%ss []%s) (d []%s) {\n", funcName, params, g.paramsPrefix(), src.SliceFullName(), dst.SliceFullName())
+		fmt.Fprintf(&code, "	if s == nil {\n		return nil\n	}\n")
+		fmt.Fprintf(&code, "	d = make([]%s, 0, len(s))\n", dst.SliceFullName())
+		fmt.Fprintf(&code, "	for _, t := range s{\n")
+		code.WriteString(zeroElem)
+		fmt.Fprintf(&code, "		d = append(d, %s)\n", nestedFunc)
+		fmt.Fprintf(&code, "	}\n")
+		fmt.Fprintf(&code, "	return d\n")
+	}
+	fmt.Fprintf(&code, "}\n")
+	g.buf.Write(code.Bytes())
+
+	return funcName, nil
+}
+
+func (g *Generator) generateMapCode(src, dst Object) (funcName string, err error) {
+	dstType := dst.funcName()
+	if dst.typ.isPointer {
+		dstType = "Ptr" + dstType
+	}
+	srcType := strings.Title(src.pkgName()) + src.funcName()
+	if src.typ.isPointer {
+		srcType = "Ptr" + srcType
+	}
+	funcName = fmt.Sprintf("New%sMapFrom%s", dstType, srcType)
+	if g.generics && !src.typ.named {
+		return funcName, g.generateGenericCall(funcName, src, dst)
+	}
+	if g.funcNames[funcName] || g.share(funcName) {
+		return funcName, nil
+	}
+	g.funcNames[funcName] = true
+
+	// Without a key type (e.g. for -collections), any comparable key.
+	key, keyParam := "K", "[K comparable]"
+	if src.typ.mapKey != nil {
+		key, keyParam = types.TypeString(src.typ.mapKey, src.qualifier), ""
+	}
+	if g.generics {
+		if err := g.generateGenericCall(funcName, src, dst); err != nil {
+			return "", err
+		}
+		results := fmt.Sprintf("map[%s]%s", key, dst.SliceFullName())
+		if g.withError {
+			results = fmt.Sprintf("(%s, error)", results)
+		}
+		g.Printf("// %s creates map[%s]%s from map[%s]%s\n", funcName, key, dst.SliceFullName(), key, src.SliceFullName())
+		g.Printf("func %s%s(%ss map[%s]%s) %s {\n	return %s\n}\n", funcName, keyParam, g.paramsPrefix(), key, src.SliceFullName(), results, g.callCode(funcName, "s", true))
+		return funcName, nil
+	}
+
+	elemFunc, err := g.generateCode([]Object{src}, dst)
+	if err != nil {
+		return "", err
+	}
+	nestedFunc := g.callCode(elemFunc, "t", src.typ.isPointer)
+	if !dst.typ.isPointer && !g.withError {
+		nestedFunc = fmt.Sprintf("*%s", nestedFunc)
+	}
+	variable := "t"
+	if g.withError {
+		variable = "v"
+		if !dst.typ.isPointer {
+			variable = "*v"
+		}
+	}
+	result, zeroElem := "v", ""
+	if src.typ.isPointer && !dst.typ.isPointer {
+		nestedFunc, result, variable, zeroElem = g.zeroElemCode(nestedFunc, dst)
+	}
+	if params := src.typeParams(); params != "" && keyParam != "" {
+		keyParam = strings.TrimSuffix(params, "]") + ", K comparable]"
+	} else if params != "" {
+		keyParam = params
+	}
+
+	var code bytes.Buffer
+	fmt.Fprintf(&code, "// %s creates map[%s]%s from map[%s]%s\n", funcName, key, dst.SliceFullName(), key, src.SliceFullName())
+	if g.withError {
+		fmt.Fprintf(&code, "func %s%s (%ss map[%s]%s) (d map[%s]%s, err error) {\n", funcName, keyParam, g.paramsPrefix(), key, src.SliceFullName(), key, dst.SliceFullName())
+		fmt.Fprintf(&code, "	if s == nil {\n		return nil, nil\n	}\n")
+		fmt.Fprintf(&code, "	d = make(map[%s]%s, len(s))\n", key, dst.SliceFullName())
+		fmt.Fprintf(&code, "	for k, t := range s{\n")
+		fmt.Fprintf(&code, "		%s, err := %s\n", result, nestedFunc)
+		fmt.Fprintf(&code, "		if err != nil {\n")
+		fmt.Fprintf(&code, "			return nil, fmt.Errorf(\"[%%v]: %%w\", k, err)\n")
+		fmt.Fprintf(&code, "		}\n")
+		code.WriteString(zeroElem)
+		fmt.Fprintf(&code, "		d[k] = %s\n", variable)
+		fmt.Fprintf(&code, "	}\n")
+		fmt.Fprintf(&code, "	return d, nil\n")
+	} else {
+		fmt.Fprintf(&code, "func %s%s (%ss map[%s]%s) (d map[%s]%s) {\n", funcName, keyParam, g.paramsPrefix(), key, src.SliceFullName(), key, dst.SliceFullName())
+		fmt.Fprintf(&code, "	if s == nil {\n		return nil\n	}\n")
+		fmt.Fprintf(&code, "	d = make(map[%s]%s, len(s))\n", key, dst.SliceFullName())
+		fmt.Fprintf(&code, "	for k, t := range s{\n")
+		code.WriteString(zeroElem)
+		fmt.Fprintf(&code, "		d[k] = %s\n", nestedFunc)
+		fmt.Fprintf(&code, "	}\n")
+		fmt.Fprintf(&code, "	return d\n")
+	}
+	fmt.Fprintf(&code, "}\n")
+	g.buf.Write(code.Bytes())
+
+	return funcName, nil
+}
+
+// zeroElemCode returns the code converting the elements of a slice or map
+// of pointers (e.g. []*Bar) into non-pointers, which a nil element leaves
+// the zero value as its converter returns nil: the call, the variable of
+// its result, the variable of the element, and the code setting it.
+func (g *Generator) zeroElemCode(nestedFunc string, dst Object) (call, result, variable, code string) {
+	call = strings.TrimPrefix(nestedFunc, "*")
+	if g.withError {
+		return call, "c", "v", derefCode("v", dst.SliceFullName(), "c", "*c")
+	}
+	return "v", "", "v", derefCode("v", dst.SliceFullName(), "t", "*"+call)
+}
+
+// generateGenericCall records the call format of funcName converting the
+// slice or map of src into that of dst with a generic helper, which takes
+// the converter of each element (e.g. repackSlice(%s, NewFooFromBarBar)).
+func (g *Generator) generateGenericCall(funcName string, src, dst Object) error {
+	elemFunc, err := g.generateCode([]Object{src}, dst)
+	if err != nil {
+		return err
+	}
+	helper := "repackSlice"
+	if src.typ.isMap {
+		helper = "repackMap"
+	}
+	if g.withError {
+		helper += "WithError"
+	}
+	g.helpers[helper] = true
+	g.calls[funcName] = fmt.Sprintf("%s(%%s, %s)", helper, g.elemCode(elemFunc, src, dst))
+	return nil
+}
+
+// elemCode returns the function converting an element of src into one of
+// dst with elemFunc, for the generic helpers: elemFunc itself when both
+// are pointers and there are no -params to pass nor -options to take, or
+// else a function literal.
+func (g *Generator) elemCode(elemFunc string, src, dst Object) string {
+	if src.typ.isPointer && dst.typ.isPointer && len(g.params) == 0 && !g.funcOptions {
+		if g.methods[elemFunc] {
+			// A method expression (e.g. (*Bar).ToFoo).
+			return fmt.Sprintf("(%s).%s", src.FullName(), elemFunc[strings.Index(elemFunc, ".")+1:])
+		}
+		return elemFunc
+	}
+	srcElem, dstElem := src.SliceFullName(), dst.SliceFullName()
+	call := g.callCode(elemFunc, "t", src.typ.isPointer)
+	switch {
+	case !g.withError && dst.typ.isPointer:
+		return fmt.Sprintf("func(t %s) %s {\n	return %s\n}", srcElem, dstElem, call)
+	case !g.withError && src.typ.isPointer:
+		return fmt.Sprintf("func(t %s) (v %s) {\n	if t != nil {\n		v = *%s\n	}\n	return v\n}", srcElem, dstElem, call)
+	case !g.withError:
+		return fmt.Sprintf("func(t %s) %s {\n	return *%s\n}", srcElem, dstElem, call)
+	case dst.typ.isPointer:
+		return fmt.Sprintf("func(t %s) (%s, error) {\n	return %s\n}", srcElem, dstElem, call)
+	case src.typ.isPointer:
+		return fmt.Sprintf("func(t %s) (v %s, err error) {\n	c, err := %s\n	if err != nil || c == nil {\n		return v, err\n	}\n	return *c, nil\n}",
+			srcElem, dstElem, call)
+	default:
+		return fmt.Sprintf("func(t %s) (%s, error) {\n	v, err := %s\n	if err != nil {\n		return %s{}, err\n	}\n	return *v, nil\n}",
+			srcElem, dstElem, call, dstElem)
+	}
+}
+
+// packageHelpers are the helpers generated once per package by name, the
+// generic helpers of -generics and the FieldDiff of -diff, in the order they
+// are generated.
+var packageHelpers = []struct{ name, code string }{
+	{"repackSlice", `
+// repackSlice converts each element of s with f.
+func repackSlice[S, D any](s []S, f func(S) D) []D {
+	if s == nil {
+		return nil
+	}
+	d := make([]D, 0, len(s))
+	for _, t := range s {
+		d = append(d, f(t))
+	}
+	return d
+}
+`},
+	{"repackSliceWithError", `
+// repackSliceWithError converts each element of s with f, failing at the first error.
+func repackSliceWithError[S, D any](s []S, f func(S) (D, error)) ([]D, error) {
+	if s == nil {
+		return nil, nil
+	}
+	d := make([]D, 0, len(s))
+	for i, t := range s {
+		v, err := f(t)
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %w", i, err)
+		}
+		d = append(d, v)
+	}
+	return d, nil
+}
+`},
+	{"repackMap", `
+// repackMap converts each value of m with f.
+func repackMap[K comparable, S, D any](m map[K]S, f func(S) D) map[K]D {
+	if m == nil {
+		return nil
+	}
+	d := make(map[K]D, len(m))
+	for k, t := range m {
+		d[k] = f(t)
+	}
+	return d
+}
+`},
+	{"repackMapWithError", `
+// repackMapWithError converts each value of m with f, failing at the first error.
+func repackMapWithError[K comparable, S, D any](m map[K]S, f func(S) (D, error)) (map[K]D, error) {
+	if m == nil {
+		return nil, nil
+	}
+	d := make(map[K]D, len(m))
+	for k, t := range m {
+		v, err := f(t)
+		if err != nil {
+			return nil, fmt.Errorf("[%v]: %w", k, err)
+		}
+		d[k] = v
+	}
+	return d, nil
+}
+`},
+	{"FieldDiff", `
+// FieldDiff is a mapped field of a dst whose value differs from the one
+// converted from the srcs.
+type FieldDiff struct {
+	Field string      // name of the dst field
+	Old   interface{} // value of the dst
+	New   interface{} // value converted from the srcs
+}
+`},
+}
+
+// declaredElsewhere returns the files of the funcs and types declared by
+// the generated files of the directory other than the outputs, in the
+// build, by name.
+// Generated files are loaded without their declarations.
+func (g *Generator) declaredElsewhere(dir string, outputs ...string) (map[string]string, error) {
+	excluded := map[string]bool{}
+	for _, name := range outputs {
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		excluded[abs] = true
+	}
+	declared := map[string]string{}
+	for _, name := range g.generatedFiles(dir, false) {
+		if abs, err := filepath.Abs(name); err != nil || excluded[abs] || g.stale[abs] {
+			continue
+		}
+		if matched, err := g.buildContext.MatchFile(dir, filepath.Base(name)); err == nil && !matched {
+			// A file of other build constraints may declare the same.
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), name, nil, 0)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse %s: %s", name, err)
+		}
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil {
+					declared[decl.Name.Name] = name
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if spec, ok := spec.(*ast.TypeSpec); ok {
+						declared[spec.Name.Name] = name
+					}
+				}
+			}
+		}
+	}
+	return declared, nil
+}
+
+// staleFiles returns the stale files of generate among those the dsts of
+// names would be generated into by the other layouts, of the filename
+// template or the default ones, other than the outputs: the files of dir
+// generated by the same command but for its output files, declaring any of
+// pairFuncs, and their tests.
+func (g *Generator) staleFiles(filename, dir string, names, outputs, pairFuncs []string) ([]string, error) {
+	excluded := map[string]bool{}
+	for _, name := range outputs {
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		excluded[abs] = true
+	}
+	pairs := map[string]bool{}
+	for _, funcName := range pairFuncs {
+		pairs[funcName] = true
+	}
+	stale := map[string]bool{}
+	for name := range g.stale {
+		stale[name] = true
+	}
+	command := outputCommand(g.args)
+	for _, filename := range []string{filename, "{{.Dst}}_repack.go", "repack_gen.go"} {
+		for _, dstName := range names {
+			if filename == "" || dstName == "" {
+				continue
+			}
+			name, err := filepath.Abs(outputFile(Options{Filename: filename}, dir, dstName, false))
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if excluded[name] || stale[name] || !isGenerated(name) || command == "" || outputCommand(generatedArgs(name)) != command {
+				continue
+			}
+			f, err := parser.ParseFile(token.NewFileSet(), name, nil, 0)
+			if err != nil {
+				return nil, errors.Wrapf(err, "parse %s: %s", name, err)
+			}
+			for _, decl := range f.Decls {
+				if decl, ok := decl.(*ast.FuncDecl); ok && pairs[funcDeclName(decl)] {
+					g.logger.printf(levelInfo, "%s declares %s of another layout: generate it again", filepath.Base(name), decl.Name.Name)
+					stale[name] = true
+					if test := strings.TrimSuffix(name, ".go") + "_test.go"; isGenerated(test) && !excluded[test] {
+						stale[test] = true
+					}
+					break
+				}
+			}
+		}
+	}
+	var files []string
+	for name := range stale {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// outputFlags are the flags naming the output files of a run.
+var outputFlags = map[string]bool{"layout": true, "filename": true, "output": true, "o": true}
+
+// outputCommand returns the words of the command-line arguments args but
+// the outputFlags and their values, the same for the runs of a command
+// generating its pairs into other files. The jobs of a -config file share
+// its arguments, so they are not told apart: "".
+func outputCommand(args []string) string {
+	words := strings.Fields(strings.Join(args, " "))
+	var kept []string
+	for i := 0; i < len(words); i++ {
+		name := strings.TrimLeft(words[i], "-")
+		switch {
+		case strings.HasPrefix(words[i], "-") && (name == "config" || strings.HasPrefix(name, "config=")):
+			return ""
+		case !strings.HasPrefix(words[i], "-"):
+			kept = append(kept, words[i])
+		case outputFlags[name]:
+			i++
+		case !strings.Contains(name, "=") || !outputFlags[name[:strings.Index(name, "=")]]:
+			kept = append(kept, words[i])
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// generatedArgs returns the command-line arguments recorded in the header
+// of the generated file.
+func generatedArgs(name string) []string {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil
+	}
+	const mark = "// Code generated by \"repacker"
+	i := bytes.Index(data, []byte(mark))
+	if i < 0 {
+		return nil
+	}
+	line := data[i+len(mark):]
+	if j := bytes.Index(line, []byte("\"; DO NOT EDIT")); j >= 0 {
+		return strings.Fields(string(line[:j]))
+	}
+	return nil
+}
+
+// staleNames returns the names of the stale files, sorted.
+func (g *Generator) staleNames() []string {
+	var names []string
+	for name := range g.stale {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// funcDeclName returns the name of the func, qualified by the type of its
+// receiver for methods (e.g. Bar.ToFoo).
+func funcDeclName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+	recv := decl.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok {
+		return ident.Name + "." + decl.Name.Name
+	}
+	return decl.Name.Name
+}
+
+// generateHelpers generates the helpers used by the generated code, and its
+// deep copies, once per package: those declared by the package, or by
+// another generated file than outputName, are shared.
+func (g *Generator) generateHelpers(pkg *Package, outputName string) error {
+	if len(g.helpers) == 0 && len(g.deepCopyNames) == 0 {
+		return nil
+	}
+	declared, err := g.declaredElsewhere(pkg.dir, outputName)
+	if err != nil {
+		return err
+	}
+	for _, helper := range packageHelpers {
+		if !g.helpers[helper.name] {
+			continue
+		}
+		if name, ok := declared[helper.name]; ok {
+			g.logger.printf(levelInfo, "share %s of %s", helper.name, filepath.Base(name))
+			continue
+		}
+		if obj := pkg.types.Scope().Lookup(helper.name); obj != nil {
+			g.logger.printf(levelInfo, "share %s of the package", helper.name)
+			continue
+		}
+		g.Printf("%s", helper.code)
+	}
+	// The deep copies add those of their elements as they are generated.
+	for i := 0; i < len(g.deepCopyNames); i++ {
+		funcName := g.deepCopyNames[i]
+		if name, ok := declared[funcName]; ok {
+			g.logger.printf(levelInfo, "share %s of %s", funcName, filepath.Base(name))
+			continue
+		}
+		if obj := pkg.types.Scope().Lookup(funcName); obj != nil {
+			g.logger.printf(levelInfo, "share %s of the package", funcName)
+			continue
+		}
+		g.generateDeepCopy(funcName, g.deepCopyTypes[funcName])
+	}
+	return nil
+}
+
+func (g *Generator) generateConverteCode(typ Type, primitive string) (string, error) {
+	pkg, err := g.parsePackageDir(typ.dir)
+	if err != nil {
+		return "", err
+	}
+
+	obj, err := g.lookup(pkg, typ)
+	if err != nil {
+		return "", errors.Wrapf(err, "Lookup: %s.%s", pkg.name, typ.name)
+	}
+	s, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return "", errors.Errorf("%s is not a struct", typ.name)
+	}
+	for i := 0; i < s.NumFields(); i++ {
+		field := s.Field(i)
+		if field.Exported() && primitive == strings.ToLower(field.Name()) && primitive == field.Type().String() {
+			return field.Name(), nil
+		}
+	}
+
+	return "", errors.New("not found")
+
+}
+
+// callCode returns the call of the generated converter on arg, after the
+// params of -params. isPointer reports whether arg is already a pointer
+// (or a slice).
+func (g *Generator) callCode(funcName, arg string, isPointer bool) string {
+	if call, ok := g.calls[funcName]; ok {
+		// The literals of elemCode pass the params.
+		return fmt.Sprintf(call, arg)
+	}
+	if g.methods[funcName] {
+		return fmt.Sprintf("%s.%s(%s)", arg, funcName[strings.Index(funcName, ".")+1:], g.paramArgs())
+	}
+	if !isPointer {
+		return fmt.Sprintf("%s(%s)", funcName, g.paramArgs("&"+arg))
+	}
+	return fmt.Sprintf("%s(%s)", funcName, g.paramArgs(arg))
+}
+
+// funcCode returns the code naming the converter function, which may be
+// qualified by its import path (e.g. github.com/foo/money.ToCents),
+// and records the import of its package. The signature is nil if the
+// function is left to goimports.
+func (g *Generator) funcCode(name string) (string, *types.Signature, error) {
+	importPath, funcName := "", name
+	dir := g.dir
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		importPath, funcName = name[:i], name[i+1:]
+		var err error
+		dir, err = g.importDir(importPath, g.dir)
+		if err != nil && !strings.Contains(importPath, "/") {
+			// Leave a package name (e.g. money.ToCents) to goimports.
+			return name, nil, nil
+		} else if err != nil {
+			return "", nil, errors.Wrapf(err, "%s", name)
+		}
+	}
+	pkg, err := g.parsePackageDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	fn, ok := pkg.types.Scope().Lookup(funcName).(*types.Func)
+	if !ok && importPath == "" {
+		// A func of a file not loaded yet, e.g. one being written.
+		return name, nil, nil
+	} else if !ok {
+		return "", nil, errors.Errorf("%s: no func %s in %s", name, funcName, importPath)
+	}
+	if dir == g.dir {
+		return funcName, fn.Type().(*types.Signature), nil
+	}
+	g.imports = append(g.imports, importPath)
+	return g.names.name(pkg.path, pkg.name) + "." + funcName, fn.Type().(*types.Signature), nil
+}
+
+// fallible reports whether the function of the signature also returns an
+// error, as a fallible conversion does.
+func fallible(sig *types.Signature) bool {
+	if sig == nil || sig.Results().Len() != 2 {
+		return false
+	}
+	return types.Identical(sig.Results().At(1).Type(), types.Universe.Lookup("error").Type())
+}
+
+// typeConverter returns the converter of src into dst, or nil if none.
+func (g *Generator) typeConverter(src, dst types.Type) *Converter {
+	for i, c := range g.typeConverters {
+		if c.Src == types.TypeString(src, nil) && c.Dst == types.TypeString(dst, nil) {
+			return &g.typeConverters[i]
+		}
+	}
+	return nil
+}
+
+// typeConverterCode returns the code converting expr of type t with the
+// converter, and the signature of its function.
+func (g *Generator) typeConverterCode(c *Converter, expr string, t types.Type) (string, *types.Signature, error) {
+	if strings.HasPrefix(c.Func, ".") {
+		obj, _, _ := types.LookupFieldOrMethod(t, true, nil, c.Func[1:])
+		method, ok := obj.(*types.Func)
+		if !ok {
+			return "", nil, errors.Errorf("%s has no method %s", c.Src, c.Func[1:])
+		}
+		return fmt.Sprintf("%s%s()", expr, c.Func), method.Type().(*types.Signature), nil
+	}
+	name, sig, err := g.funcCode(c.Func)
+	if err != nil {
+		return "", nil, err
+	}
+	passed, err := g.passedParams(name, sig)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(append(passed, expr), ", ")), sig, nil
+}
+
+// protobufKnown is the import path of the packages of the protobuf
+// well-known types.
+const protobufKnown = "google.golang.org/protobuf/types/known/"
+
+// wellKnownTypes are the Go types of the protobuf well-known types, by the
+// type name in their package, with the method unwrapping them and the
+// function wrapping them.
+var wellKnownTypes = map[string]struct{ typ, method, wrap string }{
+	"timestamppb.Timestamp":  {"time.Time", "AsTime", "timestamppb.New"},
+	"durationpb.Duration":    {"time.Duration", "AsDuration", "durationpb.New"},
+	"wrapperspb.DoubleValue": {"float64", "GetValue", "wrapperspb.Double"},
+	"wrapperspb.FloatValue":  {"float32", "GetValue", "wrapperspb.Float"},
+	"wrapperspb.Int64Value":  {"int64", "GetValue", "wrapperspb.Int64"},
+	"wrapperspb.UInt64Value": {"uint64", "GetValue", "wrapperspb.UInt64"},
+	"wrapperspb.Int32Value":  {"int32", "GetValue", "wrapperspb.Int32"},
+	"wrapperspb.UInt32Value": {"uint32", "GetValue", "wrapperspb.UInt32"},
+	"wrapperspb.BoolValue":   {"bool", "GetValue", "wrapperspb.Bool"},
+	"wrapperspb.StringValue": {"string", "GetValue", "wrapperspb.String"},
+	"wrapperspb.BytesValue":  {"[]byte", "GetValue", "wrapperspb.Bytes"},
+}
+
+// wellKnownType returns the import path of the protobuf well-known type t
+// points to (e.g. *timestamppb.Timestamp) and its name in its package,
+// or "" if it is none.
+func wellKnownType(t types.Type) (importPath, name string) {
+	p, ok := t.(*types.Pointer)
+	if !ok {
+		return "", ""
+	}
+	named, ok := types.Unalias(p.Elem()).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || !strings.HasPrefix(named.Obj().Pkg().Path(), protobufKnown) {
+		return "", ""
+	}
+	name = types.TypeString(named, packageName)
+	if _, ok := wellKnownTypes[name]; !ok {
+		return "", ""
+	}
+	return named.Obj().Pkg().Path(), name
+}
+
+// isWellKnown reports whether t is a pointer to a protobuf well-known type.
+func isWellKnown(t types.Type) bool {
+	_, name := wellKnownType(t)
+	return name != ""
+}
+
+// wellKnownCode returns the code converting expr of a protobuf well-known
+// type into its Go type or a pointer to it (e.g. *timestamppb.Timestamp
+// into time.Time), or back, writing the variables it needs with the
+// name variable. A nil well-known type converts into the zero value or a
+// nil pointer, and a nil pointer into a nil well-known type.
+func (g *Generator) wellKnownCode(src, dst types.Type, expr, variable string, variables *bytes.Buffer) (string, bool) {
+	if _, name := wellKnownType(src); name != "" {
+		known := wellKnownTypes[name]
+		unwrapped := fmt.Sprintf("%s.%s()", expr, known.method)
+		switch types.TypeString(dst, packageName) {
+		case known.typ:
+			if known.method == "AsTime" {
+				// AsTime of nil is the Unix epoch.
+				fmt.Fprint(variables, derefCode(variable, known.typ, expr, unwrapped))
+				return variable, true
+			}
+			return unwrapped, true
+		case "*" + known.typ:
+			fmt.Fprint(variables, nilCheckedCode(variable, known.typ, expr, unwrapped))
+			return variable, true
+		}
+		return "", false
+	}
+	importPath, name := wellKnownType(dst)
+	if name == "" {
+		return "", false
+	}
+	known := wellKnownTypes[name]
+	switch types.TypeString(src, packageName) {
+	case known.typ:
+		g.imports = append(g.imports, importPath)
+		return fmt.Sprintf("%s(%s)", known.wrap, expr), true
+	case "*" + known.typ:
+		g.imports = append(g.imports, importPath)
+		fmt.Fprint(variables, derefCode(variable, "*"+name, expr, fmt.Sprintf("%s(*%s)", known.wrap, expr)))
+		return variable, true
+	}
+	return "", false
+}
+
+// sqlNullFields are the value fields of the sql.Null* types by type name.
+var sqlNullFields = map[string]string{
+	"NullString":  "String",
+	"NullInt64":   "Int64",
+	"NullInt32":   "Int32",
+	"NullInt16":   "Int16",
+	"NullByte":    "Byte",
+	"NullFloat64": "Float64",
+	"NullBool":    "Bool",
+	"NullTime":    "Time",
+	"Null":        "V", // sql.Null[T]
+}
+
+// sqlNull returns the value field of the sql.Null* type t (e.g. String of
+// sql.NullString), or of gorm.DeletedAt, and its type, or "" if t is none.
+func sqlNull(t types.Type) (field string, value types.Type) {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return "", nil
+	}
+	switch {
+	case named.Obj().Pkg().Path() == "database/sql":
+		field = sqlNullFields[named.Obj().Name()]
+	case isGormDeletedAt(named):
+		field = "Time" // a sql.NullTime
+	}
+	s, ok := named.Underlying().(*types.Struct)
+	if field == "" || !ok {
+		return "", nil
+	}
+	for i := 0; i < s.NumFields(); i++ {
+		if s.Field(i).Name() == field {
+			return field, s.Field(i).Type()
+		}
+	}
+	return "", nil
+}
+
+// isSQLNull reports whether t is one of the sql.Null* types.
+func isSQLNull(t types.Type) bool {
+	field, _ := sqlNull(t)
+	return field != ""
+}
+
+// nonZeroCode returns the condition that expr, of type t or a pointer to
+// it, is not the zero value, if t is a basic type or time.Time.
+func nonZeroCode(expr string, t types.Type, isPointer bool) (string, bool) {
+	if isTime(t) {
+		return fmt.Sprintf("!%s.IsZero()", expr), true
+	}
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return "", false
+	}
+	if isPointer {
+		expr = "*" + expr
+	}
+	switch {
+	case basic.Info()&types.IsBoolean != 0:
+		return expr, true
+	case basic.Info()&types.IsString != 0:
+		return expr + ` != ""`, true
+	case basic.Info()&types.IsNumeric != 0:
+		return expr + " != 0", true
+	}
+	return "", false
+}
+
+// sqlNullCode returns the code converting expr of a sql.Null* type into
+// its value type or a pointer to it (e.g. sql.NullString into *string), or
+// back, writing the variables it needs with the name variable. An invalid
+// value leaves the zero value or a nil pointer, and a nil pointer an invalid
+// value. With -null=zero, it leaves a pointer to the zero value instead, and
+// the zero value converts into an invalid value.
+func (g *Generator) sqlNullCode(src, dst types.Type, expr, variable string, variables *bytes.Buffer, qualifier types.Qualifier) (string, bool) {
+	if field, value := sqlNull(src); field != "" {
+		valueName := types.TypeString(value, qualifier)
+		dstPtr, dstIsPointer := dst.(*types.Pointer)
+		switch {
+		case identical(dst, value), dstIsPointer && identical(dstPtr.Elem(), value) && g.null == "zero":
+			fmt.Fprintf(variables, "	var %s %s\n	if %s.Valid {\n		%s = %s.%s\n	}\n",
+				variable, valueName, expr, variable, expr, field)
+			if dstIsPointer {
+				return "&" + variable, true
+			}
+			return variable, true
+		case dstIsPointer && identical(dstPtr.Elem(), value):
+			fmt.Fprintf(variables, "	var %s *%s\n	if %s.Valid {\n		v := %s.%s\n		%s = &v\n	}\n",
+				variable, valueName, expr, expr, field, variable)
+			return variable, true
+		}
+		return "", false
+	}
+	field, value := sqlNull(dst)
+	if field == "" {
+		return "", false
+	}
+	nullName := types.TypeString(dst, qualifier)
+	srcPtr, srcIsPointer := src.(*types.Pointer)
+	switch {
+	case identical(src, value):
+		valid := "true"
+		if nonZero, ok := nonZeroCode(expr, value, false); ok && g.null == "zero" {
+			valid = nonZero
+		}
+		return fmt.Sprintf("%s{%s: %s, Valid: %s}", nullName, field, expr, valid), true
+	case srcIsPointer && identical(srcPtr.Elem(), value):
+		valid := expr + " != nil"
+		if nonZero, ok := nonZeroCode(expr, value, true); ok && g.null == "zero" {
+			valid += " && " + nonZero
+		}
+		fmt.Fprintf(variables, "	var %s %s\n	if %s {\n		%s = %s{%s: *%s, Valid: true}\n	}\n",
+			variable, nullName, valid, variable, nullName, field, expr)
+		return variable, true
+	}
+	return "", false
+}
+
+// formatCode returns the code that formats the expression of type t as
+// a string, with strconv for numbers and fmt.Sprint otherwise.
+func formatCode(expr string, t types.Type) string {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return fmt.Sprintf("fmt.Sprint(%s)", expr)
+	}
+	// The argument is converted for named and sized types.
+	arg := func(typeName string) string {
+		if types.Identical(t, types.Universe.Lookup(typeName).Type()) {
+			return expr
+		}
+		return conversionCode(typeName, expr)
+	}
+	switch info := basic.Info(); {
+	case basic.Kind() == types.Int:
+		return fmt.Sprintf("strconv.Itoa(%s)", arg("int"))
+	case info&types.IsUnsigned != 0:
+		return fmt.Sprintf("strconv.FormatUint(%s, 10)", arg("uint64"))
+	case info&types.IsInteger != 0:
+		return fmt.Sprintf("strconv.FormatInt(%s, 10)", arg("int64"))
+	case basic.Kind() == types.Float32:
+		return fmt.Sprintf("strconv.FormatFloat(%s, 'g', -1, 32)", arg("float64"))
+	case basic.Kind() == types.Float64:
+		return fmt.Sprintf("strconv.FormatFloat(%s, 'g', -1, 64)", arg("float64"))
+	}
+	return fmt.Sprintf("fmt.Sprint(%s)", expr)
+}
+
+// parseCode returns the strconv call that parses the string expression into
+// the named basic type. cast reports whether the result must still be
+// converted to that type.
+func parseCode(expr, basic string) (code string, cast bool, err error) {
+	switch basic {
+	case "bool":
+		return fmt.Sprintf("strconv.ParseBool(%s)", expr), false, nil
+	case "int":
+		return fmt.Sprintf("strconv.Atoi(%s)", expr), false, nil
+	case "int8", "int16", "int32", "int64":
+		return fmt.Sprintf("strconv.ParseInt(%s, 10, %s)", expr, basic[3:]), basic != "int64", nil
+	case "uint":
+		return fmt.Sprintf("strconv.ParseUint(%s, 10, 0)", expr), true, nil
+	case "uint8", "uint16", "uint32", "uint64":
+		return fmt.Sprintf("strconv.ParseUint(%s, 10, %s)", expr, basic[4:]), basic != "uint64", nil
+	case "float32", "float64":
+		return fmt.Sprintf("strconv.ParseFloat(%s, %s)", expr, basic[5:]), basic != "float64", nil
+	}
+	return "", false, fmt.Errorf("cannot parse string into %s", basic)
+}
+
+// nilCheckedCode returns the code that declares the *typeName variable
+// and sets it to the converted expr only when the field pointer is not nil.
+func nilCheckedCode(variable, typeName, field, expr string) string {
+	return fmt.Sprintf("	var %s *%s\n	if %s != nil {\n		v := %s\n		%s = &v\n	}\n",
+		variable, typeName, field, expr, variable)
+}
+
+// optionalCode returns the code of the pointer dst field converted into
+// code from expr of the plain type src, for -optional: the address of the
+// variable last written to variables after start, now set only when expr
+// is not the zero value (or a nil slice or map), so that the zero value
+// leaves a nil pointer. Other conversions are returned as is.
+func optionalCode(variables *bytes.Buffer, start int, expr string, src, dst types.Type, code string, qualifier types.Qualifier) string {
+	_, srcIsPointer := src.(*types.Pointer)
+	dstPtr, dstIsPointer := dst.(*types.Pointer)
+	if srcIsPointer || !dstIsPointer {
+		return code
+	}
+	check, ok := nonZeroCode(expr, src, false)
+	switch src.Underlying().(type) {
+	case *types.Slice, *types.Map:
+		check, ok = expr+" != nil", true
+	}
+	variable := strings.TrimPrefix(code, "&")
+	if !ok || variable == code || !token.IsIdentifier(variable) {
+		return code
+	}
+	written := strings.TrimSuffix(variables.String()[start:], "\n")
+	declaration := "	" + variable + " := "
+	last := written[strings.LastIndex(written, "\n")+1:]
+	if !strings.HasPrefix(last, declaration) {
+		return code
+	}
+	variables.Truncate(start + len(written) - len(last))
+	fmt.Fprintf(variables, "	var %s *%s\n	if %s {\n		v := %s\n		%s = &v\n	}\n",
+		variable, types.TypeString(dstPtr.Elem(), qualifier), check, strings.TrimPrefix(last, declaration), variable)
+	return variable
+}
+
+// derefCode returns the code that declares the typeName variable and sets
+// it to the converted expr only when the field pointer is not nil, leaving
+// the zero value otherwise.
+func derefCode(variable, typeName, field, expr string) string {
+	return fmt.Sprintf("	var %s %s\n	if %s != nil {\n		%s = %s\n	}\n",
+		variable, typeName, field, variable, expr)
+}
+
+// defaultCode returns the code of the default value of a field of type t.
+// Strings are quoted, and numbers and bools are checked as is. Any other
+// value is a Go expression (e.g. LevelInfo or time.Now()), written as is.
+// Pointers point to a variable of the default value of their element.
+func defaultCode(t types.Type, value string, qualifier types.Qualifier) (string, error) {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		elem, err := defaultCode(p.Elem(), value, qualifier)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("func() %s {\n	v := %s\n	return &v\n}()",
+			types.TypeString(t, qualifier), conversionCode(types.TypeString(p.Elem(), qualifier), elem)), nil
+	}
+	var err error
+	if basic, ok := t.Underlying().(*types.Basic); ok {
+		switch info := basic.Info(); {
+		case info&types.IsString != 0:
+			return strconv.Quote(value), nil
+		case info&types.IsBoolean != 0:
+			_, err = strconv.ParseBool(value)
+		case info&types.IsUnsigned != 0:
+			_, err = strconv.ParseUint(value, 0, 64)
+		case info&types.IsInteger != 0:
+			_, err = strconv.ParseInt(value, 0, 64)
+		case info&types.IsFloat != 0:
+			_, err = strconv.ParseFloat(value, 64)
+		}
+		if err == nil {
+			return value, nil
+		}
+	}
+	expr, exprErr := parser.ParseExpr(value)
+	if _, literal := expr.(*ast.BasicLit); exprErr != nil || (literal && err != nil) {
+		return "", fmt.Errorf("invalid default %q for %s", value, t)
+	}
+	return value, nil
+}
+
+// errorCheck returns the code that returns early with the error
+// of a failed conversion of the named field, preceded by errResult.
+func errorCheck(errResult, fieldName string) string {
+	return fmt.Sprintf("	if err != nil {\n		return %sfmt.Errorf(\"%s: %%w\", err)\n	}\n", errResult, fieldName)
+}
+
+func (g *Generator) goimport(code []byte) ([]byte, error) {
+	src, err := imports.Process("", code, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to formats and adjusts imports for the provided file")
+	}
+	return src, nil
+}
+
+type Package struct {
+	dir       string
+	path      string // import path
+	name      string
+	types     *types.Package
+	testTypes map[string]string // files of the types declared in _test.go files, by name
+	docs      map[string]string // doc comments of the types and their fields, by name (e.g. User and User.Name)
+}
+
+// packageName qualifies types by package name, as the generated code
+// refers to them.
+func packageName(p *types.Package) string {
+	return p.Name()
+}
+
+// packagePath qualifies types by import path, to compare types of packages
+// loaded apart, which the packages of the same name would confuse.
+func packagePath(p *types.Package) string {
+	return p.Path()
+}
+
+// srcDir returns the directory of -srcdir. A relative path is relative to
+// the root of the module of dir, so that go:generate directives anywhere in
+// the module share the paths, unless it starts with ./ or ../. In a
+// workspace, a path not in the module is relative to the go.work instead
+// (e.g. src/models for a module of the workspace next to that of dir).
+func srcDir(path, dir string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	if path == "." || path == ".." || strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") {
+		return filepath.Abs(path)
+	}
+	root := moduleRoot(dir)
+	if root == "" {
+		return "", errors.Errorf("-srcdir: no go.mod in %s or above for %s", dir, path)
+	}
+	if _, err := os.Stat(filepath.Join(root, path)); err != nil {
+		if work := goWork(dir); work != "" {
+			if _, err := os.Stat(filepath.Join(filepath.Dir(work), path)); err == nil {
+				return filepath.Join(filepath.Dir(work), path), nil
+			}
+		}
+	}
+	return filepath.Join(root, path), nil
+}
+
+// moduleRoot returns the directory of the go.mod of the module of dir, or
+// "" if there is none.
+func moduleRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// declaredFuncs returns the functions and methods (e.g. Foo.PopulateFrom)
+// declared by the code, in order.
+func declaredFuncs(code []byte) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), "", code, 0)
+	if err != nil {
+		return nil
+	}
+	var funcs []string
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		name := fn.Name.Name
+		if fn.Recv != nil && len(fn.Recv.List) > 0 {
+			recv := fn.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			// Without the type params of a generic type (e.g. Page[T]).
+			switch index := recv.(type) {
+			case *ast.IndexExpr:
+				recv = index.X
+			case *ast.IndexListExpr:
+				recv = index.X
+			}
+			name = types.ExprString(recv) + "." + name
+		}
+		funcs = append(funcs, name)
+	}
+	return funcs
+}
+
+// isDirectory reports whether the named file is a directory.
+func isDirectory(name string) (bool, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+func splitType(name string) (importPath, typeName string) {
+	token := strings.Split(name, ".")
+	if len(token) == 1 {
+		return "", name
+	}
+	importPath = strings.Join(token[:len(token)-1], ".")
+	typeName = token[len(token)-1]
+	return importPath, typeName
+}
+
+// normalizeName folds the field name for -match=normalized (or -fuzzy),
+// so that user_id, UserID and UserId are all equal.
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
+
+// matchName folds the field name for the -match strategy.
+func (g *Generator) matchName(name string) string {
+	if g.match == "case-insensitive" {
+		return strings.ToLower(name)
+	}
+	return normalizeName(name)
+}
+
+func toLowerFirstChar(str string) string {
+	for i, v := range str {
+		return string(unicode.ToLower(v)) + str[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,190 @@
+package repacker
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// copyDir copies the files of the directory src into dst, recursively.
+func copyDir(t *testing.T, src, dst string) {
+	t.Helper()
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), b, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// generateModule generates the code of opts into dst/repack.go of a copy
+// of the module testdata/name, with the files added, and returns the
+// directory of the copy and the code.
+func generateModule(t *testing.T, name string, opts Options, files map[string]string) (string, []byte) {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	dir := t.TempDir()
+	copyDir(t, filepath.Join("testdata", name), dir)
+	for file, code := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(code), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts.Dir = filepath.Join(dir, "dst")
+	code, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("Generate: %+v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dst", "repack.go"), code, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, code
+}
+
+// goCommand runs go with the args in the module of dir, and returns its
+// output.
+func goCommand(t *testing.T, dir string, code []byte, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go %s: %v\n%s\n%s", strings.Join(args, " "), err, out, code)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// runModule generates the code of opts into the dst package of a copy of
+// the module testdata/name, and returns the output of its main package.
+func runModule(t *testing.T, name string, opts Options, files map[string]string) string {
+	t.Helper()
+	dir, code := generateModule(t, name, opts, files)
+	return goCommand(t, dir, code, "run", ".")
+}
+
+// TestModuleDependencies checks that go.mod and go.sum hold all the
+// dependencies of the packages and their tests, such as those of
+// golang.org/x/tools/go/packages, as go install needs.
+func TestModuleDependencies(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	cmd := exec.Command("go", "list", "-mod=readonly", "-deps", "-test", "./...")
+	cmd.Env = append(os.Environ(), "GOWORK=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go list: %v\n%s", err, out)
+	}
+}
+
+func TestNilElements(t *testing.T) {
+	const convert = `package main
+
+import (
+	"nilelem/dst"
+	"nilelem/src"
+)
+
+func convert(s *src.Bar) *dst.Foo {
+	return dst.NewFooFromSrcBar(s)
+}
+`
+	const convertWithError = `package main
+
+import (
+	"nilelem/dst"
+	"nilelem/src"
+)
+
+func convert(s *src.Bar) *dst.Foo {
+	d, err := dst.NewFooFromSrcBar(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+`
+	tests := []struct {
+		name      string
+		withError bool
+		generics  bool
+	}{
+		{name: "plain"},
+		{name: "witherror", withError: true},
+		{name: "generics", generics: true},
+		{name: "generics witherror", withError: true, generics: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := convert
+			if tt.withError {
+				code = convertWithError
+			}
+			opts := Options{Src: "nilelem/src.Bar", Dst: "Foo", WithError: tt.withError, Generics: tt.generics}
+			got := runModule(t, "nilelem", opts, map[string]string{"convert.go": code})
+			// The nil elements are the zero value of Item.
+			if want := "&{[{0} {1}] map[a:{0} b:{2}]}"; got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata/kinds")
+
+// TestKinds compares the converters of each kind of field, generated into
+// the module testdata/kinds, with their golden files, and builds them.
+func TestKinds(t *testing.T) {
+	tests := []struct {
+		name      string
+		withError bool
+	}{
+		{name: "Basic"},
+		{name: "Pointer"},
+		{name: "Slice"},
+		{name: "Map"},
+		{name: "Array"},
+		{name: "Struct"},
+		{name: "Text", withError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Src: "kinds/src." + tt.name, Dst: tt.name, WithError: tt.withError}
+			dir, code := generateModule(t, "kinds", opts, nil)
+			golden := filepath.Join("testdata", "kinds", "golden", strings.ToLower(tt.name)+".golden")
+			if *update {
+				if err := os.WriteFile(golden, code, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(code, want) {
+				t.Errorf("the code differs from %s; rerun with -update if intended:\n%s", golden, code)
+			}
+			goCommand(t, dir, code, "build", "./...")
+		})
+	}
+}
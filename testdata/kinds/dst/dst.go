@@ -0,0 +1,57 @@
+package dst
+
+import "time"
+
+type Level string
+
+const (
+	LevelLow  Level = "low"
+	LevelHigh Level = "high"
+)
+
+type Item struct {
+	ID int
+}
+
+type Basic struct {
+	Count   int64
+	Name    string
+	Level   Level
+	Timeout int64
+}
+
+type Pointer struct {
+	Count int
+	Name  *string
+	Ratio *int64
+}
+
+type Slice struct {
+	IDs   []int64
+	Grid  [][]int64
+	Items []Item
+}
+
+type Map struct {
+	Scores map[string]int64
+	Groups map[string][]int64
+	Items  map[string]Item
+}
+
+type Array struct {
+	Points [3]int64
+	Items  [2]Item
+}
+
+type Struct struct {
+	Item  Item
+	Ptr   Item
+	Value *Item
+}
+
+type Text struct {
+	Created string
+	Age     int
+	Active  string
+	Started time.Time
+}
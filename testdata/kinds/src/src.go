@@ -0,0 +1,57 @@
+package src
+
+import "time"
+
+type Level int
+
+const (
+	LevelLow Level = iota
+	LevelHigh
+)
+
+type Item struct {
+	ID int
+}
+
+type Basic struct {
+	Count   int32
+	Name    []byte
+	Level   Level
+	Timeout time.Duration `repack:"Timeout,unit=ms"`
+}
+
+type Pointer struct {
+	Count *int
+	Name  string
+	Ratio *int32
+}
+
+type Slice struct {
+	IDs   []int32
+	Grid  [][]int32
+	Items []*Item
+}
+
+type Map struct {
+	Scores map[string]int32
+	Groups map[string][]int32
+	Items  map[string]*Item
+}
+
+type Array struct {
+	Points [3]int32
+	Items  [2]*Item
+}
+
+type Struct struct {
+	Item  Item
+	Ptr   *Item
+	Value Item
+}
+
+type Text struct {
+	Created time.Time
+	Age     string
+	Active  bool
+	Started string
+}
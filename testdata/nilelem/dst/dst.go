@@ -0,0 +1,10 @@
+package dst
+
+type Item struct {
+	N int
+}
+
+type Foo struct {
+	Items  []Item
+	ByName map[string]Item
+}
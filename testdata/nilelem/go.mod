@@ -0,0 +1,3 @@
+module nilelem
+
+go 1.21
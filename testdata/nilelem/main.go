@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+
+	"nilelem/src"
+)
+
+func main() {
+	s := &src.Bar{
+		Items:  []*src.Item{nil, {N: 1}},
+		ByName: map[string]*src.Item{"a": nil, "b": {N: 2}},
+	}
+	fmt.Printf("%v\n", convert(s))
+}
@@ -0,0 +1,10 @@
+package src
+
+type Item struct {
+	N int
+}
+
+type Bar struct {
+	Items  []*Item
+	ByName map[string]*Item
+}
@@ -0,0 +1,125 @@
+package repacker
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// timeUnits are the methods of time.Time returning the numbers of the Unix
+// units of the unit option, and the formats of the calls converting such
+// numbers back.
+var timeUnits = map[string]struct{ method, back string }{
+	"unix":   {"Unix", "time.Unix(%s, 0)"},
+	"unixms": {"UnixMilli", "time.UnixMilli(%s)"},
+	"unixus": {"UnixMicro", "time.UnixMicro(%s)"},
+	"unixns": {"UnixNano", "time.Unix(0, %s)"},
+}
+
+// isInteger reports whether the underlying type of t is an integer type.
+func isInteger(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsInteger != 0
+}
+
+// location returns the code of the location of the tz option of the
+// tags, time.UTC, time.Local or else a variable loaded by the setup code
+// with time.LoadLocation, or "" if there is none. skipped is why the field
+// is skipped instead, if so.
+func (g *Generator) location(dstTag, srcTag, variable, errResult, fieldName string) (loc, setup, skipped string, err error) {
+	tz, ok := g.fieldOption(dstTag, srcTag, "tz")
+	switch {
+	case !ok:
+		return "", "", "", nil
+	case tz == "UTC" || tz == "Local":
+		return "time." + tz, "", "", nil
+	}
+	if _, err := time.LoadLocation(tz); err != nil || tz == "" {
+		return "", "", "", errors.Errorf("unknown tz %q; use UTC, Local or a name of the IANA time zone database (e.g. Asia/Tokyo)", tz)
+	}
+	if !g.withError {
+		return "", "", "due to fallible conversion; use -witherror", nil
+	}
+	loc = variable + "Location"
+	setup = fmt.Sprintf("	%s, err := time.LoadLocation(%s)\n", loc, strconv.Quote(tz)) + errorCheck(errResult, fieldName)
+	return loc, setup, "", nil
+}
+
+// timeCode returns the code converting expr of time.Time into a number of
+// the Unix unit of the unit option, or back, in the location of the tz
+// option, or into another time.Time in that location, writing the
+// variables it needs with the name variable, or "" if neither applies. A
+// nil *time.Time converts into 0 with deref. skipped is why the field is
+// skipped instead, if so.
+func (g *Generator) timeCode(src, dst types.Type, dstTag, srcTag, expr, variable, errResult, fieldName string,
+	variables *bytes.Buffer, deref func(variable, typeName, pointer, expr string) string, qualifier types.Qualifier) (code string, skipped string, err error) {
+	_, srcIsPtr := src.(*types.Pointer)
+	dstPtr, dstIsPtr := dst.(*types.Pointer)
+	unit, hasUnit := g.fieldOption(dstTag, srcTag, "unit")
+	timeUnit, isTimeUnit := timeUnits[unit]
+	_, hasTZ := g.fieldOption(dstTag, srcTag, "tz")
+	switch {
+	case hasUnit && (isTime(src) && isInteger(dst) || isInteger(src) && !srcIsPtr && isTime(dst)):
+		if !isTimeUnit {
+			return "", "", errors.Errorf("unknown unit %q of a time; use unix, unixms, unixus or unixns", unit)
+		}
+	case hasTZ && isTime(src) && isTime(dst) && srcIsPtr == dstIsPtr:
+	case hasTZ && !isTime(src) && !isTime(dst):
+		return "", "", errors.New("tz option of a field of no time.Time")
+	default:
+		return "", "", nil
+	}
+	typeName := types.TypeString(dst, qualifier)
+
+	if isTime(src) && isInteger(dst) {
+		// The numbers count from the epoch in any location, but a tz option
+		// for the way back is still checked.
+		if _, _, _, err := g.location(dstTag, srcTag, variable, errResult, fieldName); err != nil {
+			return "", "", err
+		}
+		// The numbers of other integer types may overflow.
+		if g.strict && !isBasicKind(dst, types.Int64) {
+			return "", "due to narrowing conversion", nil
+		}
+		number := fmt.Sprintf("%s.%s()", expr, timeUnit.method)
+		if !types.Identical(dst, types.Typ[types.Int64]) {
+			number = conversionCode(typeName, number)
+		}
+		if srcIsPtr {
+			return deref(variable, typeName, expr, number), "", nil
+		}
+		return number, "", nil
+	}
+
+	loc, setup, skipped, err := g.location(dstTag, srcTag, variable, errResult, fieldName)
+	if err != nil || skipped != "" {
+		return "", skipped, err
+	}
+	variables.WriteString(setup)
+	var t string
+	if isInteger(src) {
+		value := expr
+		if !types.Identical(src, types.Typ[types.Int64]) {
+			value = conversionCode("int64", expr)
+		}
+		t = fmt.Sprintf(timeUnit.back, value)
+	} else {
+		t = expr
+	}
+	if loc != "" {
+		t = fmt.Sprintf("%s.In(%s)", t, loc)
+	}
+	switch {
+	case srcIsPtr && dstIsPtr:
+		fmt.Fprint(variables, nilCheckedCode(variable, types.TypeString(dstPtr.Elem(), qualifier), expr, t))
+		return variable, "", nil
+	case dstIsPtr:
+		fmt.Fprintf(variables, "	%s := %s\n", variable, t)
+		return "&" + variable, "", nil
+	}
+	return t, "", nil
+}
@@ -0,0 +1,51 @@
+package repacker
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// transformCode returns the code passing expr through the funcs of the
+// transform option, separated by | and called in order (e.g.
+// strings.TrimSpace(strings.ToLower(s.Name)) for
+// transform=strings.ToLower|strings.TrimSpace), converted to the dst type
+// if it is a named type of the result, and the signature of the last func.
+// Only the last func may also return an error.
+func (g *Generator) transformCode(chain, expr string, dst types.Type, qualifier types.Qualifier) (string, *types.Signature, error) {
+	code := expr
+	var sig *types.Signature
+	funcs := strings.Split(chain, "|")
+	for i, f := range funcs {
+		if f == "" {
+			return "", nil, errors.Errorf("transform option %s: empty func", chain)
+		}
+		name, fnSig, err := g.funcCode(f)
+		if err != nil {
+			return "", nil, err
+		}
+		switch {
+		case fnSig == nil || fnSig.Results().Len() == 1 || i == len(funcs)-1 && fallible(fnSig):
+		case fallible(fnSig):
+			return "", nil, errors.Errorf("transform option %s: only the last func may return an error, not %s", chain, name)
+		default:
+			return "", nil, errors.Errorf("transform option %s: %s returns %d values", chain, name, fnSig.Results().Len())
+		}
+		passed, err := g.passedParams(name, fnSig)
+		if err != nil {
+			return "", nil, err
+		}
+		code, sig = fmt.Sprintf("%s(%s)", name, strings.Join(append(passed, code), ", ")), fnSig
+	}
+	if sig == nil || fallible(sig) {
+		return code, sig, nil
+	}
+	// A string result converts to a named string (e.g. Status).
+	result := sig.Results().At(0).Type()
+	if !assignable(result, dst) && types.ConvertibleTo(result, dst) && types.Identical(result.Underlying(), dst.Underlying()) {
+		code = conversionCode(types.TypeString(dst, qualifier), code)
+	}
+	return code, sig, nil
+}
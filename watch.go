@@ -0,0 +1,153 @@
+package repacker
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+// watchDelay is how long Watch waits for more changes before running, as
+// editors and refactoring tools write several files in a row.
+const watchDelay = 100 * time.Millisecond
+
+// Watch runs each of opts as Run, then again whenever a Go file changes in
+// the directories of the packages of its module it read, until the watcher
+// fails. Errors of the runs are logged rather than returned, and a failed
+// run runs again on any change, so that the next edit can fix it.
+func Watch(opts ...Options) error {
+	loggers := make([]*logger, len(opts))
+	for i, o := range opts {
+		if o.Check {
+			return errors.New("-watch cannot be combined with -check")
+		}
+		var err error
+		if loggers[i], err = newLogger(o); err != nil {
+			return err
+		}
+	}
+	// The watch itself logs as the first of opts.
+	l := &logger{}
+	if len(loggers) > 0 {
+		l = loggers[0]
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrapf(err, "watch: %s", err)
+	}
+	defer watcher.Close()
+
+	watched := map[string]bool{}
+	// dirs are the directories read by each of opts, or nil if its last
+	// run failed.
+	dirs := make([]map[string]bool, len(opts))
+	// outputs are the files written by the runs, whose changes are ignored.
+	outputs := map[string]bool{}
+	watch := func(dir string) error {
+		if watched[dir] {
+			return nil
+		}
+		if err := watcher.Add(dir); err != nil {
+			return errors.Wrapf(err, "watch %s: %s", dir, err)
+		}
+		watched[dir] = true
+		return nil
+	}
+	runAll := func(changed map[string]bool) error {
+		// The runs share the packages they load.
+		cache := &packageCache{}
+		for i, o := range opts {
+			if dirs[i] != nil && !intersects(dirs[i], changed) {
+				continue
+			}
+			dir := o.Dir
+			if dir == "" {
+				dir = "."
+			}
+			// The dst directory is watched even if the run fails.
+			if dir, err := filepath.Abs(dir); err == nil {
+				if err := watch(dir); err != nil {
+					return err
+				}
+			}
+			r, err := run(o, cache)
+			if err != nil {
+				loggers[i].printf(levelError, "%s", err)
+				dirs[i] = nil
+				continue
+			}
+			if !o.Stdout {
+				for _, output := range r.outputs(o) {
+					outputName, _ := filepath.Abs(output.name)
+					outputs[outputName] = true
+					outputs[strings.TrimSuffix(outputName, ".go")+"_test.go"] = true
+				}
+			}
+			dirs[i] = map[string]bool{}
+			for _, dir := range r.dirs {
+				if err := watch(dir); err != nil {
+					return err
+				}
+				dirs[i][dir] = true
+			}
+		}
+		return nil
+	}
+	if err := runAll(nil); err != nil {
+		return err
+	}
+	l.printf(levelNotice, "watching %d directories", len(watched))
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !isSourceChange(event, outputs) {
+				continue
+			}
+			changed := map[string]bool{filepath.Dir(event.Name): true}
+			for delay := time.After(watchDelay); delay != nil; {
+				select {
+				case event := <-watcher.Events:
+					if isSourceChange(event, outputs) {
+						changed[filepath.Dir(event.Name)] = true
+					}
+				case <-delay:
+					delay = nil
+				}
+			}
+			l.printf(levelNotice, "%s changed", event.Name)
+			if err := runAll(changed); err != nil {
+				return err
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return errors.Wrapf(err, "watch: %s", err)
+		}
+	}
+}
+
+// isSourceChange reports whether the event changes a Go file other than
+// the outputs.
+func isSourceChange(event fsnotify.Event, outputs map[string]bool) bool {
+	if event.Op == fsnotify.Chmod || !strings.HasSuffix(event.Name, ".go") {
+		return false
+	}
+	name, err := filepath.Abs(event.Name)
+	return err == nil && !outputs[name]
+}
+
+// intersects reports whether a and b have a key in common.
+func intersects(a, b map[string]bool) bool {
+	for key := range b {
+		if a[key] {
+			return true
+		}
+	}
+	return false
+}
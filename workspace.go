@@ -0,0 +1,108 @@
+package repacker
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// goWork returns the go.work file of the workspace of dir, as the go
+// command finds it: that of GOWORK, or else the first one in dir or above,
+// or "" if there is none or GOWORK is off.
+func goWork(dir string) string {
+	switch env := os.Getenv("GOWORK"); env {
+	case "off":
+		return ""
+	case "":
+	default:
+		return env
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.work")); err == nil {
+			return filepath.Join(dir, "go.work")
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// workspaceModules returns the directories of the modules of the go.work
+// file, those of its use directives, or nil if it cannot be read.
+func workspaceModules(goWork string) []string {
+	f, err := os.Open(goWork)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var dirs []string
+	inBlock := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case inBlock && fields[0] == ")":
+			inBlock = false
+			continue
+		case inBlock:
+		case fields[0] == "use" && len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+			continue
+		case fields[0] == "use" && len(fields) == 2:
+			fields = fields[1:]
+		default:
+			continue
+		}
+		dir := fields[0]
+		if unquoted, err := strconv.Unquote(dir); err == nil {
+			dir = unquoted
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(goWork), dir)
+		}
+		dirs = append(dirs, filepath.Clean(dir))
+	}
+	return dirs
+}
+
+// moduleRoots returns the directories of the main modules of dir: those of
+// its workspace, if it is in one, or else that of its module, if any.
+func moduleRoots(dir string) []string {
+	if work := goWork(dir); work != "" {
+		if dirs := workspaceModules(work); len(dirs) > 0 {
+			return dirs
+		}
+	}
+	if root := moduleRoot(dir); root != "" {
+		return []string{root}
+	}
+	return nil
+}
+
+// loadEnv returns the environment of the go command loading the packages
+// of dir, or nil for that of repacker. In a workspace, it leaves -mod=mod
+// out of GOFLAGS, which the go command refuses in workspace mode, so that a
+// GOFLAGS set for the modules alone does not fail the loading.
+func loadEnv(dir string) []string {
+	flags := strings.Fields(os.Getenv("GOFLAGS"))
+	var kept []string
+	for _, flag := range flags {
+		if flag != "-mod=mod" && flag != "--mod=mod" {
+			kept = append(kept, flag)
+		}
+	}
+	if len(kept) == len(flags) || goWork(dir) == "" {
+		return nil
+	}
+	return append(os.Environ(), "GOFLAGS="+strings.Join(kept, " "))
+}